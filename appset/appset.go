@@ -0,0 +1,223 @@
+// Package appset simulates the ApplicationSet controller offline: it
+// evaluates the generators of an ApplicationSet against a local checkout and
+// a cluster inventory, and renders the Applications they produce.
+//
+// The list, git (directories and files), clusters, matrix and merge
+// generators are supported. Generators that need a provider API, like
+// scmProvider and pullRequest, cannot be evaluated offline. Templates use
+// fasttemplate ({{path.basename}}) unless spec.goTemplate is set.
+package appset
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rebelopsio/argo-helper/yamledit"
+)
+
+// Options configures the simulation
+type Options struct {
+	// Fs is the filesystem the repository is read from, defaults to the OS
+	// filesystem
+	Fs afero.Fs
+	// RepoDir is the local checkout git generators read, defaults to the
+	// current directory
+	RepoDir string
+	// Clusters is the inventory of the clusters generator, defaults to
+	// DefaultInventory
+	Clusters []Cluster
+}
+
+func (o Options) fs() afero.Fs {
+	if o.Fs == nil {
+		return afero.NewOsFs()
+	}
+	return o.Fs
+}
+
+// Application is an Application generated by an ApplicationSet
+type Application struct {
+	Name    string
+	Project string
+	// RepoURL, TargetRevision and Path are those of the first source
+	RepoURL        string
+	TargetRevision string
+	Path           string
+	// Server and Cluster are the destination server and cluster name,
+	// usually only one of them is set
+	Server    string
+	Cluster   string
+	Namespace string
+	// Params are the generator parameters the Application was rendered with
+	Params Params
+	// Node is the rendered spec.template
+	Node *yaml.Node
+}
+
+// Destination returns the destination cluster, by server or by name
+func (a Application) Destination() string {
+	if a.Server != "" {
+		return a.Server
+	}
+	return a.Cluster
+}
+
+// Generate evaluates the generators of an ApplicationSet resource and
+// returns the Applications it produces, in the order of the generators
+func Generate(appSet *yaml.Node, opts Options) ([]Application, error) {
+	if appSet.Kind == yaml.DocumentNode && len(appSet.Content) > 0 {
+		appSet = appSet.Content[0]
+	}
+	if kind := yamledit.Scalar(appSet, "kind"); kind != "ApplicationSet" {
+		return nil, fmt.Errorf("expected an ApplicationSet, got %q", kind)
+	}
+	if opts.RepoDir == "" {
+		opts.RepoDir = "."
+	}
+
+	spec := yamledit.Lookup(appSet, "spec")
+	var options struct {
+		GoTemplate        bool     `yaml:"goTemplate"`
+		GoTemplateOptions []string `yaml:"goTemplateOptions"`
+	}
+	if spec != nil {
+		if err := spec.Decode(&options); err != nil {
+			return nil, fmt.Errorf("invalid spec: %w", err)
+		}
+	}
+	generators := yamledit.Lookup(spec, "generators")
+	if generators == nil || len(generators.Content) == 0 {
+		return nil, fmt.Errorf("spec.generators is empty")
+	}
+	tmpl := yamledit.Lookup(spec, "template")
+	if tmpl == nil {
+		return nil, fmt.Errorf("spec.template is missing")
+	}
+
+	e := &evaluator{opts: opts, goTemplate: options.GoTemplate, goOptions: options.GoTemplateOptions}
+
+	var apps []Application
+	for _, generator := range generators.Content {
+		params, err := e.generate(generator)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range params {
+			node, err := e.render(tmpl, p)
+			if err != nil {
+				return nil, err
+			}
+			apps = append(apps, application(node, p))
+		}
+	}
+	return apps, nil
+}
+
+// application reads the fields of a rendered template
+func application(node *yaml.Node, params Params) Application {
+	spec := yamledit.Lookup(node, "spec")
+	source := yamledit.Lookup(spec, "source")
+	if sources := yamledit.Lookup(spec, "sources"); source == nil && sources != nil && len(sources.Content) > 0 {
+		source = sources.Content[0]
+	}
+	destination := yamledit.Lookup(spec, "destination")
+
+	return Application{
+		Name:           yamledit.Scalar(yamledit.Lookup(node, "metadata"), "name"),
+		Project:        yamledit.Scalar(spec, "project"),
+		RepoURL:        yamledit.Scalar(source, "repoURL"),
+		TargetRevision: yamledit.Scalar(source, "targetRevision"),
+		Path:           yamledit.Scalar(source, "path"),
+		Server:         yamledit.Scalar(destination, "server"),
+		Cluster:        yamledit.Scalar(destination, "name"),
+		Namespace:      yamledit.Scalar(destination, "namespace"),
+		Params:         params,
+		Node:           node,
+	}
+}
+
+// render returns a copy of node with the parameters substituted in every
+// key and value
+func (e *evaluator) render(node *yaml.Node, params Params) (*yaml.Node, error) {
+	rendered := *node
+	if node.Kind == yaml.ScalarNode {
+		value, err := e.substitute(node.Value, params)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		rendered.Value = value
+		return &rendered, nil
+	}
+
+	rendered.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		var err error
+		if rendered.Content[i], err = e.render(child, params); err != nil {
+			return nil, err
+		}
+	}
+	return &rendered, nil
+}
+
+// fasttemplateTag matches a {{ param }} tag
+var fasttemplateTag = regexp.MustCompile(`\{\{(.*?)\}\}`)
+
+// substitute renders a template string. Like Argo CD, fasttemplate leaves
+// unknown parameters as they are.
+func (e *evaluator) substitute(value string, params Params) (string, error) {
+	if !strings.Contains(value, "{{") {
+		return value, nil
+	}
+
+	if !e.goTemplate {
+		return fasttemplateTag.ReplaceAllStringFunc(value, func(tag string) string {
+			key := strings.TrimSpace(tag[2 : len(tag)-2])
+			if param, ok := params[key]; ok {
+				return fmt.Sprint(param)
+			}
+			return tag
+		}), nil
+	}
+
+	t, err := template.New("").Funcs(goTemplateFuncs).Option(e.goOptions...).Parse(value)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, map[string]interface{}(params)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// goTemplateFuncs are the Sprig functions ApplicationSets commonly use
+var goTemplateFuncs = template.FuncMap{
+	"normalize": normalize,
+	"lower":     strings.ToLower,
+	"upper":     strings.ToUpper,
+	"trim":      strings.TrimSpace,
+	"replace": func(old, new, s string) string {
+		return strings.ReplaceAll(s, old, new)
+	},
+	"trunc": func(n int, s string) string {
+		if n >= 0 && len(s) > n {
+			return s[:n]
+		}
+		return s
+	},
+	"default": func(def interface{}, value interface{}) interface{} {
+		if value == nil || value == "" {
+			return def
+		}
+		return value
+	},
+	"join": func(sep string, items []string) string {
+		return strings.Join(items, sep)
+	},
+}
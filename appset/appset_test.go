@@ -0,0 +1,279 @@
+package appset
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+// repository returns an in-memory checkout with a few applications
+func repository(t *testing.T) afero.Fs {
+	t.Helper()
+
+	fsys := afero.NewMemMapFs()
+	files := map[string]string{
+		"/repo/apps/web/kustomization.yaml":   "",
+		"/repo/apps/api/kustomization.yaml":   "",
+		"/repo/apps/legacy/deployment.yaml":   "",
+		"/repo/config/web/config.json":        `{"app": "web", "team": {"name": "Front End"}}`,
+		"/repo/config/jobs/nightly/jobs.yaml": "- app: backup\n- app: report\n",
+		"/repo/.git/HEAD":                     "ref: refs/heads/main\n",
+	}
+	for path, content := range files {
+		if err := afero.WriteFile(fsys, path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	return fsys
+}
+
+// generate parses an ApplicationSet and returns its Applications as
+// name path destination namespace
+func generate(t *testing.T, source string, opts Options) ([]string, error) {
+	t.Helper()
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(source), &doc); err != nil {
+		t.Fatalf("Failed to parse ApplicationSet: %v", err)
+	}
+	apps, err := Generate(&doc, opts)
+
+	var summary []string
+	for _, app := range apps {
+		summary = append(summary, strings.Join([]string{app.Name, app.Path, app.Destination(), app.Namespace}, " "))
+	}
+	return summary, err
+}
+
+// appSet returns an ApplicationSet with the generators and a template
+// using their parameters
+func appSet(generators, name, path, server, namespace string) string {
+	return `apiVersion: argoproj.io/v1alpha1
+kind: ApplicationSet
+metadata:
+  name: apps
+spec:
+  generators:
+` + generators + `  template:
+    metadata:
+      name: '` + name + `'
+    spec:
+      project: demo
+      source:
+        repoURL: https://example.com/repo.git
+        path: '` + path + `'
+      destination:
+        server: '` + server + `'
+        namespace: '` + namespace + `'
+`
+}
+
+func TestGenerate(t *testing.T) {
+	clusters := []Cluster{
+		{Name: "prod-eu", Server: "https://prod-eu", Labels: map[string]string{"env": "prod"}},
+		{Name: "prod-us", Server: "https://prod-us", Labels: map[string]string{"env": "prod"}},
+		{Name: "Dev", Server: "https://dev", Labels: map[string]string{"env": "dev"}},
+	}
+	opts := Options{Fs: repository(t), RepoDir: "/repo", Clusters: clusters}
+
+	tests := []struct {
+		name     string
+		appSet   string
+		expected []string
+	}{
+		{
+			name: "list",
+			appSet: appSet(`    - list:
+        elements:
+          - name: web
+            namespace: frontend
+          - name: api
+            namespace: backend
+`, "{{name}}", "apps/{{name}}", "https://kubernetes.default.svc", "{{ namespace }}"),
+			expected: []string{
+				"web apps/web https://kubernetes.default.svc frontend",
+				"api apps/api https://kubernetes.default.svc backend",
+			},
+		},
+		{
+			name: "git directories",
+			appSet: appSet(`    - git:
+        repoURL: https://example.com/repo.git
+        revision: HEAD
+        directories:
+          - path: apps/*
+          - path: apps/legacy
+            exclude: true
+`, "{{path.basename}}", "{{path}}", "https://kubernetes.default.svc", "{{path[1]}}"),
+			expected: []string{
+				"api apps/api https://kubernetes.default.svc api",
+				"web apps/web https://kubernetes.default.svc web",
+			},
+		},
+		{
+			name: "git files",
+			appSet: appSet(`    - git:
+        repoURL: https://example.com/repo.git
+        revision: HEAD
+        files:
+          - path: config/**/*.json
+          - path: config/**/jobs.yaml
+`, "{{app}}", "{{path}}", "https://kubernetes.default.svc", "{{team.name}}"),
+			expected: []string{
+				"backup config/jobs/nightly https://kubernetes.default.svc {{team.name}}",
+				"report config/jobs/nightly https://kubernetes.default.svc {{team.name}}",
+				"web config/web https://kubernetes.default.svc Front End",
+			},
+		},
+		{
+			name: "clusters",
+			appSet: appSet(`    - clusters:
+        selector:
+          matchExpressions:
+            - key: env
+              operator: In
+              values: [dev]
+`, "web-{{nameNormalized}}", "apps/web", "{{server}}", "{{metadata.labels.env}}"),
+			expected: []string{"web-dev apps/web https://dev dev"},
+		},
+		{
+			name: "matrix",
+			appSet: appSet(`    - matrix:
+        generators:
+          - git:
+              repoURL: https://example.com/repo.git
+              revision: HEAD
+              directories:
+                - path: apps/web
+          - clusters:
+              selector:
+                matchLabels:
+                  env: prod
+`, "{{path.basename}}-{{name}}", "{{path}}", "{{server}}", "{{path.basename}}"),
+			expected: []string{
+				"web-prod-eu apps/web https://prod-eu web",
+				"web-prod-us apps/web https://prod-us web",
+			},
+		},
+		{
+			name: "matrix with parameters of the first generator",
+			appSet: appSet(`    - matrix:
+        generators:
+          - list:
+              elements:
+                - dir: config
+          - git:
+              repoURL: https://example.com/repo.git
+              revision: HEAD
+              files:
+                - path: '{{dir}}/web/config.json'
+`, "{{app}}", "{{path}}", "https://kubernetes.default.svc", "{{dir}}"),
+			expected: []string{"web config/web https://kubernetes.default.svc config"},
+		},
+		{
+			name: "merge",
+			appSet: appSet(`    - merge:
+        mergeKeys: [server]
+        generators:
+          - clusters: {}
+          - list:
+              elements:
+                - server: https://prod-us
+                  namespace: us-only
+`, "web-{{name}}", "apps/web", "{{server}}", "{{namespace}}"),
+			expected: []string{
+				"web-prod-eu apps/web https://prod-eu {{namespace}}",
+				"web-prod-us apps/web https://prod-us us-only",
+				"web-Dev apps/web https://dev {{namespace}}",
+			},
+		},
+		{
+			name: "go template",
+			appSet: strings.Replace(appSet(`    - git:
+        repoURL: https://example.com/repo.git
+        revision: HEAD
+        directories:
+          - path: apps/*
+        values:
+          owner: platform
+`, "{{ .path.basename }}-{{ .values.owner }}", "{{ .path.path }}", "https://kubernetes.default.svc", "{{ index .path.segments 1 | upper }}"),
+				"spec:\n", "spec:\n  goTemplate: true\n", 1),
+			expected: []string{
+				"api-platform apps/api https://kubernetes.default.svc API",
+				"legacy-platform apps/legacy https://kubernetes.default.svc LEGACY",
+				"web-platform apps/web https://kubernetes.default.svc WEB",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apps, err := generate(t, tt.appSet, opts)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(apps, tt.expected) {
+				t.Errorf("Expected:\n%s\ngot:\n%s", strings.Join(tt.expected, "\n"), strings.Join(apps, "\n"))
+			}
+		})
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	opts := Options{Fs: repository(t), RepoDir: "/repo"}
+
+	tests := map[string]string{
+		"needs the provider API": `    - pullRequest:
+        github:
+          owner: org
+          repo: repo
+`,
+		"matrix needs two generators": `    - matrix:
+        generators:
+          - clusters: {}
+`,
+		"duplicate merge keys": `    - merge:
+        mergeKeys: [env]
+        generators:
+          - list:
+              elements:
+                - env: prod
+                - env: prod
+          - clusters: {}
+`,
+	}
+	for name, generators := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := generate(t, appSet(generators, "x", "x", "x", "x"), opts); err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+}
+
+func TestReadInventory(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	inventory := "clusters:\n  - name: prod\n    server: https://prod\n    labels:\n      env: prod\n"
+	if err := afero.WriteFile(fsys, "/clusters.yaml", []byte(inventory), 0644); err != nil {
+		t.Fatalf("Failed to write inventory: %v", err)
+	}
+
+	clusters, err := ReadInventory(fsys, "/clusters.yaml")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []Cluster{{Name: "prod", Server: "https://prod", Labels: map[string]string{"env": "prod"}}}
+	if !reflect.DeepEqual(clusters, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, clusters)
+	}
+
+	if err := afero.WriteFile(fsys, "/invalid.yaml", []byte("clusters:\n  - name: prod\n"), 0644); err != nil {
+		t.Fatalf("Failed to write inventory: %v", err)
+	}
+	if _, err := ReadInventory(fsys, "/invalid.yaml"); err == nil {
+		t.Errorf("Expected error for a cluster without a server")
+	}
+}
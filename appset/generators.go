@@ -0,0 +1,617 @@
+package appset
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rebelopsio/argo-helper/yamledit"
+)
+
+// Params are the parameters a generator produces for one Application. With
+// fasttemplate the keys are flat (path.basename), with goTemplate nested
+// (.path.basename).
+type Params map[string]interface{}
+
+// offlineGenerators maps the generators that need a live Argo CD or a
+// provider API to what they need
+var offlineGenerators = map[string]string{
+	"scmProvider":             "the SCM provider API",
+	"pullRequest":             "the pull request API",
+	"clusterDecisionResource": "the cluster decision resources",
+	"plugin":                  "the plugin service",
+}
+
+// evaluator evaluates the generators of an ApplicationSet
+type evaluator struct {
+	opts       Options
+	goTemplate bool
+	// goOptions are the goTemplateOptions, like missingkey=error
+	goOptions []string
+	// dirs and files of the repository, listed on first use
+	dirs, files []string
+	listed      bool
+}
+
+// generate evaluates a generator list item, like {git: {...}}
+func (e *evaluator) generate(node *yaml.Node) ([]Params, error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: expected a generator", node.Line)
+	}
+
+	var params []Params
+	var filter *selector
+	found := ""
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name, value := node.Content[i].Value, node.Content[i+1]
+
+		var err error
+		switch name {
+		case "selector":
+			filter = &selector{}
+			if err := value.Decode(filter); err != nil {
+				return nil, fmt.Errorf("line %d: invalid selector: %w", value.Line, err)
+			}
+			continue
+		case "template":
+			// Generator templates are merged onto spec.template by Argo CD,
+			// they are not simulated
+			continue
+		case "list":
+			params, err = e.list(value)
+		case "git":
+			params, err = e.git(value)
+		case "clusters":
+			params, err = e.clusters(value)
+		case "matrix":
+			params, err = e.matrix(value)
+		case "merge":
+			params, err = e.merge(value)
+		default:
+			if needs, ok := offlineGenerators[name]; ok {
+				return nil, fmt.Errorf("line %d: the %s generator needs %s and cannot be evaluated offline", node.Content[i].Line, name, needs)
+			}
+			return nil, fmt.Errorf("line %d: unsupported generator %s", node.Content[i].Line, name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s generator: %w", name, err)
+		}
+		found = name
+	}
+	if found == "" {
+		return nil, fmt.Errorf("line %d: no generator found", node.Line)
+	}
+
+	if filter == nil {
+		return params, nil
+	}
+	var selected []Params
+	for _, p := range params {
+		ok, err := filter.matches(func(key string) (string, bool) { return e.lookup(p, key) })
+		if err != nil {
+			return nil, fmt.Errorf("%s generator selector: %w", found, err)
+		}
+		if ok {
+			selected = append(selected, p)
+		}
+	}
+	return selected, nil
+}
+
+func (e *evaluator) list(node *yaml.Node) ([]Params, error) {
+	var spec struct {
+		Elements     []map[string]interface{} `yaml:"elements"`
+		ElementsYaml string                   `yaml:"elementsYaml"`
+	}
+	if err := node.Decode(&spec); err != nil {
+		return nil, err
+	}
+	elements := spec.Elements
+	if spec.ElementsYaml != "" {
+		var extra []map[string]interface{}
+		if err := yaml.Unmarshal([]byte(spec.ElementsYaml), &extra); err != nil {
+			return nil, fmt.Errorf("invalid elementsYaml: %w", err)
+		}
+		elements = append(elements, extra...)
+	}
+
+	var params []Params
+	for _, element := range elements {
+		params = append(params, e.params(element))
+	}
+	return params, nil
+}
+
+// gitPath is a path pattern of the git generator
+type gitPath struct {
+	Path    string `yaml:"path"`
+	Exclude bool   `yaml:"exclude"`
+}
+
+func (e *evaluator) git(node *yaml.Node) ([]Params, error) {
+	var spec struct {
+		Directories     []gitPath         `yaml:"directories"`
+		Files           []gitPath         `yaml:"files"`
+		PathParamPrefix string            `yaml:"pathParamPrefix"`
+		Values          map[string]string `yaml:"values"`
+	}
+	if err := node.Decode(&spec); err != nil {
+		return nil, err
+	}
+	if err := e.listRepository(); err != nil {
+		return nil, err
+	}
+
+	var params []Params
+	switch {
+	case len(spec.Directories) > 0:
+		for _, dir := range e.dirs {
+			ok, err := matchAny(spec.Directories, dir, path.Match)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				params = append(params, e.pathParams(dir, "", spec.PathParamPrefix))
+			}
+		}
+	case len(spec.Files) > 0:
+		for _, file := range e.files {
+			ok, err := matchAny(spec.Files, file, matchGlob)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			fileParams, err := e.fileParams(file, spec.PathParamPrefix)
+			if err != nil {
+				return nil, err
+			}
+			params = append(params, fileParams...)
+		}
+	default:
+		return nil, fmt.Errorf("directories or files are required")
+	}
+
+	for _, p := range params {
+		e.addValues(p, spec.Values)
+	}
+	return params, nil
+}
+
+// matchAny reports whether name matches one of the included patterns and
+// none of the excluded ones
+func matchAny(patterns []gitPath, name string, match func(pattern, name string) (bool, error)) (bool, error) {
+	included := false
+	for _, pattern := range patterns {
+		ok, err := match(pattern.Path, name)
+		if err != nil {
+			return false, fmt.Errorf("invalid path %q: %w", pattern.Path, err)
+		}
+		if ok && pattern.Exclude {
+			return false, nil
+		}
+		included = included || ok
+	}
+	return included, nil
+}
+
+// matchGlob matches a git files pattern, where ** also matches slashes
+func matchGlob(pattern, name string) (bool, error) {
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+
+	matched, err := regexp.MatchString(re.String(), name)
+	return matched, err
+}
+
+// listRepository lists the directories and files of the repository,
+// relative to its root and with forward slashes
+func (e *evaluator) listRepository() error {
+	if e.listed {
+		return nil
+	}
+	e.listed = true
+
+	root := e.opts.RepoDir
+	return afero.Walk(e.opts.fs(), root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			e.dirs = append(e.dirs, rel)
+		} else {
+			e.files = append(e.files, rel)
+		}
+		return nil
+	})
+}
+
+// pathParams returns the path parameters of a directory, or of a file in
+// it
+func (e *evaluator) pathParams(dir, file, prefix string) Params {
+	segments := strings.Split(dir, "/")
+	base := path.Base(dir)
+
+	if e.goTemplate {
+		p := map[string]interface{}{
+			"path":               dir,
+			"basename":           base,
+			"basenameNormalized": normalize(base),
+			"segments":           segments,
+		}
+		if file != "" {
+			p["filename"] = file
+			p["filenameNormalized"] = normalize(file)
+		}
+		if prefix != "" {
+			return Params{prefix: map[string]interface{}{"path": p}}
+		}
+		return Params{"path": p}
+	}
+
+	if prefix != "" {
+		prefix += "."
+	}
+	p := Params{
+		prefix + "path":                    dir,
+		prefix + "path.basename":           base,
+		prefix + "path.basenameNormalized": normalize(base),
+	}
+	if file != "" {
+		p[prefix+"path.filename"] = file
+		p[prefix+"path.filenameNormalized"] = normalize(file)
+	}
+	for i, segment := range segments {
+		p[fmt.Sprintf("%spath[%d]", prefix, i)] = segment
+	}
+	return p
+}
+
+// fileParams returns the parameters of a git generator file: its content
+// and its path. A file holding a list produces one set per item.
+func (e *evaluator) fileParams(file, prefix string) ([]Params, error) {
+	data, err := afero.ReadFile(e.opts.fs(), filepath.Join(e.opts.RepoDir, filepath.FromSlash(file)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+
+	var content interface{}
+	if err := yaml.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+
+	var items []map[string]interface{}
+	switch content := content.(type) {
+	case nil:
+		items = []map[string]interface{}{{}}
+	case map[string]interface{}:
+		items = []map[string]interface{}{content}
+	case []interface{}:
+		for _, item := range content {
+			object, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: expected a list of objects", file)
+			}
+			items = append(items, object)
+		}
+	default:
+		return nil, fmt.Errorf("%s: expected an object or a list of objects", file)
+	}
+
+	var params []Params
+	for _, item := range items {
+		p := e.params(item)
+		for key, value := range e.pathParams(path.Dir(file), path.Base(file), prefix) {
+			p[key] = value
+		}
+		params = append(params, p)
+	}
+	return params, nil
+}
+
+func (e *evaluator) clusters(node *yaml.Node) ([]Params, error) {
+	var spec struct {
+		Selector selector          `yaml:"selector"`
+		Values   map[string]string `yaml:"values"`
+	}
+	if err := node.Decode(&spec); err != nil {
+		return nil, err
+	}
+
+	clusters := e.opts.Clusters
+	if clusters == nil {
+		clusters = DefaultInventory
+	}
+
+	var params []Params
+	for _, cluster := range clusters {
+		ok, err := spec.Selector.matches(func(key string) (string, bool) {
+			value, ok := cluster.Labels[key]
+			return value, ok
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		p := e.params(map[string]interface{}{
+			"name":           cluster.Name,
+			"nameNormalized": normalize(cluster.Name),
+			"server":         cluster.Server,
+			"metadata": map[string]interface{}{
+				"labels":      stringMap(cluster.Labels),
+				"annotations": stringMap(cluster.Annotations),
+			},
+		})
+		e.addValues(p, spec.Values)
+		params = append(params, p)
+	}
+	return params, nil
+}
+
+func (e *evaluator) matrix(node *yaml.Node) ([]Params, error) {
+	generators := children(node)
+	if len(generators) != 2 {
+		return nil, fmt.Errorf("exactly 2 child generators are required, got %d", len(generators))
+	}
+
+	first, err := e.generate(generators[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var params []Params
+	for _, a := range first {
+		// The second generator may use the parameters of the first, like a
+		// git generator reading files from {{path}}
+		child, err := e.render(generators[1], a)
+		if err != nil {
+			return nil, err
+		}
+		second, err := e.generate(child)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, b := range second {
+			combined := Params{}
+			for key, value := range a {
+				combined[key] = value
+			}
+			for key, value := range b {
+				if existing, ok := combined[key]; ok && !reflect.DeepEqual(existing, value) {
+					return nil, fmt.Errorf("found duplicate key %s with different values", key)
+				}
+				combined[key] = value
+			}
+			params = append(params, combined)
+		}
+	}
+	return params, nil
+}
+
+func (e *evaluator) merge(node *yaml.Node) ([]Params, error) {
+	var spec struct {
+		MergeKeys []string `yaml:"mergeKeys"`
+	}
+	if err := node.Decode(&spec); err != nil {
+		return nil, err
+	}
+	generators := children(node)
+	if len(generators) < 2 {
+		return nil, fmt.Errorf("at least 2 child generators are required, got %d", len(generators))
+	}
+	if len(spec.MergeKeys) == 0 {
+		return nil, fmt.Errorf("mergeKeys are required")
+	}
+
+	base, err := e.generate(generators[0])
+	if err != nil {
+		return nil, err
+	}
+	if _, err := e.byMergeKey(base, spec.MergeKeys); err != nil {
+		return nil, err
+	}
+
+	for _, generator := range generators[1:] {
+		params, err := e.generate(generator)
+		if err != nil {
+			return nil, err
+		}
+		overrides, err := e.byMergeKey(params, spec.MergeKeys)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, p := range base {
+			if override, ok := overrides[e.mergeKey(p, spec.MergeKeys)]; ok {
+				base[i] = mergeParams(p, override)
+			}
+		}
+	}
+	return base, nil
+}
+
+// children returns the child generators of matrix and merge
+func children(node *yaml.Node) []*yaml.Node {
+	return yamledit.Items(node, "generators")
+}
+
+// byMergeKey indexes parameter sets by their merge key values
+func (e *evaluator) byMergeKey(params []Params, keys []string) (map[string]Params, error) {
+	indexed := map[string]Params{}
+	for _, p := range params {
+		key := e.mergeKey(p, keys)
+		if _, exists := indexed[key]; exists {
+			return nil, fmt.Errorf("duplicate merge key %s", strings.ReplaceAll(key, "\x00", ", "))
+		}
+		indexed[key] = p
+	}
+	return indexed, nil
+}
+
+func (e *evaluator) mergeKey(p Params, keys []string) string {
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		values[i], _ = e.lookup(p, key)
+	}
+	return strings.Join(values, "\x00")
+}
+
+// mergeParams returns base with the values of override, merging nested
+// objects
+func mergeParams(base, override map[string]interface{}) Params {
+	merged := Params{}
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range override {
+		baseMap, baseIsMap := merged[key].(map[string]interface{})
+		overrideMap, overrideIsMap := value.(map[string]interface{})
+		if baseIsMap && overrideIsMap {
+			merged[key] = map[string]interface{}(mergeParams(baseMap, overrideMap))
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// params converts a generator object to parameters, flattening it for
+// fasttemplate
+func (e *evaluator) params(object map[string]interface{}) Params {
+	if e.goTemplate {
+		return Params(object)
+	}
+	flat := Params{}
+	flatten(flat, "", object)
+	return flat
+}
+
+// addValues adds the values of a generator as values.<key>
+func (e *evaluator) addValues(p Params, values map[string]string) {
+	if len(values) == 0 {
+		return
+	}
+	if e.goTemplate {
+		p["values"] = stringMap(values)
+		return
+	}
+	for key, value := range values {
+		p["values."+key] = value
+	}
+}
+
+// lookup returns a parameter as a string, following dots into nested
+// objects for goTemplate
+func (e *evaluator) lookup(p Params, key string) (string, bool) {
+	if !e.goTemplate {
+		value, ok := p[key]
+		if !ok {
+			return "", false
+		}
+		return fmt.Sprint(value), true
+	}
+
+	var value interface{} = map[string]interface{}(p)
+	for _, part := range strings.Split(key, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if value, ok = object[part]; !ok {
+			return "", false
+		}
+	}
+	return fmt.Sprint(value), true
+}
+
+// flatten adds the leaves of a value to flat with dotted keys, lists are
+// indexed by position
+func flatten(flat Params, prefix string, value interface{}) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			flatten(flat, join(key), value[key])
+		}
+	case []interface{}:
+		for i, item := range value {
+			flatten(flat, join(strconv.Itoa(i)), item)
+		}
+	case nil:
+		flat[prefix] = ""
+	default:
+		flat[prefix] = fmt.Sprint(value)
+	}
+}
+
+func stringMap(m map[string]string) map[string]interface{} {
+	converted := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		converted[key] = value
+	}
+	return converted
+}
+
+// invalidNameChars matches what Argo CD replaces when normalizing a name
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9.-]`)
+
+// normalize makes a name usable as a Kubernetes resource name, like the
+// Normalized parameters of Argo CD
+func normalize(name string) string {
+	normalized := invalidNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	if len(normalized) > 253 {
+		normalized = normalized[:253]
+	}
+	return strings.Trim(normalized, "-.")
+}
@@ -0,0 +1,109 @@
+package appset
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+// InClusterServer is the Kubernetes API server Argo CD runs in
+const InClusterServer = "https://kubernetes.default.svc"
+
+// Cluster is a cluster registered in Argo CD, as the clusters generator
+// sees it
+type Cluster struct {
+	Name        string            `yaml:"name"`
+	Server      string            `yaml:"server"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+// DefaultInventory is used when no inventory is given: a fresh Argo CD
+// only knows the cluster it runs in
+var DefaultInventory = []Cluster{{Name: "in-cluster", Server: InClusterServer}}
+
+// ReadInventory reads a cluster inventory file:
+//
+//	clusters:
+//	  - name: prod-eu
+//	    server: https://prod-eu.example.com
+//	    labels:
+//	      env: prod
+func ReadInventory(fsys afero.Fs, path string) ([]Cluster, error) {
+	data, err := afero.ReadFile(fsys, path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("cluster inventory %s does not exist", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var inventory struct {
+		Clusters []Cluster `yaml:"clusters"`
+	}
+	if err := yaml.Unmarshal(data, &inventory); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for i, cluster := range inventory.Clusters {
+		if cluster.Name == "" || cluster.Server == "" {
+			return nil, fmt.Errorf("%s: cluster %d needs a name and a server", path, i+1)
+		}
+	}
+	return inventory.Clusters, nil
+}
+
+// selector is a Kubernetes label selector
+type selector struct {
+	MatchLabels      map[string]string `yaml:"matchLabels"`
+	MatchExpressions []struct {
+		Key      string   `yaml:"key"`
+		Operator string   `yaml:"operator"`
+		Values   []string `yaml:"values"`
+	} `yaml:"matchExpressions"`
+}
+
+// matches reports whether labels are selected, using lookup to read them
+func (s selector) matches(lookup func(key string) (string, bool)) (bool, error) {
+	for key, value := range s.MatchLabels {
+		if actual, ok := lookup(key); !ok || actual != value {
+			return false, nil
+		}
+	}
+
+	for _, expression := range s.MatchExpressions {
+		actual, ok := lookup(expression.Key)
+		switch expression.Operator {
+		case "In":
+			if !ok || !contains(expression.Values, actual) {
+				return false, nil
+			}
+		case "NotIn":
+			if ok && contains(expression.Values, actual) {
+				return false, nil
+			}
+		case "Exists":
+			if !ok {
+				return false, nil
+			}
+		case "DoesNotExist":
+			if ok {
+				return false, nil
+			}
+		default:
+			return false, fmt.Errorf("unsupported selector operator %q", expression.Operator)
+		}
+	}
+	return true, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,176 @@
+// Package chart loads the Helm chart argo-helper scaffolds and renders it
+// without helm, so commands can check and inspect the manifests it produces
+// offline.
+package chart
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+// ValuesSchemaFile is the JSON schema of the chart values, next to
+// values.yaml
+const ValuesSchemaFile = "values.schema.json"
+
+// Metadata is the part of Chart.yaml available to templates as .Chart
+type Metadata struct {
+	Name        string `yaml:"name"`
+	Version     string `yaml:"version"`
+	AppVersion  string `yaml:"appVersion"`
+	Description string `yaml:"description"`
+	Type        string `yaml:"type"`
+}
+
+// File is a file of the chart
+type File struct {
+	// Name is the path relative to the chart root, with forward slashes
+	Name string
+	Data []byte
+}
+
+// Chart is a chart loaded from a repository
+type Chart struct {
+	// Root is the directory containing Chart.yaml
+	Root     string
+	Metadata Metadata
+	// Values are the defaults from values.yaml
+	Values map[string]interface{}
+	// Templates are the files below templates/, sorted by name
+	Templates []File
+
+	fs afero.Fs
+}
+
+// Load reads the chart in root
+func Load(fsys afero.Fs, root string) (*Chart, error) {
+	data, err := afero.ReadFile(fsys, filepath.Join(root, "Chart.yaml"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s has no Chart.yaml, run argo-helper init first", root)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Chart.yaml: %w", err)
+	}
+
+	c := &Chart{Root: root, fs: fsys}
+	if err := yaml.Unmarshal(data, &c.Metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse Chart.yaml: %w", err)
+	}
+
+	c.Values, err = ReadValues(fsys, filepath.Join(root, "values.yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	c.Templates, err = c.readFiles("templates", func(string) bool { return true })
+	if err != nil {
+		return nil, fmt.Errorf("failed to read templates: %w", err)
+	}
+
+	return c, nil
+}
+
+// Examples returns the YAML files below examples/, sorted by name. They
+// are not part of the chart templates but use the same values and helpers,
+// see RenderFile.
+func (c *Chart) Examples() ([]File, error) {
+	files, err := c.readFiles("examples", func(name string) bool {
+		ext := filepath.Ext(name)
+		return ext == ".yaml" || ext == ".yml"
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read examples: %w", err)
+	}
+	return files, nil
+}
+
+// readFiles returns the files below a directory of the chart accepted by
+// include. A missing directory has no files.
+func (c *Chart) readFiles(dir string, include func(name string) bool) ([]File, error) {
+	var files []File
+	root := filepath.Join(c.Root, dir)
+	if exists, err := afero.DirExists(c.fs, root); err != nil || !exists {
+		return nil, err
+	}
+	err := afero.Walk(c.fs, root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !include(path) {
+			return nil
+		}
+
+		data, err := afero.ReadFile(c.fs, path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(c.Root, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, File{Name: filepath.ToSlash(rel), Data: data})
+		return nil
+	})
+	return files, err
+}
+
+// EnvValuesPath returns the path of the values file of an environment
+func EnvValuesPath(root, env string) string {
+	return filepath.Join(root, "values", env, "values.yaml")
+}
+
+// ValuesFor returns the values the chart is rendered with for an
+// environment: values.yaml overlaid with values/<env>/values.yaml. An empty
+// environment returns the defaults.
+func (c *Chart) ValuesFor(env string) (map[string]interface{}, error) {
+	if env == "" {
+		return MergeValues(c.Values, nil), nil
+	}
+
+	path := EnvValuesPath(c.Root, env)
+	if exists, err := afero.Exists(c.fs, path); err != nil || !exists {
+		return nil, fmt.Errorf("unknown environment %q: %s does not exist", env, path)
+	}
+
+	values, err := ReadValues(c.fs, path)
+	if err != nil {
+		return nil, err
+	}
+	return MergeValues(c.Values, values), nil
+}
+
+// Environments returns the environments with a values/<env>/values.yaml
+// file, sorted by name
+func (c *Chart) Environments() ([]string, error) {
+	entries, err := afero.ReadDir(c.fs, filepath.Join(c.Root, "values"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read environments: %w", err)
+	}
+
+	var envs []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if exists, err := afero.Exists(c.fs, EnvValuesPath(c.Root, entry.Name())); err == nil && exists {
+			envs = append(envs, entry.Name())
+		}
+	}
+	return envs, nil
+}
+
+// isPartial reports whether a template only defines named templates and
+// produces no manifest, like _helpers.tpl
+func isPartial(name string) bool {
+	base := filepath.Base(name)
+	return strings.HasPrefix(base, "_") || strings.EqualFold(base, "NOTES.txt")
+}
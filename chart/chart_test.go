@@ -0,0 +1,340 @@
+package chart
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+
+	"github.com/rebelopsio/argo-helper/scaffold"
+)
+
+// scaffoldChart writes a scaffold with examples to an in-memory filesystem
+// and loads it
+func scaffoldChart(t *testing.T) (afero.Fs, *Chart) {
+	t.Helper()
+
+	fsys := afero.NewMemMapFs()
+	write := scaffold.WriteOptions{Fs: fsys}
+	if _, err := scaffold.Init(context.Background(), scaffold.InitOptions{Path: "/repo", Project: "demo", Examples: true, WriteOptions: write}); err != nil {
+		t.Fatalf("Failed to scaffold: %v", err)
+	}
+	for _, generator := range scaffold.Generators {
+		if _, err := scaffold.New(context.Background(), scaffold.NewOptions{
+			Type:           scaffold.TypeApplicationSet,
+			Name:           generator,
+			OutputPath:     "/repo/templates/apps",
+			ApplicationSet: scaffold.ApplicationSetOptions{Generator: generator},
+			WriteOptions:   write,
+		}); err != nil {
+			t.Fatalf("Failed to create %s ApplicationSet: %v", generator, err)
+		}
+	}
+	if _, err := scaffold.New(context.Background(), scaffold.NewOptions{
+		Type:         scaffold.TypeAppProject,
+		Name:         "team",
+		OutputPath:   "/repo/templates/projects",
+		ValuesFile:   "/repo/values.yaml",
+		WriteOptions: write,
+	}); err != nil {
+		t.Fatalf("Failed to create AppProject: %v", err)
+	}
+
+	// The scaffold leaves the repository URL for the user to fill in
+	values, err := afero.ReadFile(fsys, "/repo/values.yaml")
+	if err != nil {
+		t.Fatalf("Failed to read values: %v", err)
+	}
+	values = []byte(strings.Replace(string(values), `repoURL: ""`, `repoURL: https://example.com/repo.git`, 1))
+	if err := afero.WriteFile(fsys, "/repo/values.yaml", values, 0644); err != nil {
+		t.Fatalf("Failed to write values: %v", err)
+	}
+
+	c, err := Load(fsys, "/repo")
+	if err != nil {
+		t.Fatalf("Failed to load chart: %v", err)
+	}
+	return fsys, c
+}
+
+func TestMergeValues(t *testing.T) {
+	base := map[string]interface{}{
+		"global": map[string]interface{}{"environment": "dev", "project": "demo"},
+		"list":   []interface{}{"a", "b"},
+	}
+	override := map[string]interface{}{
+		"global": map[string]interface{}{"environment": "prod", "project": nil},
+		"list":   []interface{}{"c"},
+		"extra":  nil,
+	}
+
+	merged := MergeValues(base, override)
+	expected := map[string]interface{}{
+		"global": map[string]interface{}{"environment": "prod"},
+		"list":   []interface{}{"c"},
+	}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("Expected %v, got %v", expected, merged)
+	}
+	if base["global"].(map[string]interface{})["environment"] != "dev" {
+		t.Errorf("Expected base values to be left unchanged")
+	}
+}
+
+func TestRender(t *testing.T) {
+	c := &Chart{
+		Metadata: Metadata{Name: "demo"},
+		Templates: []File{
+			{Name: "templates/_helpers.tpl", Data: []byte(`{{- define "name" -}}{{ printf "%s-%s" .Values.prefix .Chart.Name | trunc 7 | trimSuffix "-" }}{{- end -}}`)},
+			{Name: "templates/app.yaml", Data: []byte(`name: {{ include "name" . }}
+server: {{ .Values.server | default "https://kubernetes.default.svc" }}
+release: {{ .Release.Name }}
+missing: {{ .Values.missing }}
+syncPolicy:
+  {{- toYaml .Values.syncPolicy | nindent 2 }}
+`)},
+			{Name: "templates/empty.yaml", Data: []byte(`{{- if .Values.missing }}kind: Never{{ end }}`)},
+		},
+	}
+	values := map[string]interface{}{
+		"prefix":     "argocd",
+		"syncPolicy": map[string]interface{}{"syncOptions": []interface{}{"CreateNamespace=true"}},
+	}
+
+	manifests, err := c.Render(values, RenderOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(manifests) != 1 {
+		t.Fatalf("Expected only templates/app.yaml to render, got %v", manifests)
+	}
+
+	expected := `name: argocd
+server: https://kubernetes.default.svc
+release: demo
+missing: 
+syncPolicy:
+  syncOptions:
+    - CreateNamespace=true
+`
+	if manifests[0].Content != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, manifests[0].Content)
+	}
+}
+
+func TestRenderFuncs(t *testing.T) {
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{`{{ .Values.name | quote }}`, `"web"`},
+		{`{{ .Values.name | squote }}`, `'web'`},
+		{`{{ .Values.empty | default "fallback" }}`, `fallback`},
+		{`{{ coalesce .Values.empty .Values.name }}`, `web`},
+		{`{{ "argocd-web-" | trunc 11 | trimSuffix "-" }}`, `argocd-web`},
+		{`{{ "argocd-web" | trunc -3 }}`, `web`},
+		{`{{ "prefix-web" | trimPrefix "prefix-" | upper }}`, `WEB`},
+		{`{{ tpl "{{ .Values.name }}-tpl" . }}`, `web-tpl`},
+		{`{{ toJson .Values.labels }}`, `{"team":"a"}`},
+		{`{{ toYaml .Values.labels | indent 2 }}`, `  team: a`},
+		{`{{ hasKey .Values.labels "team" }}`, `true`},
+		{`{{ ternary "yes" "no" (empty .Values.empty) }}`, `yes`},
+		{`{{ (dict "a" 1).a }} {{ len (list 1 2) }}`, `1 2`},
+		{`{{ dig "team" "x" .Values.labels }} {{ dig "team" "name" "x" .Values.labels }}`, `a x`},
+		{`{{ required "name is required" .Values.name }}`, `web`},
+	}
+
+	for _, tt := range tests {
+		c := &Chart{Templates: []File{{Name: "templates/test.yaml", Data: []byte(tt.template)}}}
+		manifests, err := c.Render(map[string]interface{}{
+			"name":   "web",
+			"empty":  "",
+			"labels": map[string]interface{}{"team": "a"},
+		}, RenderOptions{})
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %v", tt.template, err)
+			continue
+		}
+		if len(manifests) != 1 || manifests[0].Content != tt.expected {
+			t.Errorf("Expected %s to render %q, got %v", tt.template, tt.expected, manifests)
+		}
+	}
+
+	c := &Chart{Templates: []File{{Name: "templates/test.yaml", Data: []byte(`{{ required "name is required" .Values.name }}`)}}}
+	if _, err := c.Render(map[string]interface{}{}, RenderOptions{}); err == nil || !strings.Contains(err.Error(), "name is required") {
+		t.Errorf("Expected the required message, got %v", err)
+	}
+}
+
+func TestRenderFile(t *testing.T) {
+	fsys, c := scaffoldChart(t)
+
+	data, err := afero.ReadFile(fsys, "/repo/examples/applicationset.yaml")
+	if err != nil {
+		t.Fatalf("Failed to read example: %v", err)
+	}
+	manifest, err := c.RenderFile(File{Name: "examples/applicationset.yaml", Data: data}, c.Values, RenderOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Chart helpers are available and ApplicationSet parameters are kept
+	for _, want := range []string{"name: demo-apps", "name: '{{path.basename}}'"} {
+		if !strings.Contains(manifest.Content, want) {
+			t.Errorf("Expected %q in the rendered example:\n%s", want, manifest.Content)
+		}
+	}
+
+	if _, err := c.RenderFile(File{Name: "broken.yaml", Data: []byte("{{ include \"missing\" . }}")}, c.Values, RenderOptions{}); err == nil {
+		t.Errorf("Expected error for an undefined template")
+	}
+}
+
+func TestRenderReportsTemplateErrors(t *testing.T) {
+	c := &Chart{
+		Templates: []File{
+			{Name: "templates/nil.yaml", Data: []byte("a: 1\nserver: {{ .Values.destination.server }}\n")},
+			{Name: "templates/parse.yaml", Data: []byte("{{ if }}\n")},
+			{Name: "templates/ok.yaml", Data: []byte("a: 1\n")},
+		},
+	}
+
+	manifests, err := c.Render(map[string]interface{}{}, RenderOptions{})
+	errs, ok := err.(TemplateErrors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("Expected two template errors, got %v", err)
+	}
+	if len(manifests) != 1 || manifests[0].Name != "templates/ok.yaml" {
+		t.Errorf("Expected the valid template to render, got %v", manifests)
+	}
+
+	if errs[0].Template != "templates/parse.yaml" || errs[0].Line != 1 {
+		t.Errorf("Expected a parse error at templates/parse.yaml:1, got %v", errs[0])
+	}
+	if errs[1].Template != "templates/nil.yaml" || errs[1].Line != 2 || !strings.Contains(errs[1].Message, ".Values.destination.server") {
+		t.Errorf("Expected a nil pointer error at templates/nil.yaml:2, got %v", errs[1])
+	}
+}
+
+func TestValidateScaffold(t *testing.T) {
+	_, c := scaffoldChart(t)
+
+	for _, env := range []string{"", "dev", "prod"} {
+		values, err := c.ValuesFor(env)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", env, err)
+		}
+		if problems := c.Validate(values, RenderOptions{}); len(problems) > 0 {
+			t.Errorf("Expected the scaffold to be valid for %q, got %v", env, problems)
+		}
+	}
+
+	if _, err := c.ValuesFor("staging"); err == nil {
+		t.Errorf("Expected error for an unknown environment")
+	}
+}
+
+func TestValidateValues(t *testing.T) {
+	fsys, c := scaffoldChart(t)
+
+	// The scaffold values, including the project added by new, match the
+	// schema init writes
+	if problems := c.ValidateValues(); len(problems) > 0 {
+		t.Errorf("Expected the scaffold values to be valid, got %v", problems)
+	}
+
+	prod := `global:
+  targetRevison: v1.0.0
+projects:
+  team:
+    sourceRepos: https://example.com/repo.git
+  other: {}
+`
+	if err := afero.WriteFile(fsys, "/repo/values/prod/values.yaml", []byte(prod), 0644); err != nil {
+		t.Fatalf("Failed to write values: %v", err)
+	}
+
+	var reported []string
+	for _, problem := range c.ValidateValues() {
+		reported = append(reported, problem.String())
+	}
+	expected := []string{
+		"values/prod/values.yaml:2: global.targetRevison: unknown field",
+		"values/prod/values.yaml:5: projects.team.sourceRepos: expected array, got string",
+		"values/prod/values.yaml:6: projects.other: unknown field",
+	}
+	if !reflect.DeepEqual(reported, expected) {
+		t.Errorf("Expected problems:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(reported, "\n"))
+	}
+}
+
+func TestValidateReportsProblems(t *testing.T) {
+	fsys, _ := scaffoldChart(t)
+
+	templates := map[string]string{
+		"/repo/templates/apps/typo.yaml": `{{- /*
+An Application with a typo, the problem is reported on the template line
+*/ -}}
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: typo
+spec:
+  project: demo
+  source:
+    repoURL: {{ .Values.global.repoURL }}
+    targetRevison: HEAD
+  destination:
+    server: {{ .Values.destination.server }}
+`,
+		"/repo/templates/apps/invalid.yaml": "kind: Application\nspec: [\n",
+		"/repo/templates/apps/nil.yaml":     "{{ .Values.cluster.name }}\n",
+		"/repo/templates/apps/unset.yaml": `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: unset
+spec:
+  project: demo
+  source:
+    repoURL: {{ .Values.unset.repoURL }}
+    targetRevision: HEAD
+  destination:
+    server: {{ .Values.destination.server }}
+`,
+		// NUL bytes would be taken for the line markers of the output
+		"/repo/templates/apps/nul.yaml": "kind: Application\nname: \x00\n",
+		// The unset value is reported in the values file of the environment
+		"/repo/values/dev/values.yaml": "unset:\n  repoURL: \"\"\n",
+	}
+	for path, content := range templates {
+		if err := afero.WriteFile(fsys, path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	c, err := Load(fsys, "/repo")
+	if err != nil {
+		t.Fatalf("Failed to load chart: %v", err)
+	}
+	values, err := c.ValuesFor("dev")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var reported []string
+	for _, problem := range c.Validate(values, RenderOptions{Env: "dev"}) {
+		reported = append(reported, problem.String())
+	}
+
+	expected := []string{
+		"templates/apps/invalid.yaml:2: invalid YAML: did not find expected node content",
+		"templates/apps/nil.yaml:1: at <.Values.cluster.name>: nil pointer evaluating interface {}.name",
+		"templates/apps/nul.yaml:2: contains a NUL byte",
+		"templates/apps/typo.yaml:12: Application typo: spec.source.targetRevison: unknown field",
+		"values/dev/values.yaml:2: Application unset: spec.source.repoURL: required field is missing, set by unset.repoURL",
+	}
+	if !reflect.DeepEqual(reported, expected) {
+		t.Errorf("Expected problems:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(reported, "\n"))
+	}
+}
@@ -0,0 +1,217 @@
+package chart
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// funcMap returns the subset of the Helm template functions argo-helper
+// charts use, with Helm's argument order so values can be piped in. include
+// and tpl execute templates of t.
+func funcMap(t *template.Template) template.FuncMap {
+	return template.FuncMap{
+		// Templates
+		"include": func(name string, data interface{}) (string, error) {
+			var buf bytes.Buffer
+			if err := t.ExecuteTemplate(&buf, name, data); err != nil {
+				return "", err
+			}
+			return buf.String(), nil
+		},
+		"tpl": func(text string, data interface{}) (string, error) {
+			clone, err := t.Clone()
+			if err != nil {
+				return "", err
+			}
+			tpl, err := clone.New("tpl").Parse(text)
+			if err != nil {
+				return "", err
+			}
+			var buf bytes.Buffer
+			if err := tpl.Execute(&buf, data); err != nil {
+				return "", err
+			}
+			return strings.ReplaceAll(buf.String(), "<no value>", ""), nil
+		},
+		"required": func(message string, value interface{}) (interface{}, error) {
+			if empty(value) {
+				return nil, errors.New(message)
+			}
+			return value, nil
+		},
+
+		// Values
+		"default":  defaultValue,
+		"empty":    empty,
+		"coalesce": coalesce,
+		"ternary": func(yes, no interface{}, condition bool) interface{} {
+			if condition {
+				return yes
+			}
+			return no
+		},
+		"hasKey": func(m map[string]interface{}, key string) bool {
+			_, ok := m[key]
+			return ok
+		},
+		"dict": dict,
+		"dig":  dig,
+		"list": func(items ...interface{}) []interface{} { return items },
+
+		// Encoding
+		"toYaml": toYaml,
+		"toJson": toJSON,
+
+		// Strings
+		"indent":     indent,
+		"nindent":    func(spaces int, s string) string { return "\n" + indent(spaces, s) },
+		"trunc":      trunc,
+		"trim":       strings.TrimSpace,
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+		"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"lower":      strings.ToLower,
+		"upper":      strings.ToUpper,
+		"quote":      func(value interface{}) string { return fmt.Sprintf("%q", toString(value)) },
+		"squote":     func(value interface{}) string { return "'" + toString(value) + "'" },
+		"toString":   toString,
+	}
+}
+
+// defaultValue returns value, or def if value is empty. It is called as
+// value | default def.
+func defaultValue(def interface{}, value ...interface{}) interface{} {
+	if len(value) == 0 || empty(value[0]) {
+		return def
+	}
+	return value[0]
+}
+
+// coalesce returns the first value that is not empty
+func coalesce(values ...interface{}) interface{} {
+	for _, value := range values {
+		if !empty(value) {
+			return value
+		}
+	}
+	return nil
+}
+
+// dict builds a map from alternating keys and values
+func dict(pairs ...interface{}) map[string]interface{} {
+	m := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		m[toString(pairs[i])] = pairs[i+1]
+	}
+	return m
+}
+
+// dig returns the value at a path of keys in nested maps, or def. It is
+// called as dig "key" "key" def map, the last two arguments being the
+// default and the map.
+func dig(args ...interface{}) (interface{}, error) {
+	if len(args) < 3 {
+		return nil, errors.New("dig needs at least one key, a default and a map")
+	}
+	keys, def := args[:len(args)-2], args[len(args)-2]
+
+	value := args[len(args)-1]
+	for _, key := range keys {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return def, nil
+		}
+		if value, ok = m[toString(key)]; !ok {
+			return def, nil
+		}
+	}
+	return value, nil
+}
+
+// empty reports whether a value is unset, false, zero or has no elements
+func empty(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
+
+// toYaml marshals a value to YAML without the trailing newline
+func toYaml(value interface{}) string {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(value); err != nil {
+		return ""
+	}
+	if err := enc.Close(); err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// toJSON marshals a value to JSON
+func toJSON(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// indent prefixes every line of s with spaces
+func indent(spaces int, s string) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+// trunc shortens s to length characters, negative lengths keep the end
+func trunc(length int, s string) string {
+	switch {
+	case length < 0 && len(s)+length > 0:
+		return s[len(s)+length:]
+	case length >= 0 && len(s) > length:
+		return s[:length]
+	default:
+		return s
+	}
+}
+
+// toString formats a value the way templates print it
+func toString(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,207 @@
+package chart
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// RenderOptions describes the release a chart is rendered for
+type RenderOptions struct {
+	// ReleaseName is .Release.Name, defaults to the chart name
+	ReleaseName string
+	// Namespace is .Release.Namespace, defaults to default
+	Namespace string
+	// Env is the environment the values were read for, problems set by its
+	// values file are reported there
+	Env string
+}
+
+// Manifest is a rendered template
+type Manifest struct {
+	// Name is the template the manifest was rendered from
+	Name    string
+	Content string
+
+	// source is the template and lines the template line of every line of
+	// the content, see Locate
+	source string
+	lines  []int
+}
+
+// TemplateError is a template that failed to parse or render
+type TemplateError struct {
+	Template string
+	// Line and Column locate the error in the template, zero if unknown
+	Line, Column int
+	Message      string
+}
+
+func (e *TemplateError) Error() string {
+	switch {
+	case e.Line == 0:
+		return fmt.Sprintf("%s: %s", e.Template, e.Message)
+	case e.Column == 0:
+		return fmt.Sprintf("%s:%d: %s", e.Template, e.Line, e.Message)
+	default:
+		return fmt.Sprintf("%s:%d:%d: %s", e.Template, e.Line, e.Column, e.Message)
+	}
+}
+
+// TemplateErrors are all templates of a chart that failed to render
+type TemplateErrors []*TemplateError
+
+func (e TemplateErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Render renders every template of the chart with values, the way helm
+// template does. Templates that fail to render are left out of the
+// manifests and returned as TemplateErrors, so one broken template does not
+// hide the others. Templates rendering to nothing are skipped.
+func (c *Chart) Render(values map[string]interface{}, opts RenderOptions) ([]Manifest, error) {
+	opts = c.withDefaults(opts)
+	t, parsed, errs := c.parse()
+
+	var manifests []Manifest
+	for _, file := range c.Templates {
+		if !parsed[file.Name] || isPartial(file.Name) {
+			continue
+		}
+
+		content, lines, err := execute(t, file.Name, c.templateData(file.Name, values, opts))
+		if err != nil {
+			errs = append(errs, templateError(file.Name, err))
+			continue
+		}
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+		manifests = append(manifests, Manifest{Name: file.Name, Content: content, source: string(file.Data), lines: lines})
+	}
+
+	if len(errs) > 0 {
+		return manifests, errs
+	}
+	return manifests, nil
+}
+
+// RenderFile renders a file that is not one of the chart templates, like
+// the examples, with the named templates of the chart available to it.
+// Errors in the chart templates are ignored unless the file uses them.
+func (c *Chart) RenderFile(file File, values map[string]interface{}, opts RenderOptions) (Manifest, error) {
+	opts = c.withDefaults(opts)
+	t, _, _ := c.parse()
+
+	if err := checkSource(file.Name, file.Data); err != nil {
+		return Manifest{}, TemplateErrors{err}
+	}
+	parsed, err := t.New(file.Name).Parse(string(file.Data))
+	if err != nil {
+		return Manifest{}, TemplateErrors{templateError(file.Name, err)}
+	}
+	markLines(parsed.Tree, string(file.Data))
+	content, lines, err := execute(t, file.Name, c.templateData(file.Name, values, opts))
+	if err != nil {
+		return Manifest{}, TemplateErrors{templateError(file.Name, err)}
+	}
+	return Manifest{Name: file.Name, Content: content, source: string(file.Data), lines: lines}, nil
+}
+
+func (c *Chart) withDefaults(opts RenderOptions) RenderOptions {
+	if opts.ReleaseName == "" {
+		opts.ReleaseName = c.Metadata.Name
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = "default"
+	}
+	return opts
+}
+
+// parse parses the chart templates into one set, so they can include each
+// other's named templates, and returns the names of those that parsed.
+// Templates other than partials are marked with their lines.
+func (c *Chart) parse() (*template.Template, map[string]bool, TemplateErrors) {
+	// Helm treats missing values as empty rather than failing
+	t := template.New(c.Metadata.Name).Option("missingkey=zero")
+	t.Funcs(funcMap(t))
+
+	var errs TemplateErrors
+	parsed := map[string]bool{}
+	for _, file := range c.Templates {
+		if err := checkSource(file.Name, file.Data); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		tmpl, err := t.New(file.Name).Parse(string(file.Data))
+		if err != nil {
+			errs = append(errs, templateError(file.Name, err))
+			continue
+		}
+		if !isPartial(file.Name) {
+			markLines(tmpl.Tree, string(file.Data))
+		}
+		parsed[file.Name] = true
+	}
+	return t, parsed, errs
+}
+
+// execute executes a named template of the set and returns its output
+// with the template line of every output line
+func execute(t *template.Template, name string, data map[string]interface{}) (string, []int, error) {
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", nil, err
+	}
+	out, lines := unmark(buf.String())
+	return strings.ReplaceAll(out, "<no value>", ""), lines, nil
+}
+
+// templateData returns the top-level object templates are executed with
+func (c *Chart) templateData(name string, values map[string]interface{}, opts RenderOptions) map[string]interface{} {
+	return map[string]interface{}{
+		"Values": values,
+		"Chart": map[string]interface{}{
+			"Name":        c.Metadata.Name,
+			"Version":     c.Metadata.Version,
+			"AppVersion":  c.Metadata.AppVersion,
+			"Description": c.Metadata.Description,
+			"Type":        c.Metadata.Type,
+		},
+		"Release": map[string]interface{}{
+			"Name":      opts.ReleaseName,
+			"Namespace": opts.Namespace,
+			"Service":   "Helm",
+			"IsInstall": true,
+			"IsUpgrade": false,
+			"Revision":  1,
+		},
+		"Template": map[string]interface{}{
+			"Name":     name,
+			"BasePath": "templates",
+		},
+	}
+}
+
+// templateErrorPattern matches the location text/template puts in front of
+// its errors
+var templateErrorPattern = regexp.MustCompile(`(?s)^template: ([^:]+):(\d+)(?::(\d+))?: (?:executing "[^"]*" )?(.*)$`)
+
+// templateError converts a text/template error to a TemplateError
+func templateError(name string, err error) *TemplateError {
+	match := templateErrorPattern.FindStringSubmatch(err.Error())
+	if match == nil || match[1] != name {
+		return &TemplateError{Template: name, Message: err.Error()}
+	}
+
+	line, _ := strconv.Atoi(match[2])
+	column, _ := strconv.Atoi(match[3])
+	return &TemplateError{Template: name, Line: line, Column: column, Message: match[4]}
+}
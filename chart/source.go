@@ -0,0 +1,203 @@
+package chart
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template/parse"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rebelopsio/argo-helper/yamledit"
+)
+
+// lineMarker surrounds the template line numbers written into the output
+// while rendering. Templates are parsed with their lines marked, see
+// markLines, and every output is passed through unmark, which removes the
+// markers again. Template sources containing the marker are rejected by
+// checkSource, so every marker in the output comes from markLines.
+const lineMarker = '\x00'
+
+// checkSource rejects a template source containing lineMarker, which would
+// be taken for a line marker once rendered
+func checkSource(name string, data []byte) *TemplateError {
+	i := bytes.IndexByte(data, lineMarker)
+	if i < 0 {
+		return nil
+	}
+	return &TemplateError{Template: name, Line: bytes.Count(data[:i], []byte("\n")) + 1, Message: "contains a NUL byte"}
+}
+
+// markLines rewrites the parse tree of a template in place, so executing it
+// writes the line of the template it is at before every node and after
+// every newline of its text. Each rendered line can then be traced back to
+// the template line that produced it. Lines written by actions, like an
+// include spanning several lines, belong to the line of the action. The
+// output of a marked tree must be passed through unmark.
+func markLines(tree *parse.Tree, source string) {
+	if tree == nil || tree.Root == nil {
+		return
+	}
+	lineAt := func(pos parse.Pos) int {
+		if int(pos) > len(source) {
+			return 0
+		}
+		return strings.Count(source[:pos], "\n") + 1
+	}
+
+	var mark func(list *parse.ListNode)
+	mark = func(list *parse.ListNode) {
+		if list == nil {
+			return
+		}
+		nodes := make([]parse.Node, 0, 2*len(list.Nodes))
+		for _, node := range list.Nodes {
+			switch n := node.(type) {
+			case *parse.TextNode:
+				n.Text = markText(n.Text, lineAt(n.Pos))
+				nodes = append(nodes, n)
+				continue
+			case *parse.IfNode:
+				mark(n.List)
+				mark(n.ElseList)
+			case *parse.RangeNode:
+				mark(n.List)
+				mark(n.ElseList)
+			case *parse.WithNode:
+				mark(n.List)
+				mark(n.ElseList)
+			}
+			nodes = append(nodes, &parse.TextNode{NodeType: parse.NodeText, Pos: node.Position(), Text: marker(lineAt(node.Position()))}, node)
+		}
+		list.Nodes = nodes
+	}
+	mark(tree.Root)
+}
+
+// markText marks the lines of a text starting at a template line
+func markText(text []byte, line int) []byte {
+	marked := marker(line)
+	for _, c := range text {
+		marked = append(marked, c)
+		if c == '\n' {
+			line++
+			marked = append(marked, marker(line)...)
+		}
+	}
+	return marked
+}
+
+func marker(line int) []byte {
+	return []byte(string(lineMarker) + strconv.Itoa(line) + string(lineMarker))
+}
+
+// unmark removes the line markers from rendered output and returns the
+// template line of every output line, zero where unknown
+func unmark(out string) (string, []int) {
+	var b strings.Builder
+	var lines []int
+	current, lineStart := 0, true
+	for i := 0; i < len(out); i++ {
+		if out[i] == lineMarker {
+			if end := strings.IndexByte(out[i+1:], lineMarker); end >= 0 {
+				if n, err := strconv.Atoi(out[i+1 : i+1+end]); err == nil {
+					current = n
+					i += end + 1
+					continue
+				}
+			}
+		}
+		if lineStart {
+			lines = append(lines, current)
+			lineStart = false
+		}
+		b.WriteByte(out[i])
+		if out[i] == '\n' {
+			lineStart = true
+		}
+	}
+	return b.String(), lines
+}
+
+// SourceLine returns the line of the template that rendered a line of the
+// manifest, zero if unknown
+func (m Manifest) SourceLine(line int) int {
+	if line < 1 || line > len(m.lines) {
+		return 0
+	}
+	return m.lines[line-1]
+}
+
+// Location is a position in a file of the chart
+type Location struct {
+	// File is relative to the chart root, with forward slashes
+	File string
+	// Line and Column are zero when unknown
+	Line, Column int
+	// Value is the path of the value the location sets, like
+	// global.repoURL, empty for template locations
+	Value string
+}
+
+// valuesRefPattern matches the values a template line uses
+var valuesRefPattern = regexp.MustCompile(`\.Values((?:\.[A-Za-z_][A-Za-z0-9_]*)+)`)
+
+// Locate returns where a field of a manifest rendered with the values of
+// env is set. line is the line of the field in the manifest and path its
+// keys in the resource, sequence items by index. When the template line
+// rendering the field uses a single value, like toYaml of a values
+// mapping, the field is located in the values file of env or in
+// values.yaml. Otherwise it is located on the template line, without a
+// column.
+func (c *Chart) Locate(m Manifest, env string, line int, path []string) Location {
+	loc := Location{File: m.Name, Line: m.SourceLine(line)}
+	if loc.Line == 0 {
+		return loc
+	}
+
+	sourceLines := strings.Split(m.source, "\n")
+	if loc.Line > len(sourceLines) {
+		return loc
+	}
+	refs := valuesRefPattern.FindAllStringSubmatch(sourceLines[loc.Line-1], -1)
+	if len(refs) != 1 {
+		return loc
+	}
+	ref := strings.Split(strings.TrimPrefix(refs[0][1], "."), ".")
+
+	files := []string{"values.yaml"}
+	if env != "" {
+		files = []string{filepath.ToSlash(filepath.Join("values", env, "values.yaml")), "values.yaml"}
+	}
+	for _, file := range files {
+		data, err := afero.ReadFile(c.fs, filepath.Join(c.Root, file))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		var doc yaml.Node
+		if err != nil || yaml.Unmarshal(data, &doc) != nil {
+			return loc
+		}
+		value := yamledit.Lookup(&doc, ref...)
+		if value == nil {
+			continue
+		}
+
+		// Fields rendered from a values mapping are looked up below it,
+		// by the longest end of their path it has
+		valuePath := ref
+		for i := 0; i < len(path) && value.Kind != yaml.ScalarNode; i++ {
+			if field := yamledit.Lookup(value, path[i:]...); field != nil {
+				value = field
+				valuePath = append(append([]string{}, ref...), path[i:]...)
+			}
+		}
+		return Location{File: file, Line: value.Line, Column: value.Column, Value: strings.Join(valuePath, ".")}
+	}
+	return loc
+}
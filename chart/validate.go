@@ -0,0 +1,235 @@
+package chart
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rebelopsio/argo-helper/schema"
+	"github.com/rebelopsio/argo-helper/yamledit"
+)
+
+// Problem is an error found in a chart
+type Problem struct {
+	// File is the template or values file the problem was found in
+	File string
+	// Line and Column locate the problem in File, zero if unknown
+	Line, Column int
+	Message      string
+}
+
+func (p Problem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", p.File, p.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message)
+}
+
+// Documents parses the YAML documents of a manifest, skipping empty ones
+func (m Manifest) Documents() ([]*yaml.Node, error) {
+	dec := yaml.NewDecoder(strings.NewReader(m.Content))
+
+	var docs []*yaml.Node
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return docs, err
+		}
+		if len(doc.Content) > 0 && !isNull(doc.Content[0]) {
+			docs = append(docs, &doc)
+		}
+	}
+}
+
+// Validate renders the chart with values and checks that every manifest is
+// valid YAML made of Kubernetes resources, and that every Argo CD resource
+// matches its CRD schema. It returns the problems sorted by file and line.
+func (c *Chart) Validate(values map[string]interface{}, opts RenderOptions) []Problem {
+	var problems []Problem
+
+	manifests, err := c.Render(values, opts)
+	var templateErrs TemplateErrors
+	if errors.As(err, &templateErrs) {
+		for _, e := range templateErrs {
+			problems = append(problems, Problem{File: e.Template, Line: e.Line, Column: e.Column, Message: e.Message})
+		}
+	}
+
+	for _, manifest := range manifests {
+		docs, err := manifest.Documents()
+		if err != nil {
+			problem := yamlProblem(manifest.Name, err)
+			problem.Line = manifest.SourceLine(problem.Line)
+			problems = append(problems, problem)
+		}
+
+		for _, doc := range docs {
+			problems = append(problems, c.validateResource(manifest, opts.Env, doc.Content[0])...)
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].File != problems[j].File {
+			return problems[i].File < problems[j].File
+		}
+		return problems[i].Line < problems[j].Line
+	})
+	return problems
+}
+
+// ValidateValues checks values.yaml and the values file of every
+// environment against values.schema.json. Charts without a schema have no
+// problems. Environment values files are overlaid onto values.yaml, so
+// they may leave out required fields.
+func (c *Chart) ValidateValues() []Problem {
+	data, err := afero.ReadFile(c.fs, filepath.Join(c.Root, ValuesSchemaFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return []Problem{{File: ValuesSchemaFile, Message: err.Error()}}
+	}
+	s, err := schema.ParseValuesSchema(data)
+	if err != nil {
+		return []Problem{{File: ValuesSchemaFile, Message: err.Error()}}
+	}
+
+	problems := c.validateValuesFile(s, "values.yaml", false)
+
+	envs, err := c.Environments()
+	if err != nil {
+		return append(problems, Problem{File: "values", Message: err.Error()})
+	}
+	for _, env := range envs {
+		problems = append(problems, c.validateValuesFile(s, filepath.ToSlash(filepath.Join("values", env, "values.yaml")), true)...)
+	}
+	return problems
+}
+
+// validateValuesFile checks a values file, named relative to the chart
+// root, against the values schema
+func (c *Chart) validateValuesFile(s *schema.Schema, name string, overlay bool) []Problem {
+	data, err := afero.ReadFile(c.fs, filepath.Join(c.Root, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return []Problem{{File: name, Message: err.Error()}}
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return []Problem{yamlProblem(name, err)}
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	errs := s.Validate
+	if overlay {
+		errs = s.ValidateOverlay
+	}
+
+	var problems []Problem
+	for _, err := range errs(doc.Content[0]) {
+		problems = append(problems, Problem{File: name, Line: err.Line, Column: err.Column, Message: err.Error()})
+	}
+	return problems
+}
+
+// validateResource checks a resource rendered from a manifest. Problems
+// are located on the template line that rendered them, or in the values
+// file that set them.
+func (c *Chart) validateResource(m Manifest, env string, resource *yaml.Node) []Problem {
+	if resource.Kind != yaml.MappingNode {
+		return []Problem{{File: m.Name, Line: m.SourceLine(resource.Line), Message: "expected a Kubernetes resource, got a YAML " + kindName(resource)}}
+	}
+
+	var problems []Problem
+	for _, name := range []string{"apiVersion", "kind"} {
+		if value := yamledit.Lookup(resource, name); value == nil || value.Value == "" {
+			problems = append(problems, Problem{File: m.Name, Line: m.SourceLine(resource.Line), Message: name + " is not set"})
+		}
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+
+	apiVersion, kind := yamledit.Lookup(resource, "apiVersion").Value, yamledit.Lookup(resource, "kind").Value
+	if schema.IsArgoCD(apiVersion) && schema.For(apiVersion, kind) == nil {
+		return []Problem{{File: m.Name, Line: m.SourceLine(resource.Line), Message: fmt.Sprintf("unknown Argo CD resource %s %s", apiVersion, kind)}}
+	}
+
+	subject := kind
+	if name := yamledit.Scalar(resource, "metadata", "name"); name != "" {
+		subject += " " + name
+	}
+
+	for _, err := range schema.Validate(resource) {
+		path := fieldPath(err.Path)
+		line := err.Line
+		// Missing fields are reported on their mapping, unless they are
+		// rendered empty
+		if field := yamledit.Lookup(resource, path...); field != nil {
+			line = field.Line
+		}
+
+		loc := c.Locate(m, env, line, path)
+		message := fmt.Sprintf("%s: %s", subject, err.Error())
+		if loc.Value != "" {
+			message += ", set by " + loc.Value
+		}
+		problems = append(problems, Problem{File: loc.File, Line: loc.Line, Column: loc.Column, Message: message})
+	}
+	return problems
+}
+
+// fieldPath splits a schema field path, like spec.sources[0].repoURL, into
+// its keys
+func fieldPath(path string) []string {
+	if path == "" {
+		return nil
+	}
+	path = strings.ReplaceAll(strings.ReplaceAll(path, "[", "."), "]", "")
+	return strings.Split(path, ".")
+}
+
+// yamlErrorPattern matches the location yaml.v3 puts in its errors
+var yamlErrorPattern = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+func yamlProblem(file string, err error) Problem {
+	match := yamlErrorPattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return Problem{File: file, Message: "invalid YAML: " + err.Error()}
+	}
+	line, _ := strconv.Atoi(match[1])
+	return Problem{File: file, Line: line, Message: "invalid YAML: " + match[2]}
+}
+
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null"
+}
+
+func kindName(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		return "list"
+	case yaml.ScalarNode:
+		return "scalar"
+	default:
+		return "node"
+	}
+}
@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"github.com/spf13/afero"
+
+	"github.com/rebelopsio/argo-helper/chart"
+)
+
+// loadChart loads the chart in the directory given as first argument, or
+// the current directory, with the values of an environment
+func loadChart(args []string, env string) (*chart.Chart, map[string]interface{}, error) {
+	root := "."
+	if len(args) > 0 {
+		root = args[0]
+	}
+
+	c, err := chart.Load(afero.NewOsFs(), root)
+	if err != nil {
+		return nil, nil, err
+	}
+	values, err := c.ValuesFor(env)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, values, nil
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/rebelopsio/argo-helper/chart"
+	"github.com/rebelopsio/argo-helper/consolidate"
+	"github.com/rebelopsio/argo-helper/scaffold"
+)
+
+// consolidateOptions holds the flags of the consolidate command
+type consolidateOptions struct {
+	chartDir string
+	repoDir  string
+	minSize  int
+}
+
+// newConsolidateCmd creates the consolidate command
+func newConsolidateCmd() *cobra.Command {
+	opts := &consolidateOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "consolidate",
+		Short: "Rewrite groups of similar Applications as ApplicationSets",
+		Long: `Find the Application templates of the chart that only differ in a few scalar
+values, typically their name, source path and destination namespace, and
+rewrite each group as one ApplicationSet:
+
+- a git directories generator when the source paths are exactly the
+  directories below their parent in the repository (--repo-dir, default is
+  the root of the git repository), and every differing value derives from the
+  directory name
+- a list generator with an element per Application otherwise
+
+The Applications are rendered before and after with values.yaml and every
+environment, and the differences are shown. Only groups whose ApplicationSet
+generates exactly the same Applications in every environment are written; the
+ApplicationSet is added next to the templates it replaces, which are removed.`,
+		Args: cobra.NoArgs,
+		// Groups that are not equivalent are not usage errors
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConsolidate(cmd, args, opts)
+		},
+		Example: `  argo-helper consolidate --dry-run
+  argo-helper consolidate --min-size 3`,
+	}
+
+	cmd.Flags().StringVar(&opts.chartDir, "chart", ".", "directory of the chart")
+	cmd.Flags().StringVar(&opts.repoDir, "repo-dir", "", "local checkout read by the git generators (default is the root of the git repository)")
+	cmd.Flags().IntVar(&opts.minSize, "min-size", 2, "smallest number of Applications consolidated")
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newConsolidateCmd())
+}
+
+func runConsolidate(cmd *cobra.Command, args []string, opts *consolidateOptions) error {
+	fsys := afero.NewOsFs()
+	c, err := chart.Load(fsys, opts.chartDir)
+	if err != nil {
+		return err
+	}
+
+	findOpts := consolidate.Options{MinSize: opts.minSize}
+	findOpts.AppSet.RepoDir = opts.repoDir
+	if findOpts.AppSet.RepoDir == "" {
+		findOpts.AppSet.RepoDir = repositoryRoot(fsys, opts.chartDir)
+	}
+	groups, err := consolidate.Find(c, findOpts)
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		fmt.Println("No similar Applications found")
+		return nil
+	}
+
+	envs, err := c.Environments()
+	if err != nil {
+		return err
+	}
+	envs = append([]string{""}, envs...)
+
+	plan := &scaffold.Plan{}
+	consolidated, applications := 0, 0
+	for i, group := range groups {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("ApplicationSet %s (%s), %s generator with %s\n", group.Name, group.File, group.Generator, strings.Join(group.Params, ", "))
+		fmt.Printf("  replaces %s\n", strings.Join(group.Files, ", "))
+		for _, note := range group.Notes {
+			fmt.Printf("  ⚠️  %s\n", note)
+		}
+
+		equivalent := true
+		for _, env := range envs {
+			comparison, err := group.Compare(c, env, findOpts.AppSet)
+			if err != nil {
+				return fmt.Errorf("failed to compare %s with %s: %w", group.Name, valuesLabel(env), err)
+			}
+			if comparison.Equivalent() {
+				fmt.Printf("  %s: %d Application(s), identical\n", valuesLabel(env), comparison.Applications)
+				continue
+			}
+			equivalent = false
+			fmt.Printf("  %s: %d Application(s) before, %d after, %d differ\n", valuesLabel(env), comparison.Applications, comparison.Generated, len(comparison.Changes))
+			for _, change := range comparison.Changes {
+				fmt.Printf("\n%s %s\n%s", changeMarks[change.Kind], change.Resource, change.Diff)
+			}
+		}
+		if !equivalent {
+			fmt.Println("  ⚠️  skipped, the ApplicationSet does not render the same Applications")
+			continue
+		}
+
+		consolidated++
+		applications += len(group.Files)
+		plan.Files = append(plan.Files, scaffold.File{Path: group.File, Content: group.Content})
+		plan.Removes = append(plan.Removes, group.Files...)
+	}
+	if consolidated == 0 {
+		return nil
+	}
+
+	// Copy-on-write dry runs cannot remove files, the changes are computed
+	// rather than written
+	changes, err := plan.Changes(fsys, opts.chartDir)
+	if err != nil {
+		return err
+	}
+	if viper.GetBool("dry-run") {
+		fmt.Printf("\nDry run: %d Application(s) would be consolidated into %d ApplicationSet(s)\n\n", applications, consolidated)
+		printDryRunChanges(changes, conflictOptions{})
+		return nil
+	}
+
+	if err := plan.WriteFS(fsys, opts.chartDir); err != nil {
+		return err
+	}
+	fmt.Println()
+	printChanges(opts.chartDir, changes)
+	fmt.Printf("\n✅ Consolidated %d Application(s) into %d ApplicationSet(s)\n", applications, consolidated)
+	return nil
+}
+
+// valuesLabel names the values an environment is rendered with
+func valuesLabel(env string) string {
+	if env == "" {
+		return "values.yaml"
+	}
+	return env
+}
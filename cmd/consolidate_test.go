@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+
+	"github.com/rebelopsio/argo-helper/chart"
+)
+
+func TestConsolidateCommand(t *testing.T) {
+	dir := t.TempDir()
+	if err := executeCommand(newInitCmd(), dir, "--project", "demo"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	apps := filepath.Join(dir, "templates", "apps")
+	for _, name := range []string{"billing", "orders"} {
+		content := fmt.Sprintf(`apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: %[1]s
+  namespace: argocd
+  labels:
+    {{- include "common.labels" . | nindent 4 }}
+spec:
+  project: {{ include "common.projectName" . }}
+  source:
+    repoURL: https://github.com/acme/apps.git
+    targetRevision: {{ .Values.global.targetRevision }}
+    path: services/%[1]s
+  destination:
+    server: {{ .Values.destination.server }}
+    namespace: %[1]s
+`, name)
+		if err := os.WriteFile(filepath.Join(apps, name+".yaml"), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write template: %v", err)
+		}
+	}
+
+	render := func() []chart.Manifest {
+		t.Helper()
+		c, err := chart.Load(afero.NewOsFs(), dir)
+		if err != nil {
+			t.Fatalf("Failed to load chart: %v", err)
+		}
+		manifests, err := c.Render(c.Values, chart.RenderOptions{})
+		if err != nil {
+			t.Fatalf("Failed to render: %v", err)
+		}
+		return manifests
+	}
+	before := len(render())
+
+	viper.Set("dry-run", true)
+	err := executeCommand(newConsolidateCmd(), "--chart", dir, "--repo-dir", dir)
+	viper.Set("dry-run", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(apps, "billing.yaml")); err != nil {
+		t.Errorf("Expected the dry run to keep the templates: %v", err)
+	}
+
+	if err := executeCommand(newConsolidateCmd(), "--chart", dir, "--repo-dir", dir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, name := range []string{"billing.yaml", "orders.yaml"} {
+		if _, err := os.Stat(filepath.Join(apps, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed, got %v", name, err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(apps, "applicationset-services.yaml"))
+	if err != nil {
+		t.Fatalf("Expected the ApplicationSet to be written: %v", err)
+	}
+	if !strings.Contains(string(data), "    - list:\n") {
+		t.Errorf("Expected a list generator, got:\n%s", data)
+	}
+	if after := len(render()); after != before-1 {
+		t.Errorf("Expected %d manifests, got %d", before-1, after)
+	}
+
+	// Nothing is left to consolidate
+	if err := executeCommand(newConsolidateCmd(), "--chart", dir, "--repo-dir", dir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+
+	"github.com/rebelopsio/argo-helper/chart"
+	"github.com/rebelopsio/argo-helper/envdiff"
+)
+
+// diffEnvOptions holds the flags of the diff-env command
+type diffEnvOptions struct {
+	chartDir string
+}
+
+// changeMarks prefix the changes in the output of diff-env
+var changeMarks = map[string]string{
+	envdiff.Added:   "+",
+	envdiff.Removed: "-",
+	envdiff.Changed: "~",
+}
+
+// newDiffEnvCmd creates the diff-env command
+func newDiffEnvCmd() *cobra.Command {
+	opts := &diffEnvOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "diff-env <from-env> <to-env>",
+		Short: "Show how two environments differ in values and rendered manifests",
+		Long: `Compare two environments of the chart, each rendered with values.yaml overlaid
+with values/<env>/values.yaml:
+
+- the effective values, key by key
+- the rendered resources, matched by kind, namespace and name, as unified diffs
+
+This shows what promoting from one environment to the other changes.`,
+		Args: cobra.ExactArgs(2),
+		// Problems in the chart are not usage errors
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDiffEnv(cmd, args, opts)
+		},
+		Example: `  argo-helper diff-env dev prod`,
+	}
+
+	cmd.Flags().StringVar(&opts.chartDir, "chart", ".", "directory of the chart")
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newDiffEnvCmd())
+}
+
+func runDiffEnv(cmd *cobra.Command, args []string, opts *diffEnvOptions) error {
+	fromEnv, toEnv := args[0], args[1]
+	c, err := chart.Load(afero.NewOsFs(), opts.chartDir)
+	if err != nil {
+		return err
+	}
+
+	fromValues, err := c.ValuesFor(fromEnv)
+	if err != nil {
+		return err
+	}
+	toValues, err := c.ValuesFor(toEnv)
+	if err != nil {
+		return err
+	}
+	fromManifests, err := c.Render(fromValues, chart.RenderOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to render %s:\n%w", fromEnv, err)
+	}
+	toManifests, err := c.Render(toValues, chart.RenderOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to render %s:\n%w", toEnv, err)
+	}
+	resources, err := envdiff.Manifests(fromEnv, fromManifests, toEnv, toManifests)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	values := envdiff.Values(fromValues, toValues)
+	fmt.Fprintf(out, "Values (%s -> %s):\n", fromEnv, toEnv)
+	if len(values) == 0 {
+		fmt.Fprintln(out, "  No differences")
+	}
+	for _, change := range values {
+		fmt.Fprintf(out, "  %s\n", change)
+	}
+
+	fmt.Fprintf(out, "\nResources (%s -> %s):\n", fromEnv, toEnv)
+	if len(resources) == 0 {
+		fmt.Fprintln(out, "  No differences")
+	}
+	for _, change := range resources {
+		fmt.Fprintf(out, "\n%s %s (%s)\n%s", changeMarks[change.Kind], change.Resource, change.File, change.Diff)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiffEnvCommand(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := executeCommand(newInitCmd(), dir, "--project", "demo", "--examples"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	prod := "global:\n  environment: prod\n  targetRevision: v1.2.0\n"
+	if err := os.WriteFile(filepath.Join(dir, "values", "prod", "values.yaml"), []byte(prod), 0644); err != nil {
+		t.Fatalf("Failed to write prod values: %v", err)
+	}
+
+	cmd := newDiffEnvCmd()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"dev", "prod", "--chart", dir})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		`~ global.targetRevision: "HEAD" -> "v1.2.0"`,
+		"~ Application/argocd/demo-demo-example (templates/apps/example-app.yaml)",
+		"-    targetRevision: HEAD\n+    targetRevision: v1.2.0\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, out.String())
+		}
+	}
+
+	if err := executeCommand(newDiffEnvCmd(), "dev", "staging", "--chart", dir); err == nil {
+		t.Errorf("Expected error for an unknown environment")
+	}
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/rebelopsio/argo-helper/config"
+	"github.com/rebelopsio/argo-helper/doctor"
+)
+
+// doctorOptions holds the flags of the doctor command
+type doctorOptions struct {
+	fix bool
+}
+
+// newDoctorCmd creates the doctor command
+func newDoctorCmd() *cobra.Command {
+	opts := &doctorOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "doctor [path]",
+		Short: "Check the repository still has the structure init set up",
+		Long: `Check the repository in path (default is the current directory) against the
+structure init sets up:
+
+- directories: custom-resources, values, templates/apps and templates/projects
+  exist
+- chart: Chart.yaml exists and has apiVersion v2, a name, a SemVer version and
+  the application type
+- helpers: the helpers templates include are defined
+- values: the values templates use are defined in values.yaml or an
+  environment, unless they are guarded by if, with or default
+- environments: every directory of values/ has a values.yaml
+
+--fix creates the missing directories and environment values files, and adds
+the missing helpers init creates to templates/_helpers.tpl. Other issues need
+a decision and are only reported. The command fails while issues remain.`,
+		Args: cobra.MaximumNArgs(1),
+		// Issues are not usage errors
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDoctor(cmd, args, opts)
+		},
+		Example: `  argo-helper doctor
+  argo-helper doctor --fix --dry-run`,
+	}
+
+	cmd.Flags().BoolVar(&opts.fix, "fix", false, "fix the issues that are safe to fix")
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newDoctorCmd())
+}
+
+func runDoctor(cmd *cobra.Command, args []string, opts *doctorOptions) error {
+	root := "."
+	if len(args) > 0 {
+		root = args[0]
+	}
+	fsys := afero.NewOsFs()
+
+	result, err := doctor.Run(doctor.Options{Fs: fsys, Root: root, Templates: config.TemplateSet()})
+	if err != nil {
+		return err
+	}
+
+	for _, check := range doctor.Checks {
+		var issues []doctor.Issue
+		for _, issue := range result.Issues {
+			if issue.Check == check {
+				issues = append(issues, issue)
+			}
+		}
+		if len(issues) == 0 {
+			fmt.Printf("✅ %s\n", check)
+			continue
+		}
+		fmt.Printf("❌ %s\n", check)
+		for _, issue := range issues {
+			fixable := ""
+			if issue.Fixable {
+				fixable = " (fixable)"
+			}
+			fmt.Printf("   %s%s\n", issue, fixable)
+		}
+	}
+
+	if len(result.Issues) == 0 {
+		fmt.Println("\n✅ No issues found")
+		return nil
+	}
+
+	fixable := result.Fixable()
+	if !opts.fix || fixable == 0 {
+		if fixable > 0 {
+			fmt.Printf("\n%d issue(s) found, %d can be fixed with --fix\n", len(result.Issues), fixable)
+		}
+		return fmt.Errorf("found %d issue(s)", len(result.Issues))
+	}
+
+	changes, err := result.Fixes.Changes(fsys, root)
+	if err != nil {
+		return err
+	}
+	if viper.GetBool("dry-run") {
+		fmt.Printf("\nDry run: %d issue(s) would be fixed\n\n", fixable)
+		printDryRunChanges(changes, conflictOptions{})
+	} else {
+		if err := result.Fixes.WriteFS(fsys, root); err != nil {
+			return err
+		}
+		fmt.Println()
+		printChanges(root, changes)
+		fmt.Printf("\n✅ Fixed %d issue(s)\n", fixable)
+	}
+
+	if remaining := len(result.Issues) - fixable; remaining > 0 {
+		return fmt.Errorf("%d issue(s) need to be fixed by hand", remaining)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestDoctorCommand(t *testing.T) {
+	dir := t.TempDir()
+	if err := executeCommand(newInitCmd(), dir, "--project", "demo"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := executeCommand(newDoctorCmd(), dir); err != nil {
+		t.Fatalf("Expected a fresh scaffold to pass: %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "custom-resources")); err != nil {
+		t.Fatalf("Failed to remove directory: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "values", "qa"), 0755); err != nil {
+		t.Fatalf("Failed to create environment: %v", err)
+	}
+	if err := executeCommand(newDoctorCmd(), dir); err == nil {
+		t.Fatal("Expected an error for the issues")
+	}
+
+	viper.Set("dry-run", true)
+	err := executeCommand(newDoctorCmd(), dir, "--fix")
+	viper.Set("dry-run", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "custom-resources")); !os.IsNotExist(err) {
+		t.Errorf("Expected the dry run to fix nothing, got %v", err)
+	}
+
+	if err := executeCommand(newDoctorCmd(), dir, "--fix"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, path := range []string{"custom-resources", filepath.Join("values", "qa", "values.yaml")} {
+		if _, err := os.Stat(filepath.Join(dir, path)); err != nil {
+			t.Errorf("Expected %s to be created: %v", path, err)
+		}
+	}
+	if err := executeCommand(newDoctorCmd(), dir); err != nil {
+		t.Errorf("Expected no issues after fixing: %v", err)
+	}
+}
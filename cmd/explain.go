@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+
+	"github.com/rebelopsio/argo-helper/appset"
+	"github.com/rebelopsio/argo-helper/chart"
+)
+
+// explainOptions holds the flags of the explain command
+type explainOptions struct {
+	env      string
+	clusters string
+	repoDir  string
+}
+
+// newExplainCmd creates the explain command
+func newExplainCmd() *cobra.Command {
+	opts := &explainOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "explain <applicationset-file>",
+		Short: "Show the Applications an ApplicationSet generates",
+		Long: `Evaluate the generators of the ApplicationSets in a file offline and show the
+Applications they generate, with their paths and destinations.
+
+Files in a chart are rendered with the chart values first, overlaid with
+values/<env>/values.yaml when --env is set. Then the generators are evaluated:
+
+- list uses its elements
+- git directories and files read the local checkout (--repo-dir, default is the
+  root of the git repository containing the file, or of the chart outside of a
+  repository) instead of the repoURL
+- clusters selects from the cluster inventory in --clusters, or only the
+  in-cluster cluster when there is none
+- matrix and merge combine the generators above
+
+The scmProvider, pullRequest, clusterDecisionResource and plugin generators
+need a live service and cannot be evaluated. Generator templates and
+templatePatch are not applied.
+
+A cluster inventory lists the clusters registered in Argo CD:
+
+  clusters:
+    - name: prod-eu
+      server: https://prod-eu.example.com
+      labels:
+        env: prod`,
+		Args: cobra.ExactArgs(1),
+		// Errors in the ApplicationSet are not usage errors
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runExplain(cmd, args, opts)
+		},
+		Example: `  argo-helper explain examples/applicationset.yaml
+  argo-helper explain templates/apps/applicationset-clusters.yaml --clusters clusters.yaml --env prod`,
+	}
+
+	cmd.Flags().StringVar(&opts.env, "env", "", "environment whose values/<env>/values.yaml is applied")
+	cmd.Flags().StringVar(&opts.clusters, "clusters", "", "cluster inventory file for the clusters generator")
+	cmd.Flags().StringVar(&opts.repoDir, "repo-dir", "", "local checkout read by the git generators")
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newExplainCmd())
+}
+
+func runExplain(cmd *cobra.Command, args []string, opts *explainOptions) error {
+	fsys := afero.NewOsFs()
+	path := args[0]
+
+	manifest, err := renderFile(fsys, path, opts.env)
+	if err != nil {
+		return err
+	}
+	docs, err := manifest.Documents()
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	generateOpts := appset.Options{Fs: fsys, RepoDir: opts.repoDir}
+	if generateOpts.RepoDir == "" {
+		generateOpts.RepoDir = repositoryRoot(fsys, filepath.Dir(path))
+	}
+	if opts.clusters != "" {
+		if generateOpts.Clusters, err = appset.ReadInventory(fsys, opts.clusters); err != nil {
+			return err
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	found := 0
+	for _, doc := range docs {
+		var meta struct {
+			Kind     string `yaml:"kind"`
+			Metadata struct {
+				Name string `yaml:"name"`
+			} `yaml:"metadata"`
+		}
+		if err := doc.Decode(&meta); err != nil || meta.Kind != "ApplicationSet" {
+			continue
+		}
+		name := meta.Metadata.Name
+		if found > 0 {
+			fmt.Fprintln(out)
+		}
+		found++
+
+		apps, err := appset.Generate(doc, generateOpts)
+		if err != nil {
+			return fmt.Errorf("ApplicationSet %s: %w", name, err)
+		}
+		if len(apps) == 0 {
+			fmt.Fprintf(out, "ApplicationSet %s generates no applications\n", name)
+			continue
+		}
+
+		fmt.Fprintf(out, "ApplicationSet %s generates %d application(s):\n\n", name, len(apps))
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tPROJECT\tPATH\tDESTINATION\tNAMESPACE")
+		for _, app := range apps {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", app.Name, app.Project, app.Path, app.Destination(), app.Namespace)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if found == 0 {
+		return fmt.Errorf("%s contains no ApplicationSet", path)
+	}
+	return nil
+}
+
+// renderFile renders a file of a chart with the values of an environment.
+// Files outside of a chart are returned as they are.
+func renderFile(fsys afero.Fs, path, env string) (chart.Manifest, error) {
+	data, err := afero.ReadFile(fsys, path)
+	if err != nil {
+		return chart.Manifest{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	root := chartRoot(fsys, filepath.Dir(path))
+	if root == "" {
+		if env != "" {
+			return chart.Manifest{}, fmt.Errorf("--env is set but %s is not part of a chart", path)
+		}
+		return chart.Manifest{Name: path, Content: string(data)}, nil
+	}
+
+	c, err := chart.Load(fsys, root)
+	if err != nil {
+		return chart.Manifest{}, err
+	}
+	values, err := c.ValuesFor(env)
+	if err != nil {
+		return chart.Manifest{}, err
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return chart.Manifest{}, err
+	}
+	name, err := filepath.Rel(root, abs)
+	if err != nil {
+		return chart.Manifest{}, err
+	}
+	return c.RenderFile(chart.File{Name: filepath.ToSlash(name), Data: data}, values, chart.RenderOptions{})
+}
+
+// chartRoot returns the closest directory from dir upwards containing a
+// Chart.yaml, or an empty string
+func chartRoot(fsys afero.Fs, dir string) string {
+	return findUp(dir, func(dir string) bool {
+		exists, err := afero.Exists(fsys, filepath.Join(dir, "Chart.yaml"))
+		return err == nil && exists
+	})
+}
+
+// repositoryRoot returns the root of the git repository containing dir.
+// Outside of a repository it returns the root of the chart containing dir,
+// or the working directory.
+func repositoryRoot(fsys afero.Fs, dir string) string {
+	root := findUp(dir, func(dir string) bool {
+		_, err := os.Stat(filepath.Join(dir, ".git"))
+		return err == nil
+	})
+	if root == "" {
+		root = chartRoot(fsys, dir)
+	}
+	if root == "" {
+		return "."
+	}
+	return root
+}
+
+// findUp returns the first absolute directory from dir upwards matching
+// found, or an empty string
+func findUp(dir string, found func(dir string) bool) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		if found(dir) {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
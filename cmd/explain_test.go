@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExplainCommand(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := executeCommand(newInitCmd(), dir, "--project", "demo", "--examples"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, app := range []string{"web", "api"} {
+		if err := os.MkdirAll(filepath.Join(dir, "apps", app), 0755); err != nil {
+			t.Fatalf("Failed to create app directory: %v", err)
+		}
+	}
+
+	path := filepath.Join(dir, "examples", "applicationset.yaml")
+	// Outside of a git repository the git generators read the chart root
+	for _, args := range [][]string{{path, "--repo-dir", dir}, {path}} {
+		cmd := newExplainCmd()
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Unexpected error for %v: %v", args, err)
+		}
+
+		for _, want := range []string{
+			"ApplicationSet demo-apps generates 2 application(s)",
+			"api   demo     apps/api  https://kubernetes.default.svc  api",
+			"web   demo     apps/web  https://kubernetes.default.svc  web",
+		} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("Expected output of %v to contain %q:\n%s", args, want, out.String())
+			}
+		}
+	}
+
+	if err := executeCommand(newExplainCmd(), filepath.Join(dir, "values.yaml")); err == nil {
+		t.Errorf("Expected error for a file without an ApplicationSet")
+	}
+}
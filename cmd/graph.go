@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+
+	"github.com/rebelopsio/argo-helper/appset"
+	"github.com/rebelopsio/argo-helper/chart"
+	"github.com/rebelopsio/argo-helper/inventory"
+)
+
+// graphOptions holds the flags of the graph command
+type graphOptions struct {
+	chartDir string
+	env      string
+	format   string
+	clusters string
+	repoDir  string
+}
+
+// newGraphCmd creates the graph command
+func newGraphCmd() *cobra.Command {
+	opts := &graphOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Export the hierarchy of Applications, ApplicationSets and projects",
+		Long: `Build the graph of the Argo CD resources of the chart, rendered with
+values.yaml overlaid with values/<env>/values.yaml when --env is set:
+
+- manages: an Application from the chart repository (global.repoURL) whose
+  source path is the chart manages the resources of its templates; with any
+  other path of the repository, it manages the resources of the templates and
+  examples below that path
+- generates: an ApplicationSet generates Applications, evaluated offline like
+  'explain' does
+- project: an Application or ApplicationSet belongs to its AppProject
+
+The graph is printed in DOT (render it with 'dot -Tsvg'), Mermaid or JSON.`,
+		Args: cobra.NoArgs,
+		// Problems in the chart are not usage errors
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runGraph(cmd, args, opts)
+		},
+		Example: `  argo-helper graph | dot -Tsvg > graph.svg
+  argo-helper graph --env prod --format mermaid`,
+	}
+
+	cmd.Flags().StringVar(&opts.chartDir, "chart", ".", "directory of the chart")
+	cmd.Flags().StringVar(&opts.env, "env", "", "environment whose values/<env>/values.yaml is applied")
+	cmd.Flags().StringVar(&opts.format, "format", "dot", "output format: "+strings.Join(inventory.GraphFormats, ", "))
+	cmd.Flags().StringVar(&opts.clusters, "clusters", "", "cluster inventory file for the clusters generator")
+	cmd.Flags().StringVar(&opts.repoDir, "repo-dir", "", "local checkout read by the git generators (default is the root of the git repository)")
+	_ = cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions(inventory.GraphFormats, cobra.ShellCompDirectiveNoFileComp))
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newGraphCmd())
+}
+
+func runGraph(cmd *cobra.Command, args []string, opts *graphOptions) error {
+	switch opts.format {
+	case "dot", "mermaid", "json":
+	default:
+		return fmt.Errorf("unsupported --format: %s (must be one of %s)", opts.format, strings.Join(inventory.GraphFormats, ", "))
+	}
+
+	fsys := afero.NewOsFs()
+	c, err := chart.Load(fsys, opts.chartDir)
+	if err != nil {
+		return err
+	}
+	resources, err := inventory.Collect(c, []string{opts.env})
+	if err != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: some files could not be rendered:\n%v\n", err)
+	}
+
+	graphOpts := inventory.GraphOptions{AppSet: appset.Options{Fs: fsys, RepoDir: opts.repoDir}}
+	if graphOpts.AppSet.RepoDir == "" {
+		graphOpts.AppSet.RepoDir = repositoryRoot(fsys, opts.chartDir)
+	}
+	if opts.clusters != "" {
+		if graphOpts.AppSet.Clusters, err = appset.ReadInventory(fsys, opts.clusters); err != nil {
+			return err
+		}
+	}
+	if graphOpts.ChartPath, err = relativePath(graphOpts.AppSet.RepoDir, opts.chartDir); err != nil {
+		return err
+	}
+	values, err := c.ValuesFor(opts.env)
+	if err != nil {
+		return err
+	}
+	if global, ok := values["global"].(map[string]interface{}); ok {
+		graphOpts.RepoURL, _ = global["repoURL"].(string)
+	}
+
+	graph := inventory.BuildGraph(resources, graphOpts)
+	for _, warning := range graph.Warnings {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s\n", warning)
+	}
+	return graph.Write(cmd.OutOrStdout(), opts.format)
+}
+
+// relativePath returns path relative to base with forward slashes, empty
+// when they are the same directory
+func relativePath(base, path string) (string, error) {
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return "", err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absBase, absPath)
+	if err != nil {
+		return "", err
+	}
+	if rel == "." {
+		return "", nil
+	}
+	return filepath.ToSlash(rel), nil
+}
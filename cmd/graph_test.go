@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGraphCommand(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := executeCommand(newInitCmd(), dir, "--project", "demo", "--examples"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "apps", "web"), 0755); err != nil {
+		t.Fatalf("Failed to create app directory: %v", err)
+	}
+
+	cmd := newGraphCmd()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--chart", dir, "--env", "prod"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		"digraph argocd {",
+		`"ApplicationSet/demo-apps" -> "Application/web" [label="generates"];`,
+		`"Application/web" -> "AppProject/demo" [label="project", style=dotted];`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, out.String())
+		}
+	}
+
+	if err := executeCommand(newGraphCmd(), "--chart", dir, "--format", "png"); err == nil {
+		t.Errorf("Expected error for an unsupported format")
+	}
+}
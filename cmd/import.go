@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/rebelopsio/argo-helper/config"
+	"github.com/rebelopsio/argo-helper/scaffold"
+)
+
+// importOptions holds the flags of the import command
+type importOptions struct {
+	outputPath string
+	valuesFile string
+	env        string
+	conflicts  conflictOptions
+}
+
+// newImportCmd creates the import command
+func newImportCmd() *cobra.Command {
+	opts := &importOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "import <file|dir>",
+		Short: "Convert existing Application manifests into templates of the chart",
+		Long: `Convert existing Application manifests, like the output of
+kubectl get applications -o yaml or argocd admin export, into templates of the
+chart. Directories are read recursively, files may hold several documents and
+Kubernetes lists.
+
+Every Application gets a template in templates/apps using the common helpers:
+- its labels include common.labels, and its project is common.projectName when
+  it is the chart project
+- its repoURL, targetRevision and destination move to the values under
+  applications.<name>, in values.yaml or with --env in values/<env>/values.yaml
+- its name and namespace are kept, so ArgoCD adopts the existing Application
+  rather than recreating it
+- the cluster-managed fields, like status and metadata.uid, are dropped
+
+Documents that are not Applications, Applications generated by an
+ApplicationSet and fields kept as-is rather than templated are reported.
+Importing the same manifests again changes nothing.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runImport(cmd, args, opts)
+		},
+		Example: `  kubectl get applications -n argocd -o yaml > apps.yaml
+  argo-helper import apps.yaml --dry-run
+  argo-helper import exports/prod --env prod`,
+	}
+
+	cmd.Flags().StringVarP(&opts.outputPath, "output", "o", "", "output path (default is templates/apps)")
+	cmd.Flags().StringVar(&opts.valuesFile, "values-file", "", "values file of the chart, next to values/<env>/values.yaml (default is values.yaml)")
+	cmd.Flags().StringVarP(&opts.env, "env", "e", "", "environment the manifests come from, whose values/<env>/values.yaml gets their values (default is values.yaml)")
+	addConflictFlags(cmd, &opts.conflicts)
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newImportCmd())
+}
+
+func runImport(cmd *cobra.Command, args []string, flags *importOptions) error {
+	opts := scaffold.ImportOptions{
+		Path:         args[0],
+		OutputPath:   flags.outputPath,
+		ValuesFile:   flags.valuesFile,
+		Env:          flags.env,
+		Templates:    config.TemplateSet(),
+		WriteOptions: writeOptions(cmd, flags.conflicts),
+	}
+
+	result, err := scaffold.Import(cmd.Context(), opts)
+	if err != nil {
+		return withConflictHint(err)
+	}
+
+	imported := 0
+	for _, file := range result.Plan.Files {
+		if file.TemplateID != "" {
+			imported++
+		}
+	}
+
+	if viper.GetBool("dry-run") {
+		fmt.Printf("Dry run: %d Application(s) would be imported from %s\n\n", imported, opts.Path)
+		printDryRunChanges(result.Changes, flags.conflicts)
+	} else {
+		printChanges("", result.Changes)
+	}
+	for _, note := range result.Plan.Notes {
+		fmt.Printf("\n⚠️  %s\n", note)
+	}
+
+	if !viper.GetBool("dry-run") {
+		fmt.Printf("\n✅ Imported %d Application(s) from %s\n", imported, opts.Path)
+	}
+	return nil
+}
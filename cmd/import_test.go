@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+
+	"github.com/rebelopsio/argo-helper/chart"
+)
+
+func TestImportCommand(t *testing.T) {
+	dir := t.TempDir()
+	if err := executeCommand(newInitCmd(), dir, "--project", "demo"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	export := filepath.Join(t.TempDir(), "apps.yaml")
+	apps := `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: billing
+  namespace: argocd
+spec:
+  project: demo
+  source:
+    repoURL: https://github.com/acme/billing.git
+    targetRevision: v2.3.1
+    path: charts/billing
+  destination:
+    server: https://prod.example.com
+    namespace: billing
+`
+	if err := os.WriteFile(export, []byte(apps), 0644); err != nil {
+		t.Fatalf("Failed to write export: %v", err)
+	}
+
+	args := []string{export, "--env", "prod",
+		"--output", filepath.Join(dir, "templates", "apps"),
+		"--values-file", filepath.Join(dir, "values.yaml")}
+	if err := executeCommand(newImportCmd(), args...); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The template renders back to the exported Application
+	c, err := chart.Load(afero.NewOsFs(), dir)
+	if err != nil {
+		t.Fatalf("Failed to load chart: %v", err)
+	}
+	values, err := c.ValuesFor("prod")
+	if err != nil {
+		t.Fatalf("Failed to read values: %v", err)
+	}
+	manifests, err := c.Render(values, chart.RenderOptions{})
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	var manifest chart.Manifest
+	for _, m := range manifests {
+		if strings.HasSuffix(m.Name, "application-billing.yaml") {
+			manifest = m
+		}
+	}
+	for _, want := range []string{
+		"project: demo",
+		"repoURL: https://github.com/acme/billing.git",
+		"targetRevision: v2.3.1",
+		"server: https://prod.example.com",
+		"namespace: billing",
+		"app.kubernetes.io/part-of: demo",
+	} {
+		if !strings.Contains(manifest.Content, want) {
+			t.Errorf("Expected the rendered Application to contain %q:\n%s", want, manifest.Content)
+		}
+	}
+
+	// Existing templates are only overwritten when asked to
+	if err := os.WriteFile(filepath.Join(dir, "templates", "apps", "application-billing.yaml"), []byte("edited\n"), 0644); err != nil {
+		t.Fatalf("Failed to edit template: %v", err)
+	}
+	if err := executeCommand(newImportCmd(), args...); err == nil {
+		t.Errorf("Expected an error overwriting an edited template")
+	}
+}
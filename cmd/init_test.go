@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInitRefusesExistingScaffold(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := executeCommand(newInitCmd(), dir, "--project", "demo"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	valuesPath := filepath.Join(dir, "values.yaml")
+	if err := os.WriteFile(valuesPath, []byte("# customized\n"), 0644); err != nil {
+		t.Fatalf("Failed to write values file: %v", err)
+	}
+
+	if err := executeCommand(newInitCmd(), dir, "--project", "demo"); err == nil {
+		t.Errorf("Expected error for an existing scaffold")
+	}
+
+	if err := executeCommand(newInitCmd(), dir, "--project", "demo", "--skip-existing"); err != nil {
+		t.Fatalf("Unexpected error with --skip-existing: %v", err)
+	}
+
+	data, err := os.ReadFile(valuesPath)
+	if err != nil {
+		t.Fatalf("Failed to read values file: %v", err)
+	}
+	if string(data) != "# customized\n" {
+		t.Errorf("Expected customized values to be kept:\n%s", data)
+	}
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/rebelopsio/argo-helper/lint"
+)
+
+// lintOptions holds the flags of the lint command
+type lintOptions struct {
+	format string
+	failOn string
+}
+
+// newLintCmd creates the lint command
+func newLintCmd() *cobra.Command {
+	opts := &lintOptions{}
+
+	var rules strings.Builder
+	for _, rule := range lint.Rules() {
+		fmt.Fprintf(&rules, "  %-32s %-8s %s\n", rule.ID, rule.Severity, rule.Description)
+	}
+
+	cmd := &cobra.Command{
+		Use:   "lint [path]",
+		Short: "Check the chart for risky ArgoCD configurations",
+		Long: `Check the chart in path (default is the current directory) for risky ArgoCD
+configurations. Template rules check the template sources, resource rules the
+resources rendered with values.yaml and with the values of every environment.
+
+Rules:
+` + rules.String() + `
+Rule severities can be changed or rules disabled with "off" in ~/.argo-helper.yaml
+or in a .argo-helper.yaml next to Chart.yaml, which takes precedence:
+
+  lint:
+    rules:
+      missing-resources-finalizer: off
+      project-wildcard-source-repos: error
+    production-envs: [prod, production]
+
+The command fails when a finding is at least as severe as --fail-on.`,
+		Args: cobra.MaximumNArgs(1),
+		// Findings are not usage errors
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runLint(cmd, args, opts)
+		},
+		Example: `  argo-helper lint
+  argo-helper lint --format sarif > argo-helper.sarif`,
+	}
+
+	cmd.Flags().StringVar(&opts.format, "format", "text", "output format: "+strings.Join(lint.Formats, ", "))
+	cmd.Flags().StringVar(&opts.failOn, "fail-on", "error", "lowest severity failing the command: error, warning, info or none")
+	_ = cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions(lint.Formats, cobra.ShellCompDirectiveNoFileComp))
+	_ = cmd.RegisterFlagCompletionFunc("fail-on", cobra.FixedCompletions([]string{"error", "warning", "info", "none"}, cobra.ShellCompDirectiveNoFileComp))
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newLintCmd())
+}
+
+func runLint(cmd *cobra.Command, args []string, opts *lintOptions) error {
+	failOn := lint.SeverityOff
+	if opts.failOn != "none" {
+		var err error
+		if failOn, err = lint.ParseSeverity(opts.failOn); err != nil || failOn == lint.SeverityOff {
+			return fmt.Errorf("unsupported --fail-on: %s (must be one of error, warning, info, none)", opts.failOn)
+		}
+	}
+
+	c, _, err := loadChart(args, "")
+	if err != nil {
+		return err
+	}
+
+	cfg, err := lintConfig(c.Root)
+	if err != nil {
+		return err
+	}
+
+	findings, err := lint.Run(c, cfg)
+	if err != nil {
+		return err
+	}
+	if err := lint.Report(cmd.OutOrStdout(), opts.format, findings, filepath.ToSlash(c.Root), version); err != nil {
+		return err
+	}
+
+	if failOn == lint.SeverityOff {
+		return nil
+	}
+	failed := 0
+	for _, finding := range findings {
+		if finding.Severity.AtLeast(failOn) {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("lint failed with %d finding(s) of severity %s or higher", failed, failOn)
+	}
+	return nil
+}
+
+// lintConfig returns the lint configuration from the config file, overlaid
+// with the .argo-helper.yaml of the chart
+func lintConfig(root string) (lint.Config, error) {
+	cfg := lint.Config{Severities: map[string]lint.Severity{}}
+	if err := addLintConfig(&cfg, viper.GetViper()); err != nil {
+		return cfg, err
+	}
+
+	repoConfig := viper.New()
+	repoConfig.SetConfigFile(filepath.Join(root, ".argo-helper.yaml"))
+	if err := repoConfig.ReadInConfig(); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return cfg, nil
+		}
+		return cfg, fmt.Errorf("failed to read %s: %w", repoConfig.ConfigFileUsed(), err)
+	}
+	return cfg, addLintConfig(&cfg, repoConfig)
+}
+
+// addLintConfig adds the lint section of a configuration to cfg
+func addLintConfig(cfg *lint.Config, v *viper.Viper) error {
+	for id, name := range v.GetStringMapString("lint.rules") {
+		severity, err := lint.ParseSeverity(name)
+		if err != nil {
+			return fmt.Errorf("invalid severity for lint rule %s: %w", id, err)
+		}
+		cfg.Severities[id] = severity
+	}
+	if envs := v.GetStringSlice("lint.production-envs"); len(envs) > 0 {
+		cfg.ProductionEnvs = envs
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLintCommand(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := executeCommand(newInitCmd(), dir, "--project", "demo", "--examples"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The scaffold only has warnings and info findings
+	if err := executeCommand(newLintCmd(), dir); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := executeCommand(newLintCmd(), dir, "--fail-on", "warning"); err == nil {
+		t.Errorf("Expected error for warnings with --fail-on warning")
+	}
+	if err := executeCommand(newLintCmd(), dir, "--fail-on", "fatal"); err == nil {
+		t.Errorf("Expected error for an unsupported --fail-on")
+	}
+
+	config := `lint:
+  rules:
+    project-wildcard-source-repos: error
+`
+	if err := os.WriteFile(filepath.Join(dir, ".argo-helper.yaml"), []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if err := executeCommand(newLintCmd(), dir); err == nil {
+		t.Errorf("Expected error for a rule raised to error in the chart config")
+	}
+	if err := executeCommand(newLintCmd(), dir, "--fail-on", "none"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rebelopsio/argo-helper/chart"
+	"github.com/rebelopsio/argo-helper/inventory"
+)
+
+// listKinds maps the arguments of the list command to resource kinds
+var listKinds = map[string]string{
+	"apps":     inventory.KindApplication,
+	"appsets":  inventory.KindApplicationSet,
+	"projects": inventory.KindAppProject,
+}
+
+// listFormats are the output formats of the list command
+var listFormats = []string{"table", "json", "yaml"}
+
+// listOptions holds the flags of the list command
+type listOptions struct {
+	chartDir string
+	envs     []string
+	output   string
+}
+
+// newListCmd creates the list command
+func newListCmd() *cobra.Command {
+	opts := &listOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "list [apps|appsets|projects]",
+		Short: "List the Applications, ApplicationSets and AppProjects of the chart",
+		Long: `List the Applications, ApplicationSets and AppProjects defined in the templates
+and examples of the chart, with their project, source path, destination and
+sync policy. All kinds are listed when no kind is given.
+
+The chart is rendered with values.yaml overlaid with values/<env>/values.yaml
+for every environment, or only those given with --env. A chart without
+environments is rendered with values.yaml alone.
+
+The ApplicationSet columns come from its template, before the generators are
+applied. Use 'explain' to list the Applications it generates.`,
+		Args:      cobra.MatchAll(cobra.MaximumNArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: []string{"apps", "appsets", "projects"},
+		// Problems in the chart are not usage errors
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runList(cmd, args, opts)
+		},
+		Example: `  argo-helper list
+  argo-helper list apps --env prod
+  argo-helper list projects -o json | jq -r '.[].name'`,
+	}
+
+	cmd.Flags().StringVar(&opts.chartDir, "chart", ".", "directory of the chart")
+	cmd.Flags().StringSliceVar(&opts.envs, "env", nil, "environments to render (default is every environment)")
+	cmd.Flags().StringVarP(&opts.output, "output", "o", "table", "output format: "+strings.Join(listFormats, ", "))
+	_ = cmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(listFormats, cobra.ShellCompDirectiveNoFileComp))
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newListCmd())
+}
+
+func runList(cmd *cobra.Command, args []string, opts *listOptions) error {
+	switch opts.output {
+	case "table", "json", "yaml":
+	default:
+		return fmt.Errorf("unsupported --output: %s (must be one of %s)", opts.output, strings.Join(listFormats, ", "))
+	}
+
+	c, err := chart.Load(afero.NewOsFs(), opts.chartDir)
+	if err != nil {
+		return err
+	}
+	envs := opts.envs
+	if len(envs) == 0 {
+		if envs, err = c.Environments(); err != nil {
+			return err
+		}
+		if len(envs) == 0 {
+			envs = []string{""}
+		}
+	}
+
+	resources, err := inventory.Collect(c, envs)
+	if err != nil {
+		// Report the templates that failed and list the others
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: some files could not be rendered:\n%v\n", err)
+	}
+
+	if len(args) > 0 {
+		var selected []inventory.Resource
+		for _, resource := range resources {
+			if resource.Kind == listKinds[args[0]] {
+				selected = append(selected, resource)
+			}
+		}
+		resources = selected
+	}
+	if resources == nil {
+		resources = []inventory.Resource{}
+	}
+
+	out := cmd.OutOrStdout()
+	switch opts.output {
+	case "json":
+		data, err := json.MarshalIndent(resources, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(data))
+		return nil
+	case "yaml":
+		enc := yaml.NewEncoder(out)
+		enc.SetIndent(2)
+		if err := enc.Encode(resources); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+
+	if len(resources) == 0 {
+		fmt.Fprintln(out, "No resources found")
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ENV\tNAME\tKIND\tPROJECT\tPATH\tDESTINATION\tSYNC POLICY")
+	for _, r := range resources {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", dash(r.Env), r.Name, r.Kind, dash(r.Project), dash(r.Path), dash(r.Destination), dash(r.SyncPolicy))
+	}
+	return w.Flush()
+}
+
+// dash returns "-" for empty table cells
+func dash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestListCommand(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := executeCommand(newInitCmd(), dir, "--project", "demo", "--examples"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	list := func(args ...string) string {
+		t.Helper()
+		cmd := newListCmd()
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetArgs(append(args, "--chart", dir))
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return out.String()
+	}
+
+	out := list()
+	for _, want := range []string{
+		"ENV   NAME",
+		"dev   demo-apps ",
+		"prod  demo       ",
+		"automated (prune, self-heal)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, out)
+		}
+	}
+
+	var resources []struct {
+		Env  string `json:"env"`
+		Kind string `json:"kind"`
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal([]byte(list("projects", "--env", "prod", "-o", "json")), &resources); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	if len(resources) != 1 || resources[0].Env != "prod" || resources[0].Kind != "AppProject" || resources[0].Name != "demo" {
+		t.Errorf("Expected the demo AppProject in prod, got %+v", resources)
+	}
+
+	if out := list("appsets", "-o", "yaml"); !strings.Contains(out, "- env: dev\n  kind: ApplicationSet\n  name: demo-apps\n") {
+		t.Errorf("Expected YAML output with the ApplicationSet:\n%s", out)
+	}
+
+	if err := executeCommand(newListCmd(), "clusters", "--chart", dir); err == nil {
+		t.Errorf("Expected error for an unknown kind")
+	}
+	if err := executeCommand(newListCmd(), "--chart", dir, "-o", "xml"); err == nil {
+		t.Errorf("Expected error for an unsupported output")
+	}
+}
@@ -2,97 +2,203 @@ package cmd
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-)
 
-var (
-	resourceType string
-	resourceName string
-	outputPath   string
+	"github.com/rebelopsio/argo-helper/config"
+	"github.com/rebelopsio/argo-helper/scaffold"
 )
 
-// newCmd represents the new command
-var newCmd = &cobra.Command{
-	Use:   "new [resource-type] [resource-name]",
-	Short: "Create a new ArgoCD resource",
-	Long: `Create a new ArgoCD resource with an opinionated template.
-Currently supported resource types:
-- applicationset: Create a new ApplicationSet manifest
+// newOptions holds the flags of the new command
+type newOptions struct {
+	outputPath string
+	conflicts  conflictOptions
+	// params holds the values of the resource type parameter flags, keyed by
+	// parameter name
+	params map[string]*paramValue
+}
 
-The resources will be created in the templates/apps/ directory by default.`,
-	Args:    cobra.MinimumNArgs(1),
-	RunE:    runNew,
-	Example: "  argo-helper new applicationset my-apps",
+// paramValue is the flag storage of a resource type parameter
+type paramValue struct {
+	kind   scaffold.ParameterKind
+	value  string
+	values []string
+	pairs  map[string]string
 }
 
-func init() {
-	rootCmd.AddCommand(newCmd)
+// entries returns the flag value in the form Parameter.Apply expects, or nil
+// if the flag is not set
+func (v *paramValue) entries() []string {
+	switch v.kind {
+	case scaffold.ListParameter, scaffold.RepeatedParameter:
+		return v.values
+	case scaffold.MapParameter:
+		entries := make([]string, 0, len(v.pairs))
+		for key, value := range v.pairs {
+			entries = append(entries, key+"="+value)
+		}
+		sort.Strings(entries)
+		return entries
+	default:
+		if v.value == "" {
+			return nil
+		}
+		return []string{v.value}
+	}
+}
+
+// newNewCmd creates the new command
+func newNewCmd() *cobra.Command {
+	opts := &newOptions{params: map[string]*paramValue{}}
+
+	cmd := &cobra.Command{
+		Use:   "new [resource-type] [resource-name]",
+		Short: "Create a new ArgoCD resource",
+		Long: `Create a new ArgoCD resource with an opinionated template.
+Currently supported resource types:
+%s
+AppProjects are generated with least-privilege defaults: only the chart
+repository as source, a single destination namespace and no cluster-scoped
+resources.
+
+Flags by resource type:
+%s`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runNew(cmd, args, opts)
+		},
+		ValidArgsFunction: completeNewArgs,
+		Example: `  argo-helper new applicationset my-apps
+  argo-helper new applicationset my-apps --generator list --list-element name=web,namespace=web
+  argo-helper new applicationset my-apps --generator matrix --child-generators git-directories,clusters
+  argo-helper new application my-service --path apps/my-service --dest-namespace my-service
+  argo-helper new application my-service --sync-policy manual
+  argo-helper new appproject team-a --destinations team-a --destinations team-a-jobs@https://kubernetes.default.svc
+  argo-helper new appproject team-b --cluster-resource-whitelist rbac.authorization.k8s.io:ClusterRole`,
+	}
 
 	// Local flags
-	newCmd.Flags().StringVarP(&outputPath, "output", "o", "", "output path (default is templates/apps/)")
+	cmd.Flags().StringVarP(&opts.outputPath, "output", "o", "", "output path (default depends on the resource type, see above)")
+	addConflictFlags(cmd, &opts.conflicts)
+
+	// Resource type flags, help text and completion all come from the registry
+	var types, flags strings.Builder
+	for _, rt := range scaffold.ResourceTypes() {
+		fmt.Fprintf(&types, "- %s: %s (created in %s/)\n", rt.Name(), rt.Description(), rt.OutputPath())
+
+		names := make([]string, 0, len(rt.Parameters()))
+		for _, param := range rt.Parameters() {
+			names = append(names, "--"+param.Name)
+			addParamFlag(cmd, opts.params, param)
+		}
+		fmt.Fprintf(&flags, "- %s: %s\n", rt.Name(), strings.Join(names, ", "))
+	}
+	cmd.Long = fmt.Sprintf(cmd.Long, types.String(), flags.String())
+
+	return cmd
 }
 
-// SetNewFlags sets the flags for the new command
-// This is used by the TUI to set the flags programmatically
-func SetNewFlags(cmd *cobra.Command, rType string, rName string, output string) {
-	// Set global variables directly since this will be used from TUI as well
-	resourceType = rType
-	resourceName = rName
-	outputPath = output
+func init() {
+	rootCmd.AddCommand(newNewCmd())
 }
 
-// RunNew is exported for use by the TUI
-func RunNew(cmd *cobra.Command, args []string) error {
-	return runNew(cmd, args)
+// addParamFlag registers the flag of a resource type parameter. Parameters
+// shared by several resource types share a single flag.
+func addParamFlag(cmd *cobra.Command, params map[string]*paramValue, param scaffold.Parameter) {
+	if _, exists := params[param.Name]; exists {
+		return
+	}
+
+	v := &paramValue{kind: param.Kind}
+	params[param.Name] = v
+
+	switch param.Kind {
+	case scaffold.ListParameter:
+		cmd.Flags().StringSliceVar(&v.values, param.Name, nil, param.Description)
+	case scaffold.RepeatedParameter:
+		cmd.Flags().StringArrayVar(&v.values, param.Name, nil, param.Description)
+	case scaffold.MapParameter:
+		cmd.Flags().StringToStringVar(&v.pairs, param.Name, nil, param.Description)
+	default:
+		cmd.Flags().StringVar(&v.value, param.Name, param.Default, param.Description)
+	}
+
+	if len(param.Choices) > 0 {
+		choices := param.Choices
+		_ = cmd.RegisterFlagCompletionFunc(param.Name, func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+			return choices, cobra.ShellCompDirectiveNoFileComp
+		})
+	}
 }
 
-func runNew(cmd *cobra.Command, args []string) error {
-	// Parse arguments
-	resourceType = args[0]
-	if len(args) > 1 {
-		resourceName = args[1]
+// completeNewArgs completes the resource type argument
+func completeNewArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
 
-	// Validate resource type
-	if resourceType != "applicationset" {
-		return fmt.Errorf("unsupported resource type: %s", resourceType)
+	var completions []string
+	for _, rt := range scaffold.ResourceTypes() {
+		completions = append(completions, rt.Name()+"\t"+rt.Description())
 	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
 
-	// If resourceName is not provided via argument or flag, prompt for it
-	if resourceName == "" {
-		return fmt.Errorf("resource name is required")
+func runNew(cmd *cobra.Command, args []string, flags *newOptions) error {
+	// Parse arguments
+	resourceType := args[0]
+	var resourceName string
+	if len(args) > 1 {
+		resourceName = args[1]
 	}
 
-	// Set default output path if not provided
-	if outputPath == "" {
-		outputPath = "templates/apps"
+	// Build the resource options from the flags
+	opts, err := flags.resourceOptions(resourceType, resourceName)
+	if err != nil {
+		return err
 	}
+	opts.WriteOptions = writeOptions(cmd, flags.conflicts)
 
-	// Create the output directory if it doesn't exist
-	if err := os.MkdirAll(outputPath, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+	// Create the resource, in memory for a dry run
+	result, err := scaffold.New(cmd.Context(), opts)
+	if err != nil {
+		return withConflictHint(err)
 	}
 
 	// If dry run is enabled, just print what would be created
 	if viper.GetBool("dry-run") {
-		return printNewDryRun()
+		return printNewDryRun(opts, result, flags.conflicts)
 	}
 
-	// Create the resource
-	if err := createResource(); err != nil {
-		return err
+	printChanges("", result.Changes)
+	for _, note := range result.Plan.Notes {
+		fmt.Printf("\n⚠️  %s\n", note)
+	}
+
+	// The resource file comes first, an existing one may have been kept
+	var resource scaffold.Change
+	for _, change := range result.Changes {
+		if !change.Dir {
+			resource = change
+			break
+		}
 	}
 
 	// Print success message
-	filename := fmt.Sprintf("%s-%s.yaml", resourceType, resourceName)
+	if resource.Status == scaffold.StatusSkip {
+		fmt.Printf("\n%s '%s' already exists at %s, kept it unchanged\n",
+			capitalizeFirstLetter(resourceType),
+			resourceName,
+			resource.Path)
+		return nil
+	}
 	fmt.Printf("\n✅ %s '%s' successfully created at %s\n\n",
 		capitalizeFirstLetter(resourceType),
 		resourceName,
-		filepath.Join(outputPath, filename))
+		resource.Path)
 
 	fmt.Println("Next steps:")
 	fmt.Println("1. Review and customize the generated resource")
@@ -101,78 +207,52 @@ func runNew(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func createResource() error {
-	// Define the content based on the resource type
-	var content string
-
-	switch resourceType {
-	case "applicationset":
-		content = generateApplicationSetTemplate()
+// resourceOptions builds the resource options from the new command flags
+func (o *newOptions) resourceOptions(resourceType, resourceName string) (scaffold.NewOptions, error) {
+	opts := scaffold.NewOptions{
+		Type:       resourceType,
+		Name:       resourceName,
+		OutputPath: o.outputPath,
+		Templates:  config.TemplateSet(),
 	}
 
-	// Write the file
-	filename := fmt.Sprintf("%s-%s.yaml", resourceType, resourceName)
-	filePath := filepath.Join(outputPath, filename)
-
-	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
-		return fmt.Errorf("failed to create file %s: %w", filename, err)
+	// Only the parameters of the selected resource type apply, New reports
+	// unsupported types
+	rt, ok := scaffold.LookupResourceType(resourceType)
+	if !ok {
+		return opts, nil
+	}
+	for _, param := range rt.Parameters() {
+		v, ok := o.params[param.Name]
+		if !ok {
+			continue
+		}
+		entries := v.entries()
+		if len(entries) == 0 {
+			continue
+		}
+		if err := param.Apply(&opts, entries); err != nil {
+			return opts, err
+		}
 	}
 
-	fmt.Printf("Created file: %s\n", filePath)
-	return nil
-}
-
-func generateApplicationSetTemplate() string {
-	return fmt.Sprintf(`apiVersion: argoproj.io/v1alpha1
-kind: ApplicationSet
-metadata:
-  name: %s
-  namespace: argocd
-spec:
-  generators:
-    - git:
-        repoURL: {{ .Values.global.repoURL }}
-        revision: {{ .Values.global.targetRevision }}
-        directories:
-          - path: apps/*
-  template:
-    metadata:
-      name: '{{ "{{ path.basename }}" }}'
-      labels:
-        {{- include "common.labels" . | nindent 8 }}
-    spec:
-      project: {{ include "common.projectName" . }}
-      source:
-        repoURL: {{ .Values.global.repoURL }}
-        targetRevision: {{ .Values.global.targetRevision }}
-        path: '{{ "{{ path }}" }}'
-      destination:
-        server: {{ .Values.destination.server | default "https://kubernetes.default.svc" }}
-        namespace: '{{ "{{ path.basename }}" }}'
-      syncPolicy:
-        {{- toYaml .Values.applications.defaults.syncPolicy | nindent 8 }}
-`, resourceName)
+	return opts, nil
 }
 
-func printNewDryRun() error {
+func printNewDryRun(opts scaffold.NewOptions, result *scaffold.Result, conflicts conflictOptions) error {
 	fmt.Println("Dry run: The following resource would be created:")
-	fmt.Printf("\nResource Type: %s\n", resourceType)
-	fmt.Printf("Resource Name: %s\n", resourceName)
-	fmt.Printf("Output Path: %s\n\n", outputPath)
-
-	filename := fmt.Sprintf("%s-%s.yaml", resourceType, resourceName)
-	fmt.Printf("File: %s\n\n", filepath.Join(outputPath, filename))
+	fmt.Printf("\nResource Type: %s\n", opts.Type)
+	fmt.Printf("Resource Name: %s\n", opts.Name)
+	fmt.Printf("Output Path: %s\n\n", result.Plan.Dirs[0])
 
-	fmt.Println("Template content:")
-	fmt.Println("---")
+	printDryRunChanges(result.Changes, conflicts)
+	fmt.Println()
 
-	switch resourceType {
-	case "applicationset":
-		fmt.Println(generateApplicationSetTemplate())
+	for _, note := range result.Plan.Notes {
+		fmt.Printf("⚠️  %s\n", note)
 	}
 
-	fmt.Println("---")
-	fmt.Printf("\nTo create this resource, run again without the --dry-run flag\n")
+	fmt.Printf("To create this resource, run again without the --dry-run flag\n")
 
 	return nil
 }
@@ -1,11 +1,16 @@
 package cmd
 
 import (
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/rebelopsio/argo-helper/scaffold"
 )
 
 func TestNewCommand(t *testing.T) {
@@ -31,6 +36,13 @@ func TestNewCommand(t *testing.T) {
 			outputPath:   tempDir,
 			shouldError:  false,
 		},
+		{
+			name:         "Valid Application",
+			resourceType: "application",
+			resourceName: "test-app",
+			outputPath:   tempDir,
+			shouldError:  false,
+		},
 		{
 			name:         "Invalid Resource Type",
 			resourceType: "invalid-type",
@@ -49,41 +61,169 @@ func TestNewCommand(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			// Create a new command for testing
-			cmd := &cobra.Command{}
-			
-			// Set the flags
-			SetNewFlags(cmd, tc.resourceType, tc.resourceName, tc.outputPath)
-			
-			// Run the command
+			// Run the command with the output flag
 			args := []string{tc.resourceType}
 			if tc.resourceName != "" {
 				args = append(args, tc.resourceName)
 			}
-			
-			err := runNew(cmd, args)
-			
+			args = append(args, "--output", tc.outputPath)
+
+			err := executeCommand(newNewCmd(), args...)
+
 			// Check if error occurred as expected
 			if tc.shouldError && err == nil {
 				t.Errorf("Expected error but got none")
 			}
-			
+
 			if !tc.shouldError && err != nil {
 				t.Errorf("Unexpected error: %v", err)
 			}
-			
+
 			// If no error, check that the file was created
 			if !tc.shouldError {
-				expectedFilePath := filepath.Join(tc.outputPath, 
+				expectedFilePath := filepath.Join(tc.outputPath,
 					tc.resourceType+"-"+tc.resourceName+".yaml")
-				
+
 				if _, err := os.Stat(expectedFilePath); os.IsNotExist(err) {
 					t.Errorf("Expected file was not created: %s", expectedFilePath)
 				}
-				
+
 				// Clean up the generated file
 				os.Remove(expectedFilePath)
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestNewAppProjectUpdatesValues(t *testing.T) {
+	tempDir := t.TempDir()
+
+	valuesPath := filepath.Join(tempDir, "values.yaml")
+	initial := "# Default values\nglobal:\n  project: demo\n"
+	if err := os.WriteFile(valuesPath, []byte(initial), 0644); err != nil {
+		t.Fatalf("Failed to write values file: %v", err)
+	}
+
+	outputDir := filepath.Join(tempDir, "templates", "projects")
+
+	// Running twice must not duplicate the values block
+	for i := 0; i < 2; i++ {
+		err := executeCommand(newNewCmd(), "appproject", "team-a",
+			"--output", outputDir,
+			"--values-file", valuesPath,
+			"--destinations", "team-a,team-a-jobs@https://example.com",
+			"--cluster-resource-whitelist", "rbac.authorization.k8s.io:ClusterRole")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(outputDir, "appproject-team-a.yaml")); err != nil {
+		t.Errorf("Expected project template to be created: %v", err)
+	}
+
+	data, err := os.ReadFile(valuesPath)
+	if err != nil {
+		t.Fatalf("Failed to read values file: %v", err)
+	}
+	content := string(data)
+
+	if !strings.HasPrefix(content, initial) {
+		t.Errorf("Existing values were modified:\n%s", content)
+	}
+	if strings.Count(content, "  team-a:\n") != 1 {
+		t.Errorf("Expected exactly one team-a project block:\n%s", content)
+	}
+	for _, want := range []string{
+		`server: "https://example.com"`,
+		`kind: "ClusterRole"`,
+		"sourceRepos: []",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Expected values to contain %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestNewAppProjectInvalidFlags(t *testing.T) {
+	err := executeCommand(newNewCmd(), "appproject", "team-b",
+		"--output", t.TempDir(),
+		"--namespace-resource-blacklist", "Secret")
+	if err == nil {
+		t.Errorf("Expected error for resource without group separator")
+	}
+}
+
+func TestNewFlagsFromRegistry(t *testing.T) {
+	newCmd := newNewCmd()
+	for _, rt := range scaffold.ResourceTypes() {
+		if !strings.Contains(newCmd.Long, rt.Name()) {
+			t.Errorf("Expected help text to list %s", rt.Name())
+		}
+		for _, param := range rt.Parameters() {
+			if newCmd.Flags().Lookup(param.Name) == nil {
+				t.Errorf("Expected flag --%s for %s", param.Name, rt.Name())
+			}
+		}
+	}
+
+	completions, _ := completeNewArgs(newCmd, nil, "")
+	if len(completions) != len(scaffold.ResourceTypes()) {
+		t.Errorf("Expected a completion per resource type, got %v", completions)
+	}
+}
+
+func TestNewDryRunWritesNothing(t *testing.T) {
+	viper.Set("dry-run", true)
+	defer viper.Set("dry-run", false)
+
+	outputDir := filepath.Join(t.TempDir(), "apps")
+	if err := executeCommand(newNewCmd(), "application", "web", "--output", outputDir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err := os.Stat(outputDir); !os.IsNotExist(err) {
+		t.Errorf("Expected dry run not to create %s", outputDir)
+	}
+}
+
+func TestNewRefusesToOverwrite(t *testing.T) {
+	outputDir := t.TempDir()
+	existing := filepath.Join(outputDir, "application-web.yaml")
+	if err := os.WriteFile(existing, []byte("# customized\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if err := executeCommand(newNewCmd(), "application", "web", "--output", outputDir); err == nil {
+		t.Errorf("Expected error for an existing file")
+	}
+
+	if err := executeCommand(newNewCmd(), "application", "web", "--output", outputDir, "--skip-existing"); err != nil {
+		t.Errorf("Unexpected error with --skip-existing: %v", err)
+	}
+
+	cmd := newNewCmd()
+	cmd.SetIn(strings.NewReader("y\n"))
+	if err := executeCommand(cmd, "application", "web", "--output", outputDir, "--interactive"); err != nil {
+		t.Errorf("Unexpected error with --interactive: %v", err)
+	}
+
+	data, err := os.ReadFile(existing)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if !strings.Contains(string(data), "kind: Application") {
+		t.Errorf("Expected the file to be overwritten after confirming:\n%s", data)
+	}
+}
+
+// executeCommand runs a command with the given arguments, discarding its
+// usage and prompt output
+func executeCommand(cmd *cobra.Command, args ...string) error {
+	cmd.SetArgs(args)
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	return cmd.Execute()
+}
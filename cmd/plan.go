@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/rebelopsio/argo-helper/scaffold"
+)
+
+// conflictOptions holds the flags deciding what happens to existing files
+type conflictOptions struct {
+	force        bool
+	skipExisting bool
+	interactive  bool
+}
+
+// addConflictFlags adds the flags deciding what happens to existing files
+func addConflictFlags(cmd *cobra.Command, opts *conflictOptions) {
+	cmd.Flags().BoolVarP(&opts.force, "force", "f", false, "overwrite existing files")
+	cmd.Flags().BoolVar(&opts.skipExisting, "skip-existing", false, "keep existing files and only create missing ones")
+	cmd.Flags().BoolVarP(&opts.interactive, "interactive", "i", false, "ask before overwriting each existing file")
+	cmd.MarkFlagsMutuallyExclusive("force", "skip-existing", "interactive")
+}
+
+// policy returns the conflict policy selected by the flags
+func (o conflictOptions) policy() scaffold.ConflictPolicy {
+	switch {
+	case o.force:
+		return scaffold.ConflictOverwrite
+	case o.skipExisting:
+		return scaffold.ConflictSkip
+	case o.interactive:
+		return scaffold.ConflictAsk
+	default:
+		return scaffold.ConflictFail
+	}
+}
+
+// writeOptions returns how the command writes its plan. Dry runs write to an
+// in-memory layer on top of the real filesystem, so they take the same code
+// path as a real run without touching the disk. A dry run never refuses or
+// prompts, it reports the conflicts as overwrites instead.
+func writeOptions(cmd *cobra.Command, conflicts conflictOptions) scaffold.WriteOptions {
+	if viper.GetBool("dry-run") {
+		policy := conflicts.policy()
+		if policy == scaffold.ConflictFail || policy == scaffold.ConflictAsk {
+			policy = scaffold.ConflictOverwrite
+		}
+		return scaffold.WriteOptions{
+			Fs:        afero.NewCopyOnWriteFs(afero.NewReadOnlyFs(afero.NewOsFs()), afero.NewMemMapFs()),
+			Conflicts: policy,
+		}
+	}
+
+	return scaffold.WriteOptions{
+		Fs:        afero.NewOsFs(),
+		Conflicts: conflicts.policy(),
+		Prompt:    promptOverwrite(cmd),
+	}
+}
+
+// withConflictHint adds the flags resolving a conflict to its error
+func withConflictHint(err error) error {
+	var conflictErr *scaffold.ConflictError
+	switch {
+	case errors.Is(err, scaffold.ErrScaffoldExists):
+		return fmt.Errorf("%w\nUse --force to overwrite it, --skip-existing to only add missing files or --interactive to decide per file", err)
+	case errors.As(err, &conflictErr):
+		return fmt.Errorf("%w\nUse --force to overwrite them, --skip-existing to keep them or --interactive to decide per file", err)
+	default:
+		return err
+	}
+}
+
+// warnExistingScaffold warns when a dry run targets a directory a real run
+// would refuse because it already contains an argo-helper scaffold
+func warnExistingScaffold(root string, conflicts conflictOptions) error {
+	if conflicts.policy() != scaffold.ConflictFail {
+		return nil
+	}
+
+	exists, err := scaffold.HasScaffold(afero.NewOsFs(), root)
+	if err != nil || !exists {
+		return err
+	}
+
+	fmt.Printf("⚠️  %s: %v, a real run needs --force, --skip-existing or --interactive\n\n", root, scaffold.ErrScaffoldExists)
+	return nil
+}
+
+// promptOverwrite returns a prompter asking on the command input whether to
+// overwrite each conflicting file
+func promptOverwrite(cmd *cobra.Command) scaffold.Prompter {
+	in := bufio.NewReader(cmd.InOrStdin())
+	out := cmd.OutOrStdout()
+	var all *bool
+
+	return func(change scaffold.Change) (bool, error) {
+		if all != nil {
+			return *all, nil
+		}
+
+		for {
+			fmt.Fprintf(out, "Overwrite %s? [y]es, [n]o, [a]ll, [s]kip all, [d]iff: ", change.Path)
+			answer, err := in.ReadString('\n')
+			if err != nil && (err != io.EOF || answer == "") {
+				return false, fmt.Errorf("failed to read answer: %w", err)
+			}
+
+			switch strings.ToLower(strings.TrimSpace(answer)) {
+			case "y", "yes":
+				return true, nil
+			case "n", "no", "":
+				return false, nil
+			case "a", "all":
+				overwrite := true
+				all = &overwrite
+				return true, nil
+			case "s", "skip":
+				overwrite := false
+				all = &overwrite
+				return false, nil
+			case "d", "diff":
+				fmt.Fprintln(out, change.Diff)
+			}
+		}
+	}
+}
+
+// printChanges prints the changes of a real run
+func printChanges(root string, changes []scaffold.Change) {
+	for _, change := range changes {
+		path := filepath.Join(root, change.Path)
+		switch {
+		case change.Dir:
+			fmt.Printf("Created directory: %s\n", path)
+		case change.Status == scaffold.StatusCreate:
+			fmt.Printf("Created file: %s\n", path)
+		case change.Status == scaffold.StatusOverwrite:
+			fmt.Printf("Updated file: %s\n", path)
+		case change.Status == scaffold.StatusSkip:
+			fmt.Printf("Skipped existing file: %s\n", path)
+		case change.Status == scaffold.StatusRemove:
+			fmt.Printf("Removed file: %s\n", path)
+		default:
+			fmt.Printf("Unchanged file: %s\n", path)
+		}
+	}
+}
+
+// printDryRunChanges prints the status of every planned path followed by the
+// diffs of the files that would change
+func printDryRunChanges(changes []scaffold.Change, conflicts conflictOptions) {
+	for _, change := range changes {
+		path := change.Path
+		if change.Dir {
+			path += "/"
+		}
+		fmt.Printf("  %-10s %s\n", change.Status, path)
+	}
+
+	for _, change := range changes {
+		if change.Diff != "" {
+			fmt.Printf("\n%s", change.Diff)
+		}
+	}
+
+	if overwrites := scaffold.Conflicts(changes); len(overwrites) > 0 && conflicts.policy() == scaffold.ConflictFail {
+		fmt.Printf("\n⚠️  %d existing file(s) would be overwritten, a real run needs --force, --skip-existing or --interactive\n", len(overwrites))
+	}
+}
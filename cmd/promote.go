@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/rebelopsio/argo-helper/chart"
+	"github.com/rebelopsio/argo-helper/promote"
+	"github.com/rebelopsio/argo-helper/scaffold"
+)
+
+// promoteOptions holds the flags of the promote command
+type promoteOptions struct {
+	chartDir string
+	from     string
+	to       string
+}
+
+// newPromoteCmd creates the promote command
+func newPromoteCmd() *cobra.Command {
+	opts := &promoteOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "promote <app> --from <env> --to <env>",
+		Short: "Copy the versions of an application from one environment to another",
+		Long: `Copy the versions of an application from one environment to another by
+editing values/<to>/values.yaml in place, keeping its comments and formatting.
+
+The versions are the targetRevision, revision, tag, imageTag, version and
+chartVersion keys below applications.<app>, at any depth, compared between the
+effective values of both environments. Only the versions that differ are
+written.
+
+A summary of the promotion is printed in Markdown, ready for a pull request
+description. Use --dry-run to see the diff without writing it.`,
+		Args: cobra.ExactArgs(1),
+		// Problems in the values are not usage errors
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPromote(cmd, args, opts)
+		},
+		Example: `  argo-helper promote web --from dev --to prod --dry-run
+  argo-helper promote web --from dev --to prod > promotion.md`,
+	}
+
+	cmd.Flags().StringVar(&opts.chartDir, "chart", ".", "directory of the chart")
+	cmd.Flags().StringVar(&opts.from, "from", "", "environment to take the versions from")
+	cmd.Flags().StringVar(&opts.to, "to", "", "environment to promote to")
+	_ = cmd.MarkFlagRequired("from")
+	_ = cmd.MarkFlagRequired("to")
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newPromoteCmd())
+}
+
+func runPromote(cmd *cobra.Command, args []string, opts *promoteOptions) error {
+	fsys := afero.NewOsFs()
+	c, err := chart.Load(fsys, opts.chartDir)
+	if err != nil {
+		return err
+	}
+	p, err := promote.Plan(fsys, c, args[0], opts.from, opts.to)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	plan := &scaffold.Plan{Files: []scaffold.File{{Path: p.File, Content: p.Content}}}
+	if viper.GetBool("dry-run") {
+		changes, err := plan.Changes(fsys, c.Root)
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			if change.Diff != "" {
+				fmt.Fprintf(out, "%s\n", change.Diff)
+			}
+		}
+	} else if len(p.Changes) > 0 {
+		if err := plan.WriteFS(fsys, c.Root); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprint(out, p.Summary())
+	return nil
+}
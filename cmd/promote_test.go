@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestPromoteCommand(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := executeCommand(newInitCmd(), dir, "--project", "demo", "--examples"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	dev := filepath.Join(dir, "values", "dev", "values.yaml")
+	prod := filepath.Join(dir, "values", "prod", "values.yaml")
+	if err := os.WriteFile(dev, []byte("applications:\n  web:\n    targetRevision: v1.3.0\n"), 0644); err != nil {
+		t.Fatalf("Failed to write dev values: %v", err)
+	}
+	before, err := os.ReadFile(prod)
+	if err != nil {
+		t.Fatalf("Failed to read prod values: %v", err)
+	}
+
+	promote := func() string {
+		t.Helper()
+		cmd := newPromoteCmd()
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetArgs([]string{"web", "--from", "dev", "--to", "prod", "--chart", dir})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return out.String()
+	}
+
+	viper.Set("dry-run", true)
+	out := promote()
+	viper.Set("dry-run", false)
+	if !strings.Contains(out, "+applications:\n+  web:\n+    targetRevision: v1.3.0\n") {
+		t.Errorf("Expected the dry run to show the diff:\n%s", out)
+	}
+	if after, _ := os.ReadFile(prod); string(after) != string(before) {
+		t.Errorf("Expected the dry run not to change %s", prod)
+	}
+
+	out = promote()
+	if !strings.Contains(out, "| `applications.web.targetRevision` | (not set) | `\"v1.3.0\"` |") {
+		t.Errorf("Expected a summary of the promotion:\n%s", out)
+	}
+	after, err := os.ReadFile(prod)
+	if err != nil {
+		t.Fatalf("Failed to read prod values: %v", err)
+	}
+	if !strings.HasPrefix(string(after), string(before)) || !strings.HasSuffix(string(after), "applications:\n  web:\n    targetRevision: v1.3.0\n") {
+		t.Errorf("Expected the version to be appended to %s:\n%s", prod, after)
+	}
+
+	if out := promote(); !strings.Contains(out, "prod already runs the versions of dev") {
+		t.Errorf("Expected a second promotion to change nothing:\n%s", out)
+	}
+
+	if err := executeCommand(newPromoteCmd(), "web", "--to", "prod", "--chart", dir); err == nil {
+		t.Errorf("Expected error without --from")
+	}
+}
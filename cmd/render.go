@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/rebelopsio/argo-helper/chart"
+)
+
+// renderOptions holds the flags of the render command
+type renderOptions struct {
+	env         string
+	outputDir   string
+	releaseName string
+	showOnly    []string
+}
+
+// newRenderCmd creates the render command
+func newRenderCmd() *cobra.Command {
+	opts := &renderOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "render [path]",
+		Short: "Render the chart templates like helm template, without helm",
+		Long: `Render the templates of the chart in path (default is the current
+directory) the way helm template does, without needing helm.
+
+The chart is rendered with values.yaml, overlaid with values/<env>/values.yaml
+when --env is set. Values are merged like Helm merges them: maps are merged
+key by key, lists and other values are replaced and null removes a key.
+
+The manifests are printed to stdout, or written to --output-dir with one file
+per template.`,
+		Args: cobra.MaximumNArgs(1),
+		// Problems in the chart are not usage errors
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRender(cmd, args, opts)
+		},
+		Example: `  argo-helper render --env prod
+  argo-helper render --env dev --output-dir rendered/dev
+  argo-helper render --show-only templates/apps/web.yaml`,
+	}
+
+	cmd.Flags().StringVar(&opts.env, "env", "", "environment whose values/<env>/values.yaml is applied")
+	cmd.Flags().StringVarP(&opts.outputDir, "output-dir", "o", "", "write the manifests to this directory instead of stdout")
+	cmd.Flags().StringVar(&opts.releaseName, "release-name", "", "release name available to templates as .Release.Name (default is the chart name)")
+	cmd.Flags().StringSliceVarP(&opts.showOnly, "show-only", "s", nil, "only render the given templates, e.g. templates/apps/web.yaml")
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newRenderCmd())
+}
+
+func runRender(cmd *cobra.Command, args []string, opts *renderOptions) error {
+	c, values, err := loadChart(args, opts.env)
+	if err != nil {
+		return err
+	}
+
+	manifests, err := c.Render(values, chart.RenderOptions{ReleaseName: opts.releaseName})
+	if err != nil {
+		return fmt.Errorf("failed to render the chart:\n%w", err)
+	}
+
+	if len(opts.showOnly) > 0 {
+		if manifests, err = selectManifests(manifests, opts.showOnly); err != nil {
+			return err
+		}
+	}
+
+	if opts.outputDir == "" {
+		for _, manifest := range manifests {
+			writeManifest(cmd.OutOrStdout(), c, manifest)
+		}
+		return nil
+	}
+
+	for _, manifest := range manifests {
+		path := filepath.Join(opts.outputDir, filepath.FromSlash(manifest.Name))
+		if viper.GetBool("dry-run") {
+			fmt.Fprintf(cmd.OutOrStdout(), "Would write: %s\n", path)
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(path), err)
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", path, err)
+		}
+		writeManifest(f, c, manifest)
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Wrote: %s\n", path)
+	}
+
+	return nil
+}
+
+// selectManifests keeps the manifests rendered from the given templates
+func selectManifests(manifests []chart.Manifest, names []string) ([]chart.Manifest, error) {
+	byName := map[string]chart.Manifest{}
+	for _, manifest := range manifests {
+		byName[manifest.Name] = manifest
+	}
+
+	var selected []chart.Manifest
+	for _, name := range names {
+		manifest, ok := byName[filepath.ToSlash(filepath.Clean(name))]
+		if !ok {
+			return nil, fmt.Errorf("could not find template %s in the rendered chart", name)
+		}
+		selected = append(selected, manifest)
+	}
+	return selected, nil
+}
+
+// writeManifest writes a manifest with the same header as helm template
+func writeManifest(w io.Writer, c *chart.Chart, manifest chart.Manifest) {
+	fmt.Fprintf(w, "---\n# Source: %s/%s\n%s", c.Metadata.Name, manifest.Name, manifest.Content)
+	if len(manifest.Content) > 0 && manifest.Content[len(manifest.Content)-1] != '\n' {
+		fmt.Fprintln(w)
+	}
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderCommand(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := executeCommand(newInitCmd(), dir, "--project", "demo", "--examples"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	prodValues := filepath.Join(dir, "values", "prod", "values.yaml")
+	if err := os.WriteFile(prodValues, []byte("applications:\n  defaults:\n    syncPolicy:\n      automated: null\n"), 0644); err != nil {
+		t.Fatalf("Failed to write values file: %v", err)
+	}
+
+	cmd := newRenderCmd()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{dir, "--env", "prod", "--show-only", "templates/apps/example-app.yaml"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	rendered := out.String()
+	if !strings.HasPrefix(rendered, "---\n# Source: demo/templates/apps/example-app.yaml\n") {
+		t.Errorf("Expected a helm template style header:\n%s", rendered)
+	}
+	if strings.Contains(rendered, "automated") || !strings.Contains(rendered, "CreateNamespace=true") {
+		t.Errorf("Expected the prod values to remove automated sync only:\n%s", rendered)
+	}
+
+	outputDir := filepath.Join(t.TempDir(), "rendered")
+	if err := executeCommand(newRenderCmd(), dir, "--output-dir", outputDir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, name := range []string{"templates/apps/example-app.yaml", "templates/projects/project.yaml"} {
+		if _, err := os.Stat(filepath.Join(outputDir, name)); err != nil {
+			t.Errorf("Expected %s to be written: %v", name, err)
+		}
+	}
+
+	if err := executeCommand(newRenderCmd(), dir, "--show-only", "templates/apps/missing.yaml"); err == nil {
+		t.Errorf("Expected error for an unknown template")
+	}
+}
@@ -6,13 +6,19 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	"github.com/rebelopsio/argo-helper/config"
 )
 
 var (
-	cfgFile string
-	dryRun  bool
+	cfgFile      string
+	dryRun       bool
+	templatesDir string
 )
 
+// version is set at build time through -ldflags
+var version = "dev"
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "argo-helper",
@@ -34,33 +40,22 @@ func init() {
 	// Global flags
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.argo-helper.yaml)")
 	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "preview the changes without making them")
+	rootCmd.PersistentFlags().StringVar(&templatesDir, "templates-dir", "", "directory with scaffold template overrides (default is templates-dir from the config file)")
 
 	// Bind flags to viper
 	if err := viper.BindPFlag("dry-run", rootCmd.PersistentFlags().Lookup("dry-run")); err != nil {
 		fmt.Println("Error binding flag:", err)
 	}
+	if err := viper.BindPFlag("templates-dir", rootCmd.PersistentFlags().Lookup("templates-dir")); err != nil {
+		fmt.Println("Error binding flag:", err)
+	}
 }
 
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
-	if cfgFile != "" {
-		// Use config file from the flag.
-		viper.SetConfigFile(cfgFile)
-	} else {
-		// Find home directory.
-		home, err := os.UserHomeDir()
-		cobra.CheckErr(err)
-
-		// Search config in home directory with name ".argo-helper" (without extension).
-		viper.AddConfigPath(home)
-		viper.SetConfigType("yaml")
-		viper.SetConfigName(".argo-helper")
-	}
-
-	viper.AutomaticEnv() // read in environment variables that match
-
-	// If a config file is found, read it in.
-	if err := viper.ReadInConfig(); err == nil {
-		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+	used, err := config.Load(cfgFile)
+	cobra.CheckErr(err)
+	if used != "" {
+		fmt.Fprintln(os.Stderr, "Using config file:", used)
 	}
 }
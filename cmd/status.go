@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rebelopsio/argo-helper/scaffold"
+)
+
+// statusFormats are the output formats of the status command
+var statusFormats = []string{"table", "json", "yaml"}
+
+// statusOptions holds the flags of the status command
+type statusOptions struct {
+	output string
+}
+
+// newStatusCmd creates the status command
+func newStatusCmd() *cobra.Command {
+	opts := &statusOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "status [path]",
+		Short: "List the generated files and whether they were edited",
+		Long: `List the files argo-helper generated in the repository in path (default is the
+current directory), with the template and scaffold version they come from:
+
+- pristine: the file is as argo-helper last wrote it
+- modified: the file was edited since
+- missing: the file was deleted
+
+init, new, import and upgrade record the files they render in
+` + scaffold.LockFile + `. Edits made by argo-helper itself, like new
+registering an application in values.yaml, keep files pristine.`,
+		Args: cobra.MaximumNArgs(1),
+		// A repository without a lock file is not a usage error
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runStatus(cmd, args, opts)
+		},
+		Example: `  argo-helper status
+  argo-helper status -o json`,
+	}
+
+	cmd.Flags().StringVarP(&opts.output, "output", "o", "table", "output format: "+strings.Join(statusFormats, ", "))
+	_ = cmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(statusFormats, cobra.ShellCompDirectiveNoFileComp))
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newStatusCmd())
+}
+
+func runStatus(cmd *cobra.Command, args []string, opts *statusOptions) error {
+	switch opts.output {
+	case "table", "json", "yaml":
+	default:
+		return fmt.Errorf("unsupported --output: %s (must be one of %s)", opts.output, strings.Join(statusFormats, ", "))
+	}
+	root := "."
+	if len(args) > 0 {
+		root = args[0]
+	}
+
+	statuses, err := scaffold.Status(afero.NewOsFs(), root)
+	if errors.Is(err, scaffold.ErrNoLock) {
+		return fmt.Errorf("%w in %s, files are recorded by init, new, import and upgrade", err, scaffold.LockFile)
+	}
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	switch opts.output {
+	case "json":
+		data, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(data))
+		return nil
+	case "yaml":
+		enc := yaml.NewEncoder(out)
+		enc.SetIndent(2)
+		if err := enc.Encode(statuses); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+
+	if len(statuses) == 0 {
+		fmt.Fprintln(out, "No generated files")
+		return nil
+	}
+	counts := map[scaffold.FileState]int{}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATE\tPATH\tTEMPLATE\tVERSION")
+	for _, s := range statuses {
+		counts[s.State]++
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", s.State, s.Path, s.Template, s.Version)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "\n%d pristine, %d modified, %d missing\n",
+		counts[scaffold.StatePristine], counts[scaffold.StateModified], counts[scaffold.StateMissing])
+	return nil
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStatusCommand(t *testing.T) {
+	dir := t.TempDir()
+	if err := executeCommand(newStatusCmd(), dir); err == nil {
+		t.Errorf("Expected an error for a directory without a lock file")
+	}
+
+	if err := executeCommand(newInitCmd(), dir, "--project", "demo"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# demo\n"), 0644); err != nil {
+		t.Fatalf("Failed to edit README.md: %v", err)
+	}
+	for _, format := range []string{"table", "json", "yaml"} {
+		if err := executeCommand(newStatusCmd(), dir, "-o", format); err != nil {
+			t.Errorf("Unexpected error for %s: %v", format, err)
+		}
+	}
+	if err := executeCommand(newStatusCmd(), dir, "-o", "xml"); err == nil {
+		t.Errorf("Expected an error for an unsupported format")
+	}
+}
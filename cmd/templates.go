@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/rebelopsio/argo-helper/config"
+	"github.com/rebelopsio/argo-helper/templates"
+)
+
+// newTemplatesCmd creates the templates command
+func newTemplatesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "templates",
+		Short: "Inspect and customize the scaffold templates",
+		Long: `Inspect and customize the templates used by init and new.
+
+The built-in templates are embedded in argo-helper. Any of them can be
+overridden by placing a file with the same id and a .tmpl extension in the
+templates directory, configured with --templates-dir or the templates-dir key
+in ~/.argo-helper.yaml. Use 'templates eject' to copy the built-in templates
+there as a starting point.
+
+Templates use [[ ]] as delimiters, so Helm {{ }} expressions are written as-is.`,
+	}
+
+	cmd.AddCommand(newTemplatesListCmd(), newTemplatesShowCmd(), newTemplatesEjectCmd())
+
+	return cmd
+}
+
+// newTemplatesListCmd creates the templates list command
+func newTemplatesListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the available templates and where they are loaded from",
+		Args:  cobra.NoArgs,
+		RunE:  runTemplatesList,
+	}
+}
+
+// newTemplatesShowCmd creates the templates show command
+func newTemplatesShowCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "show [template-id]",
+		Short:   "Print the effective content of a template",
+		Args:    cobra.ExactArgs(1),
+		RunE:    runTemplatesShow,
+		Example: "  argo-helper templates show init/values.yaml",
+	}
+}
+
+// ejectOptions holds the flags of the templates eject command
+type ejectOptions struct {
+	dir   string
+	force bool
+}
+
+// newTemplatesEjectCmd creates the templates eject command
+func newTemplatesEjectCmd() *cobra.Command {
+	opts := &ejectOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "eject [template-id...]",
+		Short: "Copy built-in templates to the templates directory for customization",
+		Long: `Copy built-in templates to the templates directory for customization.
+All templates are ejected when no template ids are given.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTemplatesEject(cmd, args, opts)
+		},
+		Example: `  argo-helper templates eject
+  argo-helper templates eject init/values.yaml --dir ./my-templates`,
+	}
+
+	cmd.Flags().StringVarP(&opts.dir, "dir", "d", "", "directory to eject to (default is the templates directory or ~/.argo-helper/templates)")
+	cmd.Flags().BoolVarP(&opts.force, "force", "f", false, "overwrite templates that have already been ejected")
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newTemplatesCmd())
+}
+
+func runTemplatesList(cmd *cobra.Command, args []string) error {
+	set := config.TemplateSet()
+	list, err := set.List()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TEMPLATE\tSOURCE")
+	for _, t := range list {
+		source := string(t.Source)
+		if t.Path != "" {
+			source = fmt.Sprintf("%s (%s)", t.Source, t.Path)
+		}
+		fmt.Fprintf(w, "%s\t%s\n", t.ID, source)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if set.OverrideDir() == "" {
+		fmt.Println("\nNo templates directory configured, set --templates-dir or templates-dir in the config file to override templates")
+	}
+
+	return nil
+}
+
+func runTemplatesShow(cmd *cobra.Command, args []string) error {
+	data, _, err := config.TemplateSet().Read(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(string(data))
+	return nil
+}
+
+func runTemplatesEject(cmd *cobra.Command, args []string, opts *ejectOptions) error {
+	dir := opts.dir
+	if dir == "" {
+		dir = config.TemplateSet().OverrideDir()
+	}
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("failed to get home directory: %w", err)
+		}
+		dir = filepath.Join(home, ".argo-helper", "templates")
+	}
+	dir = config.ExpandHome(dir)
+
+	written, err := templates.Eject(dir, args, opts.force)
+	for _, path := range written {
+		fmt.Printf("Created file: %s\n", path)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n✅ %d template(s) ejected to %s\n", len(written), dir)
+	if config.ExpandHome(viper.GetString("templates-dir")) != dir {
+		fmt.Printf("\nTo use them, pass --templates-dir %s or add the following to ~/.argo-helper.yaml:\n\ntemplates-dir: %s\n", dir, dir)
+	}
+
+	return nil
+}
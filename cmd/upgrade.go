@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/rebelopsio/argo-helper/scaffold"
+)
+
+// newUpgradeCmd creates the upgrade command
+func newUpgradeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "upgrade [path]",
+		Short: "Upgrade the repository to the scaffold of this argo-helper version",
+		Long: `Upgrade the repository in path (default is the current directory) to the
+scaffold this version of argo-helper creates, like new helpers or values keys.
+
+init records the scaffold version in ` + scaffold.ManifestFile + `. upgrade applies
+the migrations made since, in order, with a three-way merge of every file they
+change: your edits are kept and the scaffold changes are added around them.
+Files where both changed the same lines are reported as conflicts and left as
+they are; resolve them by hand and run upgrade again. Repositories created
+before scaffold versions are upgraded from version 1.`,
+		Args: cobra.MaximumNArgs(1),
+		// Conflicts are not usage errors
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runUpgrade(cmd, args)
+		},
+		Example: `  argo-helper upgrade --dry-run
+  argo-helper upgrade`,
+	}
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newUpgradeCmd())
+}
+
+func runUpgrade(cmd *cobra.Command, args []string) error {
+	opts := scaffold.UpgradeOptions{WriteOptions: writeOptions(cmd, conflictOptions{})}
+	if len(args) > 0 {
+		opts.Path = args[0]
+	}
+
+	result, err := scaffold.Upgrade(cmd.Context(), opts)
+	if err != nil {
+		return err
+	}
+	if len(result.Migrations) == 0 {
+		fmt.Printf("✅ Already at scaffold version %d\n", scaffold.ScaffoldVersion)
+		return nil
+	}
+
+	fmt.Printf("Migrations from scaffold version %d to %d:\n", result.From, scaffold.ScaffoldVersion)
+	for _, m := range result.Migrations {
+		fmt.Printf("  %d. %s\n", m.Version, m.Description)
+	}
+	fmt.Println()
+
+	if viper.GetBool("dry-run") {
+		fmt.Println("Dry run:")
+		printDryRunChanges(result.Changes, conflictOptions{force: true})
+	} else {
+		printChanges(opts.Path, result.Changes)
+	}
+	for _, note := range result.Plan.Notes {
+		fmt.Printf("\n⚠️  %s\n", note)
+	}
+
+	if len(result.Conflicts) > 0 {
+		for _, file := range result.Conflicts {
+			for _, conflict := range file.Conflicts {
+				fmt.Printf("\n❌ Conflict in %s:%d\n%s", file.Path, conflict.Line, conflict)
+			}
+		}
+		return fmt.Errorf("%d file(s) have conflicts and were left as they are, merge the scaffold changes by hand and run upgrade again", len(result.Conflicts))
+	}
+
+	if !viper.GetBool("dry-run") {
+		fmt.Printf("\n✅ Upgraded to scaffold version %d\n", result.To)
+	}
+	return nil
+}
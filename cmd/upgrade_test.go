@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/rebelopsio/argo-helper/scaffold"
+)
+
+func TestUpgradeCommand(t *testing.T) {
+	dir := t.TempDir()
+	if err := executeCommand(newInitCmd(), dir, "--project", "demo"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := executeCommand(newUpgradeCmd(), dir); err != nil {
+		t.Fatalf("Expected a new scaffold to be up to date: %v", err)
+	}
+
+	// A repository from before values.schema.json and the manifest
+	for _, path := range []string{"values.schema.json", scaffold.ManifestFile} {
+		if err := os.Remove(filepath.Join(dir, path)); err != nil {
+			t.Fatalf("Failed to remove %s: %v", path, err)
+		}
+	}
+
+	viper.Set("dry-run", true)
+	err := executeCommand(newUpgradeCmd(), dir)
+	viper.Set("dry-run", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, scaffold.ManifestFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected the dry run to write nothing, got %v", err)
+	}
+
+	if err := executeCommand(newUpgradeCmd(), dir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, path := range []string{"values.schema.json", scaffold.ManifestFile} {
+		if _, err := os.Stat(filepath.Join(dir, path)); err != nil {
+			t.Errorf("Expected %s to be created: %v", path, err)
+		}
+	}
+
+	if err := executeCommand(newUpgradeCmd(), t.TempDir()); err == nil {
+		t.Errorf("Expected an error for a directory without a scaffold")
+	}
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rebelopsio/argo-helper/chart"
+)
+
+// validateOptions holds the flags of the validate command
+type validateOptions struct {
+	env string
+}
+
+// newValidateCmd creates the validate command
+func newValidateCmd() *cobra.Command {
+	opts := &validateOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "validate [path]",
+		Short: "Render the chart and check the ArgoCD resources it produces",
+		Long: `Render every template of the chart in path (default is the current
+directory) and check the result, without helm or a cluster:
+
+- values.yaml and every values/<env>/values.yaml must match values.schema.json
+- templates must render with the chart values
+- the rendered manifests must be valid YAML made of Kubernetes resources
+- Applications, ApplicationSets and AppProjects must match the ArgoCD CRD schemas
+
+The chart is rendered with values.yaml, overlaid with values/<env>/values.yaml
+when --env is set. Problems point to the template line that rendered them, or
+to the line of the values file that set the value.`,
+		Args: cobra.MaximumNArgs(1),
+		// Problems in the chart are not usage errors
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runValidate(cmd, args, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.env, "env", "", "environment whose values/<env>/values.yaml is applied")
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newValidateCmd())
+}
+
+func runValidate(cmd *cobra.Command, args []string, opts *validateOptions) error {
+	c, values, err := loadChart(args, opts.env)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	problems := append(c.ValidateValues(), c.Validate(values, chart.RenderOptions{Env: opts.env})...)
+	for _, problem := range problems {
+		fmt.Fprintln(out, problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("validation failed with %d problem(s)", len(problems))
+	}
+
+	fmt.Fprintf(out, "✅ %d template(s) rendered and validated\n", len(c.Templates))
+	return nil
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateCommand(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := executeCommand(newInitCmd(), dir, "--project", "demo", "--examples"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The example application needs a repository URL
+	if err := executeCommand(newValidateCmd(), dir, "--env", "dev"); err == nil {
+		t.Errorf("Expected error for the missing repository URL")
+	}
+
+	valuesPath := filepath.Join(dir, "values.yaml")
+	data, err := os.ReadFile(valuesPath)
+	if err != nil {
+		t.Fatalf("Failed to read values file: %v", err)
+	}
+	data = []byte(strings.Replace(string(data), `repoURL: ""`, `repoURL: https://example.com/repo.git`, 1))
+	if err := os.WriteFile(valuesPath, data, 0644); err != nil {
+		t.Fatalf("Failed to write values file: %v", err)
+	}
+
+	if err := executeCommand(newValidateCmd(), dir, "--env", "dev"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	// Every environment values file is checked against values.schema.json
+	prodValues := filepath.Join(dir, "values", "prod", "values.yaml")
+	if err := os.WriteFile(prodValues, []byte("global:\n  targetRevison: v1.0.0\n"), 0644); err != nil {
+		t.Fatalf("Failed to write values file: %v", err)
+	}
+	if err := executeCommand(newValidateCmd(), dir, "--env", "dev"); err == nil {
+		t.Errorf("Expected error for an unknown field in the prod values")
+	}
+
+	if err := executeCommand(newValidateCmd(), dir, "--env", "staging"); err == nil {
+		t.Errorf("Expected error for an unknown environment")
+	}
+}
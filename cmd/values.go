@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rebelopsio/argo-helper/scaffold"
+	"github.com/rebelopsio/argo-helper/yamledit"
+)
+
+// newValuesCmd creates the values command
+func newValuesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "values",
+		Short: "Edit the values files of the chart",
+		Long: `Edit values.yaml and the values/<env>/values.yaml files of the chart in place.
+
+Edits keep the comments, key order and formatting of the files: only the edited
+lines change, and setting a value to what it already is changes nothing.`,
+	}
+
+	cmd.AddCommand(newValuesSetCmd())
+
+	return cmd
+}
+
+// valuesSetOptions holds the flags of the values set command
+type valuesSetOptions struct {
+	chartDir string
+	env      string
+	str      bool
+}
+
+// newValuesSetCmd creates the values set command
+func newValuesSetCmd() *cobra.Command {
+	opts := &valuesSetOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "set <path> <value>",
+		Short: "Set a value in values.yaml or the values of an environment",
+		Long: `Set the value at a dotted path like applications.web.targetRevision, creating
+the mappings leading to it.
+
+The value is parsed as YAML, so 3 is a number, true a boolean and [a, b] a
+list. Use --string to set it as a string. Without --env, values.yaml is
+edited; with it, values/<env>/values.yaml, which must exist.`,
+		Args: cobra.ExactArgs(2),
+		// Problems in the values are not usage errors
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runValuesSet(cmd, args, opts)
+		},
+		Example: `  argo-helper values set applications.web.targetRevision v1.4.0 --env prod
+  argo-helper values set global.targetRevision main --dry-run
+  argo-helper values set applications.web.image.tag 1.10 --string`,
+	}
+
+	cmd.Flags().StringVar(&opts.chartDir, "chart", ".", "directory of the chart")
+	cmd.Flags().StringVarP(&opts.env, "env", "e", "", "environment whose values/<env>/values.yaml is edited (default is values.yaml)")
+	cmd.Flags().BoolVar(&opts.str, "string", false, "set the value as a string rather than parsing it as YAML")
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newValuesCmd())
+}
+
+func runValuesSet(cmd *cobra.Command, args []string, opts *valuesSetOptions) error {
+	fsys := afero.NewOsFs()
+	file := "values.yaml"
+	if opts.env != "" {
+		file = filepath.Join("values", opts.env, "values.yaml")
+	}
+	path := filepath.Join(opts.chartDir, file)
+
+	data, err := afero.ReadFile(fsys, path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	doc, err := yamledit.Parse(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	value, err := parseValue(args[1], opts.str)
+	if err != nil {
+		return err
+	}
+	changed, err := doc.Set(yamledit.SplitPath(args[0]), value)
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", file, err)
+	}
+
+	out := cmd.OutOrStdout()
+	plan := &scaffold.Plan{Files: []scaffold.File{{Path: file, Content: string(doc.Bytes())}}}
+	switch {
+	case !changed:
+		fmt.Fprintf(out, "Unchanged file: %s\n", file)
+	case viper.GetBool("dry-run"):
+		changes, err := plan.Changes(fsys, opts.chartDir)
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			fmt.Fprint(out, change.Diff)
+		}
+	default:
+		if err := plan.WriteFS(fsys, opts.chartDir); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Updated file: %s\n", file)
+	}
+	return nil
+}
+
+// parseValue parses a value given on the command line as YAML, or as a
+// string when asString is set or it is empty
+func parseValue(value string, asString bool) (*yaml.Node, error) {
+	str := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	if asString {
+		return str, nil
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &node); err != nil {
+		return nil, fmt.Errorf("failed to parse value %q: %w", value, err)
+	}
+	if len(node.Content) == 0 {
+		return str, nil
+	}
+	return node.Content[0], nil
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestValuesSetCommand(t *testing.T) {
+	dir := t.TempDir()
+	prod := filepath.Join(dir, "values", "prod", "values.yaml")
+	if err := os.MkdirAll(filepath.Dir(prod), 0755); err != nil {
+		t.Fatalf("Failed to create environment: %v", err)
+	}
+	original := "# Production\napplications:\n  web:\n    targetRevision: v1.0.0 # pinned\n"
+	if err := os.WriteFile(prod, []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to write prod values: %v", err)
+	}
+
+	set := func(args ...string) string {
+		t.Helper()
+		cmd := newValuesSetCmd()
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetArgs(append(args, "--chart", dir, "--env", "prod"))
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return out.String()
+	}
+
+	viper.Set("dry-run", true)
+	out := set("applications.web.targetRevision", "v1.1.0")
+	viper.Set("dry-run", false)
+	if !strings.Contains(out, "-    targetRevision: v1.0.0 # pinned\n+    targetRevision: v1.1.0 # pinned\n") {
+		t.Errorf("Expected the dry run to show the diff:\n%s", out)
+	}
+	if data, _ := os.ReadFile(prod); string(data) != original {
+		t.Errorf("Expected the dry run not to change %s", prod)
+	}
+
+	set("applications.web.targetRevision", "v1.1.0")
+	set("applications.web.replicas", "3")
+	set("applications.web.image.tag", "1.10", "--string")
+	expected := "# Production\napplications:\n  web:\n    targetRevision: v1.1.0 # pinned\n    replicas: 3\n    image:\n      tag: \"1.10\"\n"
+	if data, _ := os.ReadFile(prod); string(data) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, data)
+	}
+
+	if out := set("applications.web.replicas", "3"); !strings.Contains(out, "Unchanged file: values/prod/values.yaml") {
+		t.Errorf("Expected setting the same value to change nothing:\n%s", out)
+	}
+}
+
+func TestValuesSetCommandErrors(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "values.yaml"), []byte("global:\n  project: demo\n"), 0644); err != nil {
+		t.Fatalf("Failed to write values: %v", err)
+	}
+
+	if err := executeCommand(newValuesSetCmd(), "global.project.name", "x", "--chart", dir); err == nil || !strings.Contains(err.Error(), "global.project is not a mapping") {
+		t.Errorf("Expected an error setting a key below a scalar, got %v", err)
+	}
+	if err := executeCommand(newValuesSetCmd(), "global.project", "x", "--chart", dir, "--env", "qa"); err == nil {
+		t.Errorf("Expected an error for an environment without values")
+	}
+}
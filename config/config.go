@@ -0,0 +1,60 @@
+// Package config reads the argo-helper configuration shared by the CLI and
+// the TUI
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/rebelopsio/argo-helper/templates"
+)
+
+// Load reads the config file, ~/.argo-helper.yaml unless file is set, and
+// the environment into viper. It returns the config file used, empty when
+// none was found.
+func Load(file string) (string, error) {
+	if file != "" {
+		// Use config file from the flag.
+		viper.SetConfigFile(file)
+	} else {
+		// Find home directory.
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+
+		// Search config in home directory with name ".argo-helper" (without extension).
+		viper.AddConfigPath(home)
+		viper.SetConfigType("yaml")
+		viper.SetConfigName(".argo-helper")
+	}
+
+	viper.AutomaticEnv() // read in environment variables that match
+
+	// If a config file is found, read it in.
+	if err := viper.ReadInConfig(); err != nil {
+		return "", nil
+	}
+	return viper.ConfigFileUsed(), nil
+}
+
+// TemplateSet returns the scaffold templates, including the overrides from
+// the configured templates directory
+func TemplateSet() *templates.Set {
+	return templates.New(ExpandHome(viper.GetString("templates-dir")))
+}
+
+// ExpandHome expands a leading ~ in a path to the user's home directory
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
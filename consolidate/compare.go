@@ -0,0 +1,141 @@
+package consolidate
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rebelopsio/argo-helper/appset"
+	"github.com/rebelopsio/argo-helper/chart"
+	"github.com/rebelopsio/argo-helper/envdiff"
+	"github.com/rebelopsio/argo-helper/yamledit"
+)
+
+// Comparison is the result of rendering a group before and after it is
+// consolidated, with the values of an environment
+type Comparison struct {
+	// Env is the environment, empty for values.yaml alone
+	Env string
+	// Applications is the number of Applications rendered before
+	Applications int
+	// Generated is the number of Applications the ApplicationSet generates
+	Generated int
+	// Changes are the Applications rendered differently, empty when the
+	// ApplicationSet is equivalent
+	Changes []envdiff.ResourceChange
+}
+
+// Equivalent reports whether the ApplicationSet generates exactly the
+// Applications it replaces
+func (c Comparison) Equivalent() bool {
+	return len(c.Changes) == 0 && c.Applications == c.Generated
+}
+
+// Compare renders the Application templates of the group and the
+// Applications its ApplicationSet generates with the values of env, and
+// compares them. Keys are sorted and styles dropped before comparing, so
+// only differences in content are reported.
+func (g Group) Compare(c *chart.Chart, env string, opts appset.Options) (Comparison, error) {
+	comparison := Comparison{Env: env}
+	values, err := c.ValuesFor(env)
+	if err != nil {
+		return comparison, err
+	}
+
+	var before []chart.Manifest
+	for _, file := range c.Templates {
+		if !g.member(file.Name) {
+			continue
+		}
+		manifest, err := c.RenderFile(file, values, chart.RenderOptions{})
+		if err != nil {
+			return comparison, err
+		}
+		docs, err := manifest.Documents()
+		if err != nil {
+			return comparison, fmt.Errorf("failed to parse %s: %w", file.Name, err)
+		}
+		for _, doc := range docs {
+			content, err := canonical(doc)
+			if err != nil {
+				return comparison, fmt.Errorf("failed to encode %s: %w", file.Name, err)
+			}
+			before = append(before, chart.Manifest{Name: file.Name, Content: content})
+		}
+	}
+	comparison.Applications = len(before)
+
+	manifest, err := c.RenderFile(chart.File{Name: g.File, Data: []byte(g.Content)}, values, chart.RenderOptions{})
+	if err != nil {
+		return comparison, err
+	}
+	docs, err := manifest.Documents()
+	if err != nil {
+		return comparison, fmt.Errorf("failed to parse %s: %w", g.File, err)
+	}
+	var after []chart.Manifest
+	for _, doc := range docs {
+		namespace := yamledit.Scalar(yamledit.Lookup(doc.Content[0], "metadata"), "namespace")
+		apps, err := appset.Generate(doc, opts)
+		if err != nil {
+			return comparison, fmt.Errorf("ApplicationSet %s: %w", g.Name, err)
+		}
+		for _, app := range apps {
+			content, err := canonical(application(app, namespace))
+			if err != nil {
+				return comparison, fmt.Errorf("failed to encode %s: %w", app.Name, err)
+			}
+			after = append(after, chart.Manifest{Name: g.File, Content: content})
+		}
+	}
+	comparison.Generated = len(after)
+
+	comparison.Changes, err = envdiff.Manifests("before", before, "after", after)
+	return comparison, err
+}
+
+// member reports whether the template is one of the group
+func (g Group) member(name string) bool {
+	for _, file := range g.Files {
+		if file == name {
+			return true
+		}
+	}
+	return false
+}
+
+// application returns the Application resource ArgoCD creates for a
+// generated Application, in the namespace of its ApplicationSet
+func application(app appset.Application, namespace string) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	add := func(key string, value *yaml.Node) {
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+	}
+	add("apiVersion", &yaml.Node{Kind: yaml.ScalarNode, Value: "argoproj.io/v1alpha1"})
+	add("kind", &yaml.Node{Kind: yaml.ScalarNode, Value: "Application"})
+
+	metadata := &yaml.Node{Kind: yaml.MappingNode}
+	if m := yamledit.Lookup(app.Node, "metadata"); m != nil {
+		metadata.Content = append(metadata.Content, m.Content...)
+	}
+	if namespace != "" && yamledit.Lookup(metadata, "namespace") == nil {
+		metadata.Content = append(metadata.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: "namespace"},
+			&yaml.Node{Kind: yaml.ScalarNode, Value: namespace})
+	}
+	add("metadata", metadata)
+	if spec := yamledit.Lookup(app.Node, "spec"); spec != nil {
+		add("spec", spec)
+	}
+	return node
+}
+
+// canonical returns a resource as YAML with sorted keys
+func canonical(node *yaml.Node) (string, error) {
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return "", err
+	}
+	data, err := yaml.Marshal(value)
+	return string(data), err
+}
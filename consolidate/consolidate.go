@@ -0,0 +1,511 @@
+// Package consolidate finds Application templates of a chart that only
+// differ in a few values, typically their name, path and namespace, and
+// rewrites each group as one ApplicationSet. Rewrites are checked by
+// rendering the Applications before and after, so they can be applied with
+// confidence.
+package consolidate
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/afero"
+
+	"github.com/rebelopsio/argo-helper/appset"
+	"github.com/rebelopsio/argo-helper/chart"
+	"github.com/rebelopsio/argo-helper/scaffold"
+)
+
+// Generators of the ApplicationSets
+const (
+	GeneratorList = "list"
+	GeneratorGit  = "git-directories"
+)
+
+// keyPattern matches a key: value line, the value being optional
+var keyPattern = regexp.MustCompile(`^(- )?([A-Za-z0-9_./-]+):(?:[ \t]+(.*))?$`)
+
+// indentPattern matches the indentation of Helm functions, which grows when
+// lines move into the ApplicationSet template
+var indentPattern = regexp.MustCompile(`\b(n?indent) (\d+)`)
+
+// invalidNamePattern matches what is not allowed in a resource name
+var invalidNamePattern = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// Options configures the search
+type Options struct {
+	// MinSize is the smallest group of Applications consolidated, defaults
+	// to 2
+	MinSize int
+	// AppSet is how the ApplicationSets are evaluated: git directories are
+	// only used when they match the directories of the repository
+	AppSet appset.Options
+}
+
+// Group is a group of Application templates rewritten as one ApplicationSet
+type Group struct {
+	// Name is the name of the ApplicationSet
+	Name string
+	// Files are the Application templates, relative to the chart root
+	Files []string
+	// File is the ApplicationSet template replacing them
+	File string
+	// Generator is GeneratorList or GeneratorGit
+	Generator string
+	// Params are the generator parameters: the values the Applications
+	// differ in
+	Params []string
+	// Content is the ApplicationSet template
+	Content string
+	// Notes explain the choices made, like why git directories were not used
+	Notes []string
+}
+
+// Find returns the groups of Application templates of the chart whose lines
+// only differ in scalar values, other than their kind and namespace, in the
+// order of their first template
+func Find(c *chart.Chart, opts Options) ([]Group, error) {
+	if opts.MinSize < 2 {
+		opts.MinSize = 2
+	}
+
+	bySkeleton := map[string][]*template{}
+	var order []string
+	for _, file := range c.Templates {
+		t := parse(file)
+		if t == nil {
+			continue
+		}
+		key := t.skeleton()
+		if _, ok := bySkeleton[key]; !ok {
+			order = append(order, key)
+		}
+		bySkeleton[key] = append(bySkeleton[key], t)
+	}
+
+	taken := map[string]bool{}
+	for _, file := range c.Templates {
+		taken[file.Name] = true
+	}
+
+	var groups []Group
+	for _, key := range order {
+		members := bySkeleton[key]
+		if len(members) < opts.MinSize {
+			continue
+		}
+		group, ok, err := consolidate(members, taken, opts)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			taken[group.File] = true
+			groups = append(groups, group)
+		}
+	}
+	return groups, nil
+}
+
+// template is an Application template split into lines
+type template struct {
+	file  string
+	lines []line
+}
+
+// line is a line of a template
+type line struct {
+	text   string
+	indent int
+	// item is set for the first key of a sequence item, - key: value
+	item bool
+	// key is empty for lines that are not key: value lines
+	key string
+	// value is the text of a scalar value, empty when the key opens a block
+	value string
+	// path are the keys leading to the line, including its own
+	path []string
+}
+
+// scalar reports whether the line is a key with a scalar value
+func (l line) scalar() bool {
+	return l.key != "" && l.value != "" && !strings.HasPrefix(l.value, "|") && !strings.HasPrefix(l.value, ">")
+}
+
+func (l line) is(keys ...string) bool {
+	return strings.Join(l.path, ".") == strings.Join(keys, ".")
+}
+
+// parse splits an Application template into lines, nil when it is not a
+// plain Application: a single document of apiVersion, kind, metadata and
+// spec
+func parse(file chart.File) *template {
+	if !strings.HasSuffix(file.Name, ".yaml") && !strings.HasSuffix(file.Name, ".yml") {
+		return nil
+	}
+
+	t := &template{file: file.Name}
+	type level struct {
+		indent int
+		key    string
+	}
+	var stack []level
+	kind := ""
+	for _, text := range strings.Split(strings.TrimRight(string(file.Data), "\n"), "\n") {
+		l := line{text: text}
+		trimmed := strings.TrimLeft(text, " ")
+		l.indent = len(text) - len(trimmed)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			t.lines = append(t.lines, l)
+			continue
+		}
+		if trimmed == "---" {
+			return nil
+		}
+
+		match := keyPattern.FindStringSubmatch(trimmed)
+		if match == nil {
+			if l.indent == 0 {
+				// Helm control structures around the whole resource
+				return nil
+			}
+			t.lines = append(t.lines, l)
+			continue
+		}
+
+		l.item, l.key, l.value = match[1] != "", match[2], strings.TrimSpace(match[3])
+		if l.item {
+			l.indent += 2
+		}
+		for len(stack) > 0 && stack[len(stack)-1].indent >= l.indent {
+			stack = stack[:len(stack)-1]
+		}
+		for _, s := range stack {
+			l.path = append(l.path, s.key)
+		}
+		l.path = append(l.path, l.key)
+		if l.value == "" {
+			stack = append(stack, level{l.indent, l.key})
+		}
+
+		if l.indent == 0 {
+			switch l.key {
+			case "apiVersion":
+				if !strings.HasPrefix(l.value, "argoproj.io/") {
+					return nil
+				}
+			case "kind":
+				kind = l.value
+			case "metadata", "spec":
+			default:
+				return nil
+			}
+		}
+		t.lines = append(t.lines, l)
+	}
+	if kind != "Application" {
+		return nil
+	}
+	return t
+}
+
+// skeleton returns the template with the scalar values left out, templates
+// with the same skeleton only differ in values
+func (t *template) skeleton() string {
+	var b strings.Builder
+	for _, l := range t.lines {
+		if l.scalar() {
+			fmt.Fprintf(&b, "%d %s:\n", l.indent, strings.Join(l.path, "."))
+		} else {
+			b.WriteString(l.text + "\n")
+		}
+	}
+	return b.String()
+}
+
+// field is a line whose value differs between the Applications
+type field struct {
+	index  int
+	param  string
+	values []string
+}
+
+// consolidate rewrites templates with the same skeleton as an
+// ApplicationSet, ok is false when they cannot be
+func consolidate(members []*template, taken map[string]bool, opts Options) (Group, bool, error) {
+	first := members[0]
+	var fields []field
+	params := map[string]bool{}
+	for i, l := range first.lines {
+		values := make([]string, len(members))
+		differs := false
+		for j, member := range members {
+			values[j] = member.lines[i].value
+			differs = differs || member.lines[i].text != l.text
+		}
+		if !differs {
+			continue
+		}
+		if !l.scalar() || l.is("metadata", "namespace") || l.indent == 0 {
+			return Group{}, false, nil
+		}
+		fields = append(fields, field{index: i, param: paramName(l, params), values: values})
+	}
+	if len(fields) == 0 {
+		// Identical templates render the same Application twice
+		return Group{}, false, nil
+	}
+
+	group := Group{Generator: GeneratorList}
+	for _, member := range members {
+		group.Files = append(group.Files, member.file)
+	}
+	for _, f := range fields {
+		group.Params = append(group.Params, f.param)
+	}
+
+	paths := sourcePaths(first, fields)
+	group.Name = name(paths)
+	dir := path.Dir(first.file)
+	group.File = path.Join(dir, scaffold.FileName(scaffold.TypeApplicationSet, group.Name))
+	for i := 2; taken[group.File]; i++ {
+		group.File = path.Join(dir, scaffold.FileName(scaffold.TypeApplicationSet, group.Name+"-"+strconv.Itoa(i)))
+	}
+	group.Name = strings.TrimSuffix(strings.TrimPrefix(path.Base(group.File), scaffold.TypeApplicationSet+"-"), ".yaml")
+
+	replacements := map[int]string{}
+	generator := ""
+	if paths != nil {
+		var reason string
+		var err error
+		if generator, reason, err = gitGenerator(first, fields, paths, opts.AppSet); err != nil {
+			return Group{}, false, err
+		}
+		if generator != "" {
+			group.Generator = GeneratorGit
+			group.Params = []string{"path", "path.basename"}
+			for _, f := range fields {
+				replacements[f.index] = basenameValue(f.values[0], path.Base(paths[0]))
+				if first.lines[f.index].is("spec", "source", "path") {
+					replacements[f.index] = `'{{ "{{ path }}" }}'`
+				}
+			}
+		} else {
+			group.Notes = append(group.Notes, "list generator used: "+reason)
+		}
+	}
+	if generator == "" {
+		generator = listGenerator(fields)
+		for _, f := range fields {
+			replacements[f.index] = fmt.Sprintf(`'{{ "{{ %s }}" }}'`, f.param)
+		}
+	}
+
+	group.Content = render(first, group.Name, generator, replacements)
+	return group, true, nil
+}
+
+// paramName returns a generator parameter name for a line: its key, or its
+// parent key and its key when the key is taken
+func paramName(l line, taken map[string]bool) string {
+	name := l.key
+	if taken[name] && len(l.path) > 1 {
+		parent := l.path[len(l.path)-2]
+		name = parent + strings.ToUpper(name[:1]) + name[1:]
+	}
+	for i := 2; taken[name]; i++ {
+		name = fmt.Sprintf("%s%d", l.key, i)
+	}
+	taken[name] = true
+	return name
+}
+
+// sourcePaths returns the source paths of the Applications when they differ
+// and are plain paths sharing a parent directory, nil otherwise
+func sourcePaths(t *template, fields []field) []string {
+	for _, f := range fields {
+		if !t.lines[f.index].is("spec", "source", "path") {
+			continue
+		}
+		paths := make([]string, len(f.values))
+		for i, value := range f.values {
+			paths[i] = unquote(value)
+			if strings.ContainsAny(paths[i], "{}*?[") || path.Dir(paths[i]) != path.Dir(paths[0]) || path.Dir(paths[0]) == "." {
+				return nil
+			}
+		}
+		return paths
+	}
+	return nil
+}
+
+// name returns the name of the ApplicationSet: the parent directory of the
+// source paths, or apps
+func name(paths []string) string {
+	if paths == nil {
+		return "apps"
+	}
+	name := invalidNamePattern.ReplaceAllString(strings.ToLower(path.Base(path.Dir(paths[0]))), "-")
+	if name = strings.Trim(name, "-"); name == "" {
+		return "apps"
+	}
+	return name
+}
+
+// gitGenerator returns a git directories generator for Applications whose
+// varying values all derive from the base name of their source path, and
+// whose source directories are exactly those of the repository below their
+// parent. Otherwise it returns the reason it cannot be used.
+func gitGenerator(t *template, fields []field, paths []string, opts appset.Options) (string, string, error) {
+	for _, f := range fields {
+		l := t.lines[f.index]
+		if l.is("spec", "source", "repoURL") || l.is("spec", "source", "targetRevision") {
+			return "", "the Applications differ in " + l.key, nil
+		}
+		value := basenameValue(f.values[0], path.Base(paths[0]))
+		for i := range f.values {
+			if value == "" || basenameValue(f.values[i], path.Base(paths[i])) != value {
+				return "", fmt.Sprintf("%s is not derived from the directory name of the source path", l.key), nil
+			}
+		}
+	}
+
+	parent := path.Dir(paths[0])
+	fsys := opts.Fs
+	if fsys == nil {
+		fsys = afero.NewOsFs()
+	}
+	repoDir := opts.RepoDir
+	if repoDir == "" {
+		repoDir = "."
+	}
+	entries, err := afero.ReadDir(fsys, path.Join(repoDir, parent))
+	if err != nil {
+		return "", fmt.Sprintf("%s is not a directory of the repository", parent), nil
+	}
+	var dirs, bases []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			dirs = append(dirs, entry.Name())
+		}
+	}
+	for _, p := range paths {
+		bases = append(bases, path.Base(p))
+	}
+	sort.Strings(bases)
+	if strings.Join(dirs, ",") != strings.Join(bases, ",") {
+		return "", fmt.Sprintf("the directories of %s are not exactly the source paths", parent), nil
+	}
+
+	var repoURL, revision string
+	for _, l := range t.lines {
+		switch {
+		case l.is("spec", "source", "repoURL"):
+			repoURL = l.value
+		case l.is("spec", "source", "targetRevision"):
+			revision = l.value
+		}
+	}
+	if repoURL == "" {
+		return "", "the Applications have no source repoURL", nil
+	}
+	if revision == "" {
+		revision = "HEAD"
+	}
+
+	var b strings.Builder
+	b.WriteString("    - git:\n")
+	fmt.Fprintf(&b, "        repoURL: %s\n", repoURL)
+	fmt.Fprintf(&b, "        revision: %s\n", revision)
+	b.WriteString("        directories:\n")
+	fmt.Fprintf(&b, "          - path: %s/*\n", parent)
+	return b.String(), "", nil
+}
+
+// basenameValue returns the value with the base name of the source path
+// replaced by the path.basename parameter, as a single-quoted scalar. It is
+// empty when the value does not contain the base name outside a Helm action.
+func basenameValue(value, base string) string {
+	value = unquote(value)
+	i := strings.Index(value, base)
+	if i < 0 {
+		return ""
+	}
+	prefix, suffix := value[:i], value[i+len(base):]
+	if strings.Count(prefix, "{{") != strings.Count(prefix, "}}") || strings.ContainsAny(prefix+suffix, `'`) {
+		return ""
+	}
+	return fmt.Sprintf(`'%s{{ "{{ path.basename }}" }}%s'`, prefix, suffix)
+}
+
+// listGenerator returns a list generator with an element per Application,
+// holding the values it differs in
+func listGenerator(fields []field) string {
+	var b strings.Builder
+	b.WriteString("    - list:\n")
+	b.WriteString("        elements:\n")
+	for i := range fields[0].values {
+		for j, f := range fields {
+			marker := "  "
+			if j == 0 {
+				marker = "- "
+			}
+			fmt.Fprintf(&b, "          %s%s: %s\n", marker, f.param, f.values[i])
+		}
+	}
+	return b.String()
+}
+
+// render returns the ApplicationSet template: the metadata and spec of the
+// template moved below spec.template, with the varying values replaced
+func render(t *template, name, generator string, replacements map[int]string) string {
+	namespace := "argocd"
+	for _, l := range t.lines {
+		if l.is("metadata", "namespace") {
+			namespace = l.value
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("apiVersion: argoproj.io/v1alpha1\n")
+	b.WriteString("kind: ApplicationSet\n")
+	b.WriteString("metadata:\n")
+	fmt.Fprintf(&b, "  name: %s\n", name)
+	fmt.Fprintf(&b, "  namespace: %s\n", namespace)
+	b.WriteString("spec:\n")
+	b.WriteString("  generators:\n")
+	b.WriteString(generator)
+	b.WriteString("  template:\n")
+
+	for i, l := range t.lines {
+		if l.indent == 0 && l.key == "" || l.is("apiVersion") || l.is("kind") || l.is("metadata", "namespace") {
+			continue
+		}
+		if value, ok := replacements[i]; ok {
+			marker := ""
+			if l.item {
+				marker = "- "
+			}
+			fmt.Fprintf(&b, "%s%s%s: %s\n", strings.Repeat(" ", l.indent+4-len(marker)), marker, l.key, value)
+			continue
+		}
+		text := indentPattern.ReplaceAllStringFunc(l.text, func(s string) string {
+			m := indentPattern.FindStringSubmatch(s)
+			n, _ := strconv.Atoi(m[2])
+			return fmt.Sprintf("%s %d", m[1], n+4)
+		})
+		b.WriteString("    " + text + "\n")
+	}
+	return b.String()
+}
+
+// unquote strips the quotes of a quoted scalar without escapes
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0] && !strings.Contains(value, `\`) {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
@@ -0,0 +1,180 @@
+package consolidate
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+
+	"github.com/rebelopsio/argo-helper/appset"
+	"github.com/rebelopsio/argo-helper/chart"
+)
+
+// applicationTemplate is an Application template differing in its name,
+// path and namespace
+func applicationTemplate(name, revision string) string {
+	return fmt.Sprintf(`apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: {{ .Values.prefix }}-%[1]s
+  namespace: argocd
+  labels:
+    {{- toYaml .Values.labels | nindent 4 }}
+spec:
+  project: default
+  source:
+    repoURL: https://github.com/acme/apps.git
+    targetRevision: %[2]s
+    path: services/%[1]s
+  destination:
+    server: https://kubernetes.default.svc
+    namespace: %[1]s
+`, name, revision)
+}
+
+func loadChart(t *testing.T, fsys afero.Fs, files map[string]string) *chart.Chart {
+	t.Helper()
+	files["repo/chart/Chart.yaml"] = "apiVersion: v2\nname: apps\nversion: 0.1.0\n"
+	files["repo/chart/values.yaml"] = "prefix: demo\nlabels:\n  team: platform\n"
+	files["repo/chart/values/prod/values.yaml"] = "prefix: prod\n"
+	for path, content := range files {
+		if err := afero.WriteFile(fsys, path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	c, err := chart.Load(fsys, "repo/chart")
+	if err != nil {
+		t.Fatalf("Failed to load chart: %v", err)
+	}
+	return c
+}
+
+func TestFindListGenerator(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	c := loadChart(t, fsys, map[string]string{
+		"repo/chart/templates/apps/billing.yaml": applicationTemplate("billing", "HEAD"),
+		"repo/chart/templates/apps/orders.yaml":  applicationTemplate("orders", "v1.2.0"),
+		"repo/chart/templates/apps/other.yaml":   strings.Replace(applicationTemplate("other", "HEAD"), "  project: default\n", "", 1),
+		"repo/chart/templates/configmap.yaml":    "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n",
+	})
+	opts := Options{AppSet: appset.Options{Fs: fsys, RepoDir: "repo"}}
+
+	groups, err := Find(c, opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(groups) != 1 {
+		t.Fatalf("Expected 1 group, got %d", len(groups))
+	}
+	group := groups[0]
+
+	if group.Name != "services" || group.File != "templates/apps/applicationset-services.yaml" || group.Generator != GeneratorList {
+		t.Errorf("Unexpected group %s in %s with %s generator", group.Name, group.File, group.Generator)
+	}
+	if expected := []string{"templates/apps/billing.yaml", "templates/apps/orders.yaml"}; !reflect.DeepEqual(group.Files, expected) {
+		t.Errorf("Expected files %v, got %v", expected, group.Files)
+	}
+	if expected := []string{"name", "targetRevision", "path", "namespace"}; !reflect.DeepEqual(group.Params, expected) {
+		t.Errorf("Expected params %v, got %v", expected, group.Params)
+	}
+	if len(group.Notes) != 1 || !strings.Contains(group.Notes[0], "differ in targetRevision") {
+		t.Errorf("Expected a note about targetRevision, got %v", group.Notes)
+	}
+	for _, expected := range []string{
+		"          - name: {{ .Values.prefix }}-billing\n            targetRevision: HEAD\n",
+		"      name: '{{ \"{{ name }}\" }}'\n",
+		"        {{- toYaml .Values.labels | nindent 8 }}\n",
+	} {
+		if !strings.Contains(group.Content, expected) {
+			t.Errorf("Expected ApplicationSet to contain %q, got:\n%s", expected, group.Content)
+		}
+	}
+
+	for _, env := range []string{"", "prod"} {
+		comparison, err := group.Compare(c, env, opts.AppSet)
+		if err != nil {
+			t.Fatalf("Unexpected error comparing %q: %v", env, err)
+		}
+		if !comparison.Equivalent() || comparison.Applications != 2 {
+			t.Errorf("Expected 2 identical Applications for %q, got %d, %d and %v", env, comparison.Applications, comparison.Generated, comparison.Changes)
+		}
+	}
+}
+
+func TestFindGitGenerator(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	c := loadChart(t, fsys, map[string]string{
+		"repo/chart/templates/apps/billing.yaml":   applicationTemplate("billing", "HEAD"),
+		"repo/chart/templates/apps/orders.yaml":    applicationTemplate("orders", "HEAD"),
+		"repo/services/billing/kustomization.yaml": "resources: []\n",
+		"repo/services/orders/kustomization.yaml":  "resources: []\n",
+	})
+	opts := Options{AppSet: appset.Options{Fs: fsys, RepoDir: "repo"}}
+
+	groups, err := Find(c, opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(groups) != 1 || groups[0].Generator != GeneratorGit {
+		t.Fatalf("Expected a git directories group, got %+v", groups)
+	}
+	group := groups[0]
+	for _, expected := range []string{
+		"          - path: services/*\n",
+		"      name: '{{ .Values.prefix }}-{{ \"{{ path.basename }}\" }}'\n",
+		"        path: '{{ \"{{ path }}\" }}'\n",
+	} {
+		if !strings.Contains(group.Content, expected) {
+			t.Errorf("Expected ApplicationSet to contain %q, got:\n%s", expected, group.Content)
+		}
+	}
+
+	comparison, err := group.Compare(c, "prod", opts.AppSet)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !comparison.Equivalent() {
+		t.Errorf("Expected identical Applications, got %v", comparison.Changes)
+	}
+
+	// A directory without an Application would add one
+	if err := fsys.MkdirAll("repo/services/payments", 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	groups, err = Find(c, opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(groups) != 1 || groups[0].Generator != GeneratorList {
+		t.Fatalf("Expected a list group, got %+v", groups)
+	}
+}
+
+func TestCompareChanges(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	c := loadChart(t, fsys, map[string]string{
+		"repo/chart/templates/apps/billing.yaml": applicationTemplate("billing", "HEAD"),
+		"repo/chart/templates/apps/orders.yaml":  applicationTemplate("orders", "HEAD"),
+	})
+	opts := Options{AppSet: appset.Options{Fs: fsys, RepoDir: "repo"}}
+
+	groups, err := Find(c, opts)
+	if err != nil || len(groups) != 1 {
+		t.Fatalf("Expected 1 group, got %d and %v", len(groups), err)
+	}
+	group := groups[0]
+	group.Content = strings.Replace(group.Content, "namespace: '{{ \"{{ namespace }}\" }}'", "namespace: shared", 1)
+
+	comparison, err := group.Compare(c, "", opts.AppSet)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if comparison.Equivalent() || len(comparison.Changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %v", comparison.Changes)
+	}
+	if diff := comparison.Changes[0].Diff; !strings.Contains(diff, "-    namespace: billing\n+    namespace: shared\n") {
+		t.Errorf("Unexpected diff:\n%s", diff)
+	}
+}
@@ -0,0 +1,265 @@
+// Package doctor checks that a repository still has the structure init set
+// up: its directories, Chart.yaml, the helpers and values its templates use
+// and the values files of its environments. Issues that can be fixed
+// without guessing, like a missing directory, come with a scaffold plan
+// fixing them.
+package doctor
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rebelopsio/argo-helper/chart"
+	"github.com/rebelopsio/argo-helper/scaffold"
+	"github.com/rebelopsio/argo-helper/templates"
+)
+
+// Checks run by the doctor, in order
+const (
+	CheckDirectories  = "directories"
+	CheckChart        = "chart"
+	CheckHelpers      = "helpers"
+	CheckValues       = "values"
+	CheckEnvironments = "environments"
+)
+
+// Checks are the checks in the order they run
+var Checks = []string{CheckDirectories, CheckChart, CheckHelpers, CheckValues, CheckEnvironments}
+
+// helpersFile is where init puts the named templates
+const helpersFile = "templates/_helpers.tpl"
+
+// semverPattern matches a SemVer 2 version, as Helm requires for charts
+var semverPattern = regexp.MustCompile(`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+
+// Issue is a difference between the repository and the scaffold
+type Issue struct {
+	// Check is the check reporting the issue
+	Check string
+	// Path is the file or directory, relative to the repository root
+	Path string
+	// Line is the line in Path, 0 when the issue is about the whole file
+	Line    int
+	Message string
+	// Fixable reports whether Result.Fixes fixes the issue
+	Fixable bool
+}
+
+func (i Issue) String() string {
+	location := i.Path
+	if i.Line > 0 {
+		location = fmt.Sprintf("%s:%d", i.Path, i.Line)
+	}
+	return location + ": " + i.Message
+}
+
+// Options configures a doctor run
+type Options struct {
+	// Fs is the filesystem, defaults to the OS filesystem
+	Fs afero.Fs
+	// Root is the repository root, defaults to the current directory
+	Root string
+	// Templates overrides the built-in templates the fixes are rendered
+	// from when set
+	Templates *templates.Set
+}
+
+// Result is the outcome of a doctor run
+type Result struct {
+	// Issues are in the order of Checks
+	Issues []Issue
+	// Fixes is the plan fixing the fixable issues, relative to the root
+	Fixes *scaffold.Plan
+}
+
+// Fixable returns the number of fixable issues
+func (r *Result) Fixable() int {
+	fixable := 0
+	for _, issue := range r.Issues {
+		if issue.Fixable {
+			fixable++
+		}
+	}
+	return fixable
+}
+
+// doctor holds the state of a run
+type doctor struct {
+	opts   Options
+	fs     afero.Fs
+	result *Result
+}
+
+// Run checks the repository and plans the fixes of the safe issues
+func Run(opts Options) (*Result, error) {
+	if opts.Root == "" {
+		opts.Root = "."
+	}
+	d := &doctor{opts: opts, fs: opts.Fs, result: &Result{Fixes: &scaffold.Plan{}}}
+	if d.fs == nil {
+		d.fs = afero.NewOsFs()
+	}
+
+	scaffoldPlan, err := scaffold.PlanInit(scaffold.InitOptions{Project: d.project(), Templates: opts.Templates})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.checkDirectories(scaffoldPlan.Dirs); err != nil {
+		return nil, err
+	}
+	c, err := d.checkChart()
+	if err != nil {
+		return nil, err
+	}
+	if c != nil {
+		if err := d.checkTemplates(c, scaffoldPlan); err != nil {
+			return nil, err
+		}
+	}
+	if err := d.checkEnvironments(); err != nil {
+		return nil, err
+	}
+	return d.result, nil
+}
+
+func (d *doctor) report(issue Issue) {
+	d.result.Issues = append(d.result.Issues, issue)
+}
+
+func (d *doctor) path(name string) string {
+	return filepath.Join(d.opts.Root, filepath.FromSlash(name))
+}
+
+// project returns the project name the fixes are rendered with: the global
+// project of values.yaml, or the chart name
+func (d *doctor) project() string {
+	values, err := chart.ReadValues(d.fs, d.path("values.yaml"))
+	if err == nil {
+		if global, ok := values["global"].(map[string]interface{}); ok {
+			if project, ok := global["project"].(string); ok && project != "" {
+				return project
+			}
+		}
+	}
+	var metadata chart.Metadata
+	if data, err := afero.ReadFile(d.fs, d.path("Chart.yaml")); err == nil && yaml.Unmarshal(data, &metadata) == nil && metadata.Name != "" {
+		return metadata.Name
+	}
+	return "default"
+}
+
+// checkDirectories reports the directories of the scaffold that are missing
+func (d *doctor) checkDirectories(dirs []string) error {
+	for _, dir := range dirs {
+		info, err := d.fs.Stat(d.path(dir))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			d.report(Issue{Check: CheckDirectories, Path: dir, Message: "directory is missing", Fixable: true})
+			d.result.Fixes.Dirs = append(d.result.Fixes.Dirs, dir)
+		case err != nil:
+			return fmt.Errorf("failed to check %s: %w", dir, err)
+		case !info.IsDir():
+			d.report(Issue{Check: CheckDirectories, Path: dir, Message: "is a file, expected a directory"})
+		}
+	}
+	return nil
+}
+
+// checkChart reports the missing and invalid fields of Chart.yaml and
+// returns the chart, nil when it cannot be loaded
+func (d *doctor) checkChart() (*chart.Chart, error) {
+	data, err := afero.ReadFile(d.fs, d.path("Chart.yaml"))
+	if errors.Is(err, fs.ErrNotExist) {
+		d.report(Issue{Check: CheckChart, Path: "Chart.yaml", Message: "is missing, run argo-helper init --skip-existing to create it"})
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Chart.yaml: %w", err)
+	}
+
+	var fields struct {
+		APIVersion string `yaml:"apiVersion"`
+		Name       string `yaml:"name"`
+		Version    string `yaml:"version"`
+		Type       string `yaml:"type"`
+	}
+	if err := yaml.Unmarshal(data, &fields); err != nil {
+		d.report(Issue{Check: CheckChart, Path: "Chart.yaml", Message: fmt.Sprintf("is not valid YAML: %v", err)})
+		return nil, nil
+	}
+	chartIssue := func(message string) {
+		d.report(Issue{Check: CheckChart, Path: "Chart.yaml", Message: message})
+	}
+	switch fields.APIVersion {
+	case "v2":
+	case "":
+		chartIssue("apiVersion is missing, expected v2")
+	default:
+		chartIssue(fmt.Sprintf("apiVersion is %s, expected v2", fields.APIVersion))
+	}
+	if fields.Name == "" {
+		chartIssue("name is missing")
+	}
+	switch {
+	case fields.Version == "":
+		chartIssue("version is missing")
+	case !semverPattern.MatchString(fields.Version):
+		chartIssue(fmt.Sprintf("version %s is not a SemVer version", fields.Version))
+	}
+	if fields.Type != "" && fields.Type != "application" {
+		chartIssue(fmt.Sprintf("type is %s, only application charts render manifests", fields.Type))
+	}
+
+	c, err := chart.Load(d.fs, d.opts.Root)
+	if err != nil {
+		d.report(Issue{Check: CheckChart, Path: "values.yaml", Message: err.Error()})
+		return nil, nil
+	}
+	return c, nil
+}
+
+// checkEnvironments reports the directories of values/ without a values
+// file, which commands taking an environment do not recognize
+func (d *doctor) checkEnvironments() error {
+	entries, err := afero.ReadDir(d.fs, d.path("values"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read environments: %w", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		env := entry.Name()
+		path := filepath.ToSlash(filepath.Join("values", env, "values.yaml"))
+		exists, err := afero.Exists(d.fs, d.path(path))
+		if err != nil {
+			return fmt.Errorf("failed to check %s: %w", path, err)
+		}
+		if exists {
+			continue
+		}
+		d.report(Issue{
+			Check:   CheckEnvironments,
+			Path:    path,
+			Message: fmt.Sprintf("environment %s has no values file", env),
+			Fixable: true,
+		})
+		d.result.Fixes.Files = append(d.result.Fixes.Files, scaffold.File{
+			Path:    path,
+			Content: fmt.Sprintf("# %s environment values for %s\n\nglobal:\n  environment: %s\n", env, d.project(), env),
+		})
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package doctor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/afero"
+
+	"github.com/rebelopsio/argo-helper/scaffold"
+)
+
+func scaffoldRepo(t *testing.T) afero.Fs {
+	t.Helper()
+	fsys := afero.NewMemMapFs()
+	plan, err := scaffold.PlanInit(scaffold.InitOptions{Project: "demo", Examples: true})
+	if err != nil {
+		t.Fatalf("Failed to plan init: %v", err)
+	}
+	if err := plan.WriteFS(fsys, "repo"); err != nil {
+		t.Fatalf("Failed to write scaffold: %v", err)
+	}
+	return fsys
+}
+
+func summary(result *Result) []string {
+	var issues []string
+	for _, issue := range result.Issues {
+		fixable := ""
+		if issue.Fixable {
+			fixable = " (fixable)"
+		}
+		issues = append(issues, issue.Check+" "+issue.String()+fixable)
+	}
+	return issues
+}
+
+func TestRunScaffold(t *testing.T) {
+	result, err := Run(Options{Fs: scaffoldRepo(t), Root: "repo"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Issues) != 0 {
+		t.Errorf("Expected no issues in a fresh scaffold, got %v", summary(result))
+	}
+}
+
+func TestRunIssues(t *testing.T) {
+	fsys := scaffoldRepo(t)
+	files := map[string]string{
+		"repo/Chart.yaml":             "apiVersion: v1\nname: demo\nversion: latest\n",
+		"repo/templates/_helpers.tpl": "{{- define \"custom.name\" -}}demo{{- end }}\n",
+		"repo/templates/apps/web.yaml": `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: {{ include "custom.name" . }}
+  labels:
+    {{- include "common.labels" . | nindent 4 }}
+    {{- include "team.labels" . | nindent 4 }}
+spec:
+  project: {{ .Values.global.project }}
+  source:
+    repoURL: {{ .Values.web.repoURL }}
+    targetRevision: {{ .Values.web.targetRevision | default "HEAD" }}
+    {{- if .Values.web.helm }}
+    helm:
+      releaseName: {{ .Values.web.helm.releaseName }}
+    {{- end }}
+  destination:
+    server: {{ $.Values.destination.server }}
+    namespace: {{ dig "web" "namespace" "web" .Values.applications }}
+`,
+	}
+	for path, content := range files {
+		if err := afero.WriteFile(fsys, path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	if err := fsys.RemoveAll("repo/templates/projects"); err != nil {
+		t.Fatalf("Failed to remove directory: %v", err)
+	}
+	if err := fsys.MkdirAll("repo/values/qa", 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	result, err := Run(Options{Fs: fsys, Root: "repo"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{
+		"directories templates/projects: directory is missing (fixable)",
+		"chart Chart.yaml: apiVersion is v1, expected v2",
+		"chart Chart.yaml: version latest is not a SemVer version",
+		`helpers templates/apps/example-app.yaml:4: helper "common.appName" is not defined (fixable)`,
+		`helpers templates/apps/example-app.yaml:7: helper "common.labels" is not defined (fixable)`,
+		`helpers templates/apps/example-app.yaml:9: helper "common.projectName" is not defined (fixable)`,
+		`helpers templates/apps/web.yaml:7: helper "team.labels" is not defined`,
+		"values templates/apps/web.yaml:11: .Values.web.repoURL is used but not defined in values.yaml or any environment",
+		"environments values/qa/values.yaml: environment qa has no values file (fixable)",
+	}
+	if issues := summary(result); !reflect.DeepEqual(issues, expected) {
+		t.Errorf("Expected issues:\n%v\ngot:\n%v", expected, issues)
+	}
+	if result.Fixable() != 5 {
+		t.Errorf("Expected 5 fixable issues, got %d", result.Fixable())
+	}
+
+	// Fixing leaves only the issues that need a decision
+	if err := result.Fixes.WriteFS(fsys, "repo"); err != nil {
+		t.Fatalf("Failed to write fixes: %v", err)
+	}
+	result, err = Run(Options{Fs: fsys, Root: "repo"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Issues) != 4 || result.Fixable() != 0 {
+		t.Errorf("Expected 4 issues left, got %v", summary(result))
+	}
+}
+
+func TestRunMissingChart(t *testing.T) {
+	fsys := scaffoldRepo(t)
+	if err := fsys.Remove("repo/Chart.yaml"); err != nil {
+		t.Fatalf("Failed to remove Chart.yaml: %v", err)
+	}
+
+	result, err := Run(Options{Fs: fsys, Root: "repo"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"chart Chart.yaml: is missing, run argo-helper init --skip-existing to create it"}
+	if issues := summary(result); !reflect.DeepEqual(issues, expected) {
+		t.Errorf("Expected issues %v, got %v", expected, issues)
+	}
+}
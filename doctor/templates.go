@@ -0,0 +1,338 @@
+package doctor
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"text/template/parse"
+
+	"github.com/rebelopsio/argo-helper/chart"
+	"github.com/rebelopsio/argo-helper/scaffold"
+)
+
+// optionalFuncs are the functions whose arguments may be undefined values
+var optionalFuncs = map[string]bool{
+	"default":  true,
+	"coalesce": true,
+	"dig":      true,
+	"empty":    true,
+	"hasKey":   true,
+	"ternary":  true,
+}
+
+// reference is a helper or a value a template uses
+type reference struct {
+	file string
+	line int
+	// name is the helper name, or the values path like global.project
+	name string
+}
+
+// scan is the helpers and values the templates define and use
+type scan struct {
+	defined map[string]bool
+	helpers []reference
+	values  []reference
+}
+
+// checkTemplates reports the helpers included by the templates that no
+// template defines, and the values they use that neither values.yaml nor
+// any environment defines. Helpers init creates are fixed by adding them to
+// _helpers.tpl.
+func (d *doctor) checkTemplates(c *chart.Chart, scaffoldPlan *scaffold.Plan) error {
+	s := &scan{defined: map[string]bool{}}
+	for _, file := range c.Templates {
+		if ext := path.Ext(file.Name); ext != ".yaml" && ext != ".yml" && ext != ".tpl" {
+			continue
+		}
+		if err := s.file(file); err != nil {
+			// Syntax errors are reported by validate
+			continue
+		}
+	}
+
+	builtin := builtinHelpers(scaffoldPlan)
+	var added []string
+	reported := map[string]bool{}
+	for _, ref := range s.helpers {
+		if s.defined[ref.name] || reported[ref.name] {
+			continue
+		}
+		reported[ref.name] = true
+		issue := Issue{Check: CheckHelpers, Path: ref.file, Line: ref.line, Message: fmt.Sprintf("helper %q is not defined", ref.name)}
+		if block, ok := builtin[ref.name]; ok {
+			issue.Fixable = true
+			added = append(added, block)
+		}
+		d.report(issue)
+	}
+	if len(added) > 0 {
+		d.addHelpers(c, added)
+	}
+
+	envs, err := c.Environments()
+	if err != nil {
+		return err
+	}
+	valuesSets := []map[string]interface{}{c.Values}
+	for _, env := range envs {
+		values, err := c.ValuesFor(env)
+		if err != nil {
+			return err
+		}
+		valuesSets = append(valuesSets, values)
+	}
+	reported = map[string]bool{}
+	for _, ref := range s.values {
+		if reported[ref.file+" "+ref.name] || definedIn(valuesSets, strings.Split(ref.name, ".")) {
+			continue
+		}
+		reported[ref.file+" "+ref.name] = true
+		d.report(Issue{
+			Check:   CheckValues,
+			Path:    ref.file,
+			Line:    ref.line,
+			Message: fmt.Sprintf(".Values.%s is used but not defined in values.yaml or any environment", ref.name),
+		})
+	}
+	return nil
+}
+
+// addHelpers plans the helper blocks at the end of _helpers.tpl
+func (d *doctor) addHelpers(c *chart.Chart, blocks []string) {
+	content := ""
+	for _, file := range c.Templates {
+		if file.Name == helpersFile {
+			content = strings.TrimRight(string(file.Data), "\n") + "\n\n"
+		}
+	}
+	content += strings.Join(blocks, "\n\n") + "\n"
+	d.result.Fixes.Files = append(d.result.Fixes.Files, scaffold.File{Path: helpersFile, Content: content})
+}
+
+// builtinHelpers returns the blocks of the helpers file created by init,
+// with their comment, by helper name
+func builtinHelpers(scaffoldPlan *scaffold.Plan) map[string]string {
+	blocks := map[string]string{}
+	for _, file := range scaffoldPlan.Files {
+		if file.Path != helpersFile {
+			continue
+		}
+		for _, block := range strings.Split(strings.TrimSpace(file.Content), "\n\n") {
+			tree := parse.New(file.Path)
+			tree.Mode = parse.SkipFuncCheck
+			trees := map[string]*parse.Tree{}
+			if _, err := tree.Parse(block, "{{", "}}", trees); err != nil {
+				continue
+			}
+			for name := range trees {
+				if name != file.Path {
+					blocks[name] = block
+				}
+			}
+		}
+	}
+	return blocks
+}
+
+// definedIn reports whether any of the values defines the path. Paths
+// going through a value that is not a mapping are considered defined.
+func definedIn(valuesSets []map[string]interface{}, keys []string) bool {
+	for _, values := range valuesSets {
+		var current interface{} = values
+		defined := true
+		for _, key := range keys {
+			m, ok := current.(map[string]interface{})
+			if !ok {
+				break
+			}
+			if current, ok = m[key]; !ok {
+				defined = false
+				break
+			}
+		}
+		if defined {
+			return true
+		}
+	}
+	return false
+}
+
+// file scans a template for the helpers it defines and the helpers and
+// values it uses
+func (s *scan) file(file chart.File) error {
+	text := string(file.Data)
+	tree := parse.New(file.Name)
+	tree.Mode = parse.SkipFuncCheck
+	trees := map[string]*parse.Tree{}
+	if _, err := tree.Parse(text, "{{", "}}", trees); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(trees))
+	for name := range trees {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name != file.Name {
+			s.defined[name] = true
+		}
+		w := &walker{scan: s, file: file.Name, text: text}
+		// Named templates are included with the chart root as the dot
+		w.list(trees[name].Root, true, nil)
+	}
+	return nil
+}
+
+// walker walks the nodes of a template
+type walker struct {
+	scan *scan
+	file string
+	text string
+}
+
+func (w *walker) line(node parse.Node) int {
+	pos := int(node.Position())
+	if pos > len(w.text) {
+		pos = len(w.text)
+	}
+	return strings.Count(w.text[:pos], "\n") + 1
+}
+
+// list walks a list of nodes. root reports whether the dot is the chart
+// root, guarded are the values paths checked by enclosing if and with.
+func (w *walker) list(list *parse.ListNode, root bool, guarded []string) {
+	if list == nil {
+		return
+	}
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.ActionNode:
+			w.pipe(n.Pipe, root, guarded, false)
+		case *parse.TemplateNode:
+			w.scan.helpers = append(w.scan.helpers, reference{file: w.file, line: w.line(n), name: n.Name})
+			w.pipe(n.Pipe, root, guarded, false)
+		case *parse.IfNode:
+			w.branch(&n.BranchNode, root, false, guarded)
+		case *parse.WithNode:
+			w.branch(&n.BranchNode, root, true, guarded)
+		case *parse.RangeNode:
+			w.branch(&n.BranchNode, root, true, guarded)
+		}
+	}
+}
+
+// branch walks an if, with or range. Values in its condition may be
+// undefined, and guard the values of its body.
+func (w *walker) branch(n *parse.BranchNode, root, changesDot bool, guarded []string) {
+	w.pipe(n.Pipe, root, guarded, true)
+	body := append(append([]string{}, guarded...), w.paths(n.Pipe, root)...)
+	w.list(n.List, root && !changesDot, body)
+	w.list(n.ElseList, root, guarded)
+}
+
+// pipe walks a pipeline, optional reports whether its values may be
+// undefined
+func (w *walker) pipe(pipe *parse.PipeNode, root bool, guarded []string, optional bool) {
+	if pipe == nil {
+		return
+	}
+	for i, cmd := range pipe.Cmds {
+		// A default later in the pipeline covers undefined values
+		cmdOptional := optional
+		for _, later := range pipe.Cmds[i+1:] {
+			if fn := function(later); optionalFuncs[fn] {
+				cmdOptional = true
+			}
+		}
+		fn := function(cmd)
+		if fn == "include" && len(cmd.Args) > 1 {
+			if name, ok := cmd.Args[1].(*parse.StringNode); ok {
+				w.scan.helpers = append(w.scan.helpers, reference{file: w.file, line: w.line(cmd), name: name.Text})
+			}
+		}
+		for _, arg := range cmd.Args {
+			w.arg(arg, root, guarded, cmdOptional || optionalFuncs[fn])
+		}
+	}
+}
+
+// arg walks an argument of a command
+func (w *walker) arg(arg parse.Node, root bool, guarded []string, optional bool) {
+	switch a := arg.(type) {
+	case *parse.PipeNode:
+		w.pipe(a, root, guarded, optional)
+	case *parse.FieldNode, *parse.VariableNode:
+		name, ok := valuesPath(a, root)
+		if !ok || optional || isGuarded(name, guarded) {
+			return
+		}
+		w.scan.values = append(w.scan.values, reference{file: w.file, line: w.line(a), name: name})
+	}
+}
+
+// paths returns the values paths a pipeline uses
+func (w *walker) paths(pipe *parse.PipeNode, root bool) []string {
+	var paths []string
+	if pipe == nil {
+		return nil
+	}
+	for _, cmd := range pipe.Cmds {
+		for _, arg := range cmd.Args {
+			if p, ok := arg.(*parse.PipeNode); ok {
+				paths = append(paths, w.paths(p, root)...)
+			} else if name, ok := valuesPath(arg, root); ok {
+				paths = append(paths, name)
+			}
+		}
+	}
+	return paths
+}
+
+// function returns the name of the function a command calls, if any
+func function(cmd *parse.CommandNode) string {
+	if len(cmd.Args) == 0 {
+		return ""
+	}
+	if ident, ok := cmd.Args[0].(*parse.IdentifierNode); ok {
+		return ident.Ident
+	}
+	return ""
+}
+
+// valuesPath returns the path of a .Values or $.Values field, like
+// global.project
+func valuesPath(node parse.Node, root bool) (string, bool) {
+	var ident []string
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		if !root {
+			return "", false
+		}
+		ident = n.Ident
+	case *parse.VariableNode:
+		if len(n.Ident) == 0 || n.Ident[0] != "$" {
+			return "", false
+		}
+		ident = n.Ident[1:]
+	default:
+		return "", false
+	}
+	if len(ident) < 2 || ident[0] != "Values" {
+		return "", false
+	}
+	return strings.Join(ident[1:], "."), true
+}
+
+// isGuarded reports whether a values path is checked by an enclosing if or
+// with, or is below a path that is
+func isGuarded(name string, guarded []string) bool {
+	for _, g := range guarded {
+		if name == g || strings.HasPrefix(name, g+".") {
+			return true
+		}
+	}
+	return false
+}
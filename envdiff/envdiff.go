@@ -0,0 +1,236 @@
+// Package envdiff compares two environments of a chart: the effective values
+// they are rendered with and the resources they render to.
+package envdiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rebelopsio/argo-helper/chart"
+)
+
+// Change kinds
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+// ValueChange is a value that differs between two environments
+type ValueChange struct {
+	// Path is the dotted path of the value, e.g. global.targetRevision
+	Path string
+	Kind string
+	// From and To are the values in each environment, nil when the value
+	// is missing
+	From interface{}
+	To   interface{}
+}
+
+func (c ValueChange) String() string {
+	switch c.Kind {
+	case Added:
+		return fmt.Sprintf("+ %s: %s", c.Path, FormatValue(c.To))
+	case Removed:
+		return fmt.Sprintf("- %s: %s", c.Path, FormatValue(c.From))
+	default:
+		return fmt.Sprintf("~ %s: %s -> %s", c.Path, FormatValue(c.From), FormatValue(c.To))
+	}
+}
+
+// FormatValue prints a value on one line, as JSON
+func FormatValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// Values compares two sets of values key by key and returns the changes
+// sorted by path. Maps are compared recursively, lists and other values as
+// a whole by how they are printed, so 1 and 1.0 are equal but 1 and "1"
+// are not.
+func Values(from, to map[string]interface{}) []ValueChange {
+	var changes []ValueChange
+	diffValues("", from, to, &changes)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+func diffValues(prefix string, from, to map[string]interface{}, changes *[]ValueChange) {
+	for key, fromValue := range from {
+		path := joinPath(prefix, key)
+		toValue, ok := to[key]
+		if !ok {
+			*changes = append(*changes, ValueChange{Path: path, Kind: Removed, From: fromValue})
+			continue
+		}
+
+		fromMap, fromIsMap := fromValue.(map[string]interface{})
+		toMap, toIsMap := toValue.(map[string]interface{})
+		if fromIsMap && toIsMap {
+			diffValues(path, fromMap, toMap, changes)
+		} else if FormatValue(fromValue) != FormatValue(toValue) {
+			*changes = append(*changes, ValueChange{Path: path, Kind: Changed, From: fromValue, To: toValue})
+		}
+	}
+	for key, toValue := range to {
+		if _, ok := from[key]; !ok {
+			*changes = append(*changes, ValueChange{Path: joinPath(prefix, key), Kind: Added, To: toValue})
+		}
+	}
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// ResourceChange is a resource rendered differently in two environments
+type ResourceChange struct {
+	// Resource is kind/name, or kind/namespace/name for namespaced
+	// resources
+	Resource string
+	Kind     string
+	// File is the template the resource is rendered from
+	File string
+	// Diff is the unified diff of the resource, normalized as YAML
+	Diff string
+}
+
+// resource is a rendered resource with its normalized YAML
+type resource struct {
+	file    string
+	content string
+}
+
+// Manifests compares the resources rendered for two environments, labelled
+// fromEnv and toEnv in the diffs, and returns the changes in the order of
+// the resources. Resources are matched by kind, namespace and name, so a
+// resource moving between templates is not reported.
+func Manifests(fromEnv string, from []chart.Manifest, toEnv string, to []chart.Manifest) ([]ResourceChange, error) {
+	fromResources, fromOrder, err := resources(from)
+	if err != nil {
+		return nil, err
+	}
+	toResources, toOrder, err := resources(to)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []ResourceChange
+	for _, key := range fromOrder {
+		f := fromResources[key]
+		t, ok := toResources[key]
+		change := ResourceChange{Resource: key, Kind: Changed, File: f.file}
+		if !ok {
+			change.Kind = Removed
+		} else if f.content == t.content {
+			continue
+		}
+		if change.Diff, err = unifiedDiff(fromEnv+"/"+key, f.content, toEnv+"/"+key, t.content); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	for _, key := range toOrder {
+		if _, ok := fromResources[key]; ok {
+			continue
+		}
+		t := toResources[key]
+		diff, err := unifiedDiff(fromEnv+"/"+key, "", toEnv+"/"+key, t.content)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, ResourceChange{Resource: key, Kind: Added, File: t.file, Diff: diff})
+	}
+	return changes, nil
+}
+
+// resources splits manifests into normalized resources by key
+func resources(manifests []chart.Manifest) (map[string]resource, []string, error) {
+	byKey := map[string]resource{}
+	var order []string
+	for _, manifest := range manifests {
+		docs, err := manifest.Documents()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: %w", manifest.Name, err)
+		}
+		for i, doc := range docs {
+			key := resourceKey(doc.Content[0])
+			if key == "" {
+				key = fmt.Sprintf("%s#%d", manifest.Name, i)
+			}
+			normalize(doc)
+			var buf bytes.Buffer
+			enc := yaml.NewEncoder(&buf)
+			enc.SetIndent(2)
+			if err := enc.Encode(doc); err != nil {
+				return nil, nil, fmt.Errorf("failed to encode %s: %w", key, err)
+			}
+			if _, ok := byKey[key]; !ok {
+				order = append(order, key)
+			}
+			byKey[key] = resource{file: manifest.Name, content: buf.String()}
+		}
+	}
+	return byKey, order, nil
+}
+
+// normalize removes comments and styles, so templates formatted
+// differently compare equal
+func normalize(node *yaml.Node) {
+	node.HeadComment, node.LineComment, node.FootComment = "", "", ""
+	node.Style = 0
+	for _, child := range node.Content {
+		normalize(child)
+	}
+}
+
+// resourceKey returns kind/name or kind/namespace/name, or an empty string
+// for documents without a kind and name
+func resourceKey(node *yaml.Node) string {
+	var meta struct {
+		Kind     string `yaml:"kind"`
+		Metadata struct {
+			Name      string `yaml:"name"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"metadata"`
+	}
+	if err := node.Decode(&meta); err != nil || meta.Kind == "" || meta.Metadata.Name == "" {
+		return ""
+	}
+	parts := []string{meta.Kind, meta.Metadata.Namespace, meta.Metadata.Name}
+	if meta.Metadata.Namespace == "" {
+		parts = []string{meta.Kind, meta.Metadata.Name}
+	}
+	return strings.Join(parts, "/")
+}
+
+func unifiedDiff(fromName, from, toName, to string) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        lines(from),
+		B:        lines(to),
+		FromFile: fromName,
+		ToFile:   toName,
+		Context:  3,
+	})
+}
+
+// lines splits content into lines for diffing, without the empty line
+// difflib.SplitLines adds for the final newline
+func lines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return difflib.SplitLines(strings.TrimSuffix(content, "\n"))
+}
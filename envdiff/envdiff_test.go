@@ -0,0 +1,82 @@
+package envdiff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rebelopsio/argo-helper/chart"
+)
+
+func TestValues(t *testing.T) {
+	from := map[string]interface{}{
+		"global": map[string]interface{}{"env": "dev", "revision": "HEAD"},
+		"debug":  true,
+		"hosts":  []interface{}{"a"},
+		// 1.0 decodes to a float and 1 to an int, both render as 1
+		"image": map[string]interface{}{"tag": 1.0, "port": "80"},
+	}
+	to := map[string]interface{}{
+		"global":   map[string]interface{}{"env": "prod", "revision": "HEAD", "replicas": 3},
+		"hosts":    []interface{}{"a", "b"},
+		"features": map[string]interface{}{"sso": true},
+		"image":    map[string]interface{}{"tag": 1, "port": 80},
+	}
+
+	var changes []string
+	for _, change := range Values(from, to) {
+		changes = append(changes, change.String())
+	}
+	expected := []string{
+		"- debug: true",
+		`+ features: {"sso":true}`,
+		`~ global.env: "dev" -> "prod"`,
+		"+ global.replicas: 3",
+		`~ hosts: ["a"] -> ["a","b"]`,
+		`~ image.port: "80" -> 80`,
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(changes, "\n"))
+	}
+}
+
+func TestManifests(t *testing.T) {
+	from := []chart.Manifest{
+		{Name: "templates/app.yaml", Content: "kind: Application\nmetadata:\n  name: web\n  namespace: argocd\nspec:\n  targetRevision: HEAD\n"},
+		{Name: "templates/same.yaml", Content: "kind: ConfigMap\nmetadata:\n  name: same\n"},
+		{Name: "templates/debug.yaml", Content: "kind: ConfigMap\nmetadata:\n  name: debug\n"},
+	}
+	to := []chart.Manifest{
+		{Name: "templates/app.yaml", Content: "kind: Application\nmetadata: {name: web, namespace: argocd}\nspec:\n  targetRevision: v1.0.0\n"},
+		{Name: "templates/same.yaml", Content: "# same resource\nkind: ConfigMap\nmetadata:\n  name: same\n---\nkind: ConfigMap\nmetadata:\n  name: extra\n"},
+	}
+
+	changes, err := Manifests("dev", from, "prod", to)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var summary []string
+	for _, change := range changes {
+		summary = append(summary, change.Kind+" "+change.Resource+" "+change.File)
+	}
+	expected := []string{
+		"changed Application/argocd/web templates/app.yaml",
+		"removed ConfigMap/debug templates/debug.yaml",
+		"added ConfigMap/extra templates/same.yaml",
+	}
+	if !reflect.DeepEqual(summary, expected) {
+		t.Fatalf("Expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(summary, "\n"))
+	}
+
+	// Resources are compared as YAML, so the flow style is not a change
+	diff := changes[0].Diff
+	for _, want := range []string{"--- dev/Application/argocd/web\n", "-  targetRevision: HEAD\n", "+  targetRevision: v1.0.0\n"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("Expected diff to contain %q:\n%s", want, diff)
+		}
+	}
+	if strings.Contains(diff, "-metadata") {
+		t.Errorf("Expected metadata to be unchanged:\n%s", diff)
+	}
+}
@@ -0,0 +1,251 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/rebelopsio/argo-helper/appset"
+)
+
+// Edge kinds of a Graph
+const (
+	// EdgeManages links an app-of-apps Application to the resources in its
+	// source path
+	EdgeManages = "manages"
+	// EdgeGenerates links an ApplicationSet to the Applications it generates
+	EdgeGenerates = "generates"
+	// EdgeProject links an Application or ApplicationSet to its AppProject
+	EdgeProject = "project"
+)
+
+// GraphFormats are the supported graph formats
+var GraphFormats = []string{"dot", "mermaid", "json"}
+
+// Node is a resource of a Graph
+type Node struct {
+	// ID is kind/name
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// File is the template or example defining the resource, empty for
+	// generated Applications and for projects the chart does not define
+	File string `json:"file,omitempty"`
+	// Generated is set for Applications generated by an ApplicationSet
+	Generated bool `json:"generated,omitempty"`
+}
+
+// Edge is a relationship between two nodes
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind string `json:"kind"`
+}
+
+// Graph is the hierarchy of the Argo CD resources of a chart
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+	// Warnings are the ApplicationSets whose generators could not be
+	// evaluated
+	Warnings []string `json:"warnings,omitempty"`
+
+	index map[string]int
+}
+
+// GraphOptions configures BuildGraph
+type GraphOptions struct {
+	// ChartPath is the directory of the chart relative to the repository
+	// root, with forward slashes, empty when the chart is the root
+	ChartPath string
+	// RepoURL is the repository of the chart, global.repoURL. Applications
+	// from other repositories manage none of its resources.
+	RepoURL string
+	// AppSet configures the evaluation of ApplicationSet generators
+	AppSet appset.Options
+}
+
+// BuildGraph links the resources of one environment: Applications to the
+// resources in their source path, ApplicationSets to the Applications they
+// generate, and both to their projects
+func BuildGraph(resources []Resource, opts GraphOptions) *Graph {
+	g := &Graph{index: map[string]int{}}
+
+	// Applications generated by ApplicationSets are part of the graph too,
+	// they may themselves be app-of-apps
+	apps := []Resource{}
+	for _, r := range resources {
+		g.addNode(Node{Kind: r.Kind, Name: r.Name, File: r.File})
+		switch r.Kind {
+		case KindApplication:
+			apps = append(apps, r)
+		case KindApplicationSet:
+			generated, err := appset.Generate(r.Node, opts.AppSet)
+			if err != nil {
+				g.Warnings = append(g.Warnings, fmt.Sprintf("ApplicationSet %s: %v", r.Name, err))
+			}
+			for _, app := range generated {
+				id := g.addNode(Node{Kind: KindApplication, Name: app.Name, Generated: true})
+				g.addEdge(nodeID(KindApplicationSet, r.Name), id, EdgeGenerates)
+				apps = append(apps, Resource{Kind: KindApplication, Name: app.Name, Project: app.Project, RepoURL: app.RepoURL, Path: app.Path})
+			}
+		}
+	}
+
+	for _, r := range resources {
+		if r.Kind == KindApplicationSet && r.Project != "" && !strings.Contains(r.Project, "{{") {
+			g.addProjectEdge(r)
+		}
+	}
+	for _, app := range apps {
+		if app.Project != "" {
+			g.addProjectEdge(app)
+		}
+		if app.Path == "" {
+			continue
+		}
+		for _, child := range resources {
+			if manages(app, child, opts) {
+				g.addEdge(nodeID(app.Kind, app.Name), nodeID(child.Kind, child.Name), EdgeManages)
+			}
+		}
+	}
+	return g
+}
+
+// manages reports whether the source path of an Application contains a
+// resource: the chart itself renders its templates, any other directory
+// applies the files below it. Only Applications from the repository of the
+// chart manage its resources.
+func manages(app, child Resource, opts GraphOptions) bool {
+	if app.Kind == child.Kind && app.Name == child.Name {
+		return false
+	}
+	if !sameRepository(app.RepoURL, opts.RepoURL) {
+		return false
+	}
+	chartPath := opts.ChartPath
+	dir := strings.Trim(path.Clean(app.Path), "/")
+	if dir == "." {
+		dir = ""
+	}
+	if dir == chartPath {
+		return strings.HasPrefix(child.File, "templates/")
+	}
+	file := path.Join(chartPath, child.File)
+	return dir == "" || strings.HasPrefix(file, dir+"/")
+}
+
+// sameRepository reports whether two repository URLs name the same
+// repository, ignoring case, a trailing slash and the .git suffix
+func sameRepository(a, b string) bool {
+	normalize := func(url string) string {
+		url = strings.TrimSuffix(strings.TrimSuffix(strings.TrimSpace(url), "/"), ".git")
+		return strings.ToLower(url)
+	}
+	return normalize(a) == normalize(b)
+}
+
+func (g *Graph) addProjectEdge(r Resource) {
+	id := nodeID(KindAppProject, r.Project)
+	if _, ok := g.index[id]; !ok {
+		g.addNode(Node{Kind: KindAppProject, Name: r.Project})
+	}
+	g.addEdge(nodeID(r.Kind, r.Name), id, EdgeProject)
+}
+
+// addNode adds a node unless it exists and returns its ID
+func (g *Graph) addNode(n Node) string {
+	n.ID = nodeID(n.Kind, n.Name)
+	if _, ok := g.index[n.ID]; !ok {
+		g.index[n.ID] = len(g.Nodes)
+		g.Nodes = append(g.Nodes, n)
+	}
+	return n.ID
+}
+
+func (g *Graph) addEdge(from, to, kind string) {
+	for _, e := range g.Edges {
+		if e.From == from && e.To == to && e.Kind == kind {
+			return
+		}
+	}
+	g.Edges = append(g.Edges, Edge{From: from, To: to, Kind: kind})
+}
+
+func nodeID(kind, name string) string {
+	return kind + "/" + name
+}
+
+// Write writes the graph in a format
+func (g *Graph) Write(w io.Writer, format string) error {
+	switch format {
+	case "dot":
+		return g.writeDOT(w)
+	case "mermaid":
+		return g.writeMermaid(w)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(g)
+	default:
+		return fmt.Errorf("unsupported format: %s (must be one of %v)", format, GraphFormats)
+	}
+}
+
+// nodeShapes are the DOT shapes of the resource kinds
+var nodeShapes = map[string]string{
+	KindApplication:    "box",
+	KindApplicationSet: "box3d",
+	KindAppProject:     "folder",
+}
+
+func (g *Graph) writeDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph argocd {\n  rankdir=LR;\n")
+	for _, n := range g.Nodes {
+		style := ""
+		if n.Generated {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(&b, "  %q [label=%q, shape=%s%s];\n", n.ID, n.Kind+"\n"+n.Name, nodeShapes[n.Kind], style)
+	}
+	for _, e := range g.Edges {
+		style := ""
+		if e.Kind == EdgeProject {
+			style = ", style=dotted"
+		}
+		fmt.Fprintf(&b, "  %q -> %q [label=%q%s];\n", e.From, e.To, e.Kind, style)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (g *Graph) writeMermaid(w io.Writer) error {
+	// Mermaid IDs cannot contain slashes, nodes are numbered instead
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for i, n := range g.Nodes {
+		label := strings.ReplaceAll(n.Kind+": "+n.Name, `"`, "#quot;")
+		switch n.Kind {
+		case KindAppProject:
+			fmt.Fprintf(&b, "  n%d[(\"%s\")]\n", i, label)
+		case KindApplicationSet:
+			fmt.Fprintf(&b, "  n%d[[\"%s\"]]\n", i, label)
+		default:
+			fmt.Fprintf(&b, "  n%d[\"%s\"]\n", i, label)
+		}
+	}
+	for _, e := range g.Edges {
+		arrow := "-->"
+		if e.Kind == EdgeProject {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&b, "  n%d %s|%s| n%d\n", g.index[e.From], arrow, e.Kind, g.index[e.To])
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
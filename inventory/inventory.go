@@ -0,0 +1,193 @@
+// Package inventory lists the Argo CD resources a chart defines, rendered
+// for each environment, so commands can report on them without a cluster.
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rebelopsio/argo-helper/chart"
+	"github.com/rebelopsio/argo-helper/yamledit"
+)
+
+// Argo CD resource kinds
+const (
+	KindApplication    = "Application"
+	KindApplicationSet = "ApplicationSet"
+	KindAppProject     = "AppProject"
+)
+
+// Resource is an Argo CD resource rendered for an environment
+type Resource struct {
+	// Env is the environment the resource was rendered for, empty for
+	// values.yaml alone
+	Env  string `json:"env" yaml:"env"`
+	Kind string `json:"kind" yaml:"kind"`
+	Name string `json:"name" yaml:"name"`
+	// Project is the AppProject of an Application, or of the Applications
+	// an ApplicationSet generates
+	Project string `json:"project,omitempty" yaml:"project,omitempty"`
+	// RepoURL, Path and TargetRevision are those of the first source. For
+	// AppProjects, RepoURL lists the allowed source repositories.
+	RepoURL        string `json:"repoURL,omitempty" yaml:"repoURL,omitempty"`
+	Path           string `json:"path,omitempty" yaml:"path,omitempty"`
+	TargetRevision string `json:"targetRevision,omitempty" yaml:"targetRevision,omitempty"`
+	// Destination is the destination server or cluster name. For
+	// AppProjects, it lists the allowed namespace@server destinations.
+	Destination string `json:"destination,omitempty" yaml:"destination,omitempty"`
+	Namespace   string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	SyncPolicy  string `json:"syncPolicy,omitempty" yaml:"syncPolicy,omitempty"`
+	// File is the template or example the resource was rendered from
+	File string `json:"file" yaml:"file"`
+	// Node is the rendered resource
+	Node *yaml.Node `json:"-" yaml:"-"`
+}
+
+// Collect renders the templates and examples of the chart for each
+// environment, an empty environment meaning values.yaml alone, and returns
+// their Argo CD resources in environment and file order. Files that fail to
+// render or to parse are left out and returned as the error, so one broken
+// template does not hide the others.
+func Collect(c *chart.Chart, envs []string) ([]Resource, error) {
+	examples, err := c.Examples()
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []Resource
+	var errs []error
+	for _, env := range envs {
+		values, err := c.ValuesFor(env)
+		if err != nil {
+			return nil, err
+		}
+
+		manifests, err := c.Render(values, chart.RenderOptions{})
+		if err != nil {
+			errs = append(errs, envError(env, err))
+		}
+		for _, example := range examples {
+			manifest, err := c.RenderFile(example, values, chart.RenderOptions{})
+			if err != nil {
+				errs = append(errs, envError(env, err))
+				continue
+			}
+			manifests = append(manifests, manifest)
+		}
+
+		for _, manifest := range manifests {
+			docs, err := manifest.Documents()
+			if err != nil {
+				errs = append(errs, envError(env, fmt.Errorf("%s: %w", manifest.Name, err)))
+			}
+			for _, doc := range docs {
+				if resource, ok := newResource(doc.Content[0]); ok {
+					resource.Env = env
+					resource.File = manifest.Name
+					resources = append(resources, resource)
+				}
+			}
+		}
+	}
+
+	return resources, errors.Join(errs...)
+}
+
+func envError(env string, err error) error {
+	if env == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", env, err)
+}
+
+// newResource summarizes a rendered resource, ok is false for resources
+// that are not Argo CD applications, ApplicationSets or projects
+func newResource(node *yaml.Node) (Resource, bool) {
+	if !strings.HasPrefix(yamledit.Scalar(node, "apiVersion"), "argoproj.io/") {
+		return Resource{}, false
+	}
+
+	resource := Resource{
+		Kind: yamledit.Scalar(node, "kind"),
+		Name: yamledit.Scalar(yamledit.Lookup(node, "metadata"), "name"),
+		Node: node,
+	}
+	switch resource.Kind {
+	case KindApplication:
+		resource.describeApplication(node)
+	case KindApplicationSet:
+		resource.describeApplication(yamledit.Lookup(yamledit.Lookup(node, "spec"), "template"))
+	case KindAppProject:
+		resource.describeProject(yamledit.Lookup(node, "spec"))
+	default:
+		return Resource{}, false
+	}
+	return resource, true
+}
+
+// describeApplication fills in the fields of an Application or of an
+// ApplicationSet template
+func (r *Resource) describeApplication(app *yaml.Node) {
+	spec := yamledit.Lookup(app, "spec")
+	source := yamledit.Lookup(spec, "source")
+	if sources := yamledit.Lookup(spec, "sources"); source == nil && sources != nil && len(sources.Content) > 0 {
+		source = sources.Content[0]
+	}
+	destination := yamledit.Lookup(spec, "destination")
+
+	r.Project = yamledit.Scalar(spec, "project")
+	r.RepoURL = yamledit.Scalar(source, "repoURL")
+	r.Path = yamledit.Scalar(source, "path")
+	if r.Path == "" {
+		r.Path = yamledit.Scalar(source, "chart")
+	}
+	r.TargetRevision = yamledit.Scalar(source, "targetRevision")
+	r.Destination = yamledit.Scalar(destination, "server")
+	if r.Destination == "" {
+		r.Destination = yamledit.Scalar(destination, "name")
+	}
+	r.Namespace = yamledit.Scalar(destination, "namespace")
+	r.SyncPolicy = SyncPolicy(yamledit.Lookup(spec, "syncPolicy"))
+}
+
+// describeProject fills in the allowed sources and destinations of an
+// AppProject
+func (r *Resource) describeProject(spec *yaml.Node) {
+	var repos, destinations []string
+	for _, repo := range yamledit.Items(yamledit.Lookup(spec, "sourceRepos")) {
+		repos = append(repos, repo.Value)
+	}
+	for _, destination := range yamledit.Items(yamledit.Lookup(spec, "destinations")) {
+		server := yamledit.Scalar(destination, "server")
+		if server == "" {
+			server = yamledit.Scalar(destination, "name")
+		}
+		destinations = append(destinations, yamledit.Scalar(destination, "namespace")+"@"+server)
+	}
+	r.RepoURL = strings.Join(repos, ", ")
+	r.Destination = strings.Join(destinations, ", ")
+}
+
+// SyncPolicy summarizes a syncPolicy: manual, or automated with the prune
+// and self-heal options that are enabled
+func SyncPolicy(policy *yaml.Node) string {
+	automated := yamledit.Lookup(policy, "automated")
+	if automated == nil {
+		return "manual"
+	}
+
+	var options []string
+	if yamledit.Scalar(automated, "prune") == "true" {
+		options = append(options, "prune")
+	}
+	if yamledit.Scalar(automated, "selfHeal") == "true" {
+		options = append(options, "self-heal")
+	}
+	if len(options) == 0 {
+		return "automated"
+	}
+	return fmt.Sprintf("automated (%s)", strings.Join(options, ", "))
+}
@@ -0,0 +1,216 @@
+package inventory
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+
+	"github.com/rebelopsio/argo-helper/chart"
+)
+
+// testChart loads a chart with an Application, an AppProject, an
+// ApplicationSet example and a resource that is not from Argo CD
+func testChart(t *testing.T, extra map[string]string) *chart.Chart {
+	t.Helper()
+
+	fsys := afero.NewMemMapFs()
+	files := map[string]string{
+		"/repo/Chart.yaml":              "apiVersion: v2\nname: demo\nversion: 0.1.0\n",
+		"/repo/values.yaml":             "server: https://kubernetes.default.svc\nautomated: false\n",
+		"/repo/values/prod/values.yaml": "server: https://prod\nautomated: true\n",
+		"/repo/templates/web.yaml": `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: web
+spec:
+  project: demo
+  source:
+    repoURL: https://example.com/repo.git
+    path: apps/web
+  destination:
+    server: {{ .Values.server }}
+    namespace: web
+  {{- if .Values.automated }}
+  syncPolicy:
+    automated:
+      selfHeal: true
+  {{- end }}
+`,
+		"/repo/templates/project.yaml": `apiVersion: argoproj.io/v1alpha1
+kind: AppProject
+metadata:
+  name: demo
+spec:
+  sourceRepos: [https://example.com/repo.git]
+  destinations:
+    - namespace: '*'
+      server: {{ .Values.server }}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+`,
+		"/repo/examples/apps.yaml": `apiVersion: argoproj.io/v1alpha1
+kind: ApplicationSet
+metadata:
+  name: apps
+spec:
+  generators:
+    - list:
+        elements: []
+  template:
+    spec:
+      project: demo
+      sources:
+        - repoURL: https://example.com/repo.git
+          path: '{{ "{{path}}" }}'
+      destination:
+        name: in-cluster
+      syncPolicy:
+        automated:
+          prune: true
+          selfHeal: true
+`,
+	}
+	for path, content := range extra {
+		files[path] = content
+	}
+	for path, content := range files {
+		if err := afero.WriteFile(fsys, path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	c, err := chart.Load(fsys, "/repo")
+	if err != nil {
+		t.Fatalf("Failed to load chart: %v", err)
+	}
+	return c
+}
+
+func TestCollect(t *testing.T) {
+	resources, err := Collect(testChart(t, nil), []string{"", "prod"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var summary []string
+	for _, r := range resources {
+		summary = append(summary, strings.Join([]string{r.Env, r.Kind, r.Name, r.Project, r.Path, r.Destination, r.SyncPolicy, r.File}, "|"))
+	}
+	expected := []string{
+		"|AppProject|demo|||*@https://kubernetes.default.svc||templates/project.yaml",
+		"|Application|web|demo|apps/web|https://kubernetes.default.svc|manual|templates/web.yaml",
+		"|ApplicationSet|apps|demo|{{path}}|in-cluster|automated (prune, self-heal)|examples/apps.yaml",
+		"prod|AppProject|demo|||*@https://prod||templates/project.yaml",
+		"prod|Application|web|demo|apps/web|https://prod|automated (self-heal)|templates/web.yaml",
+		"prod|ApplicationSet|apps|demo|{{path}}|in-cluster|automated (prune, self-heal)|examples/apps.yaml",
+	}
+	if !reflect.DeepEqual(summary, expected) {
+		t.Errorf("Expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(summary, "\n"))
+	}
+}
+
+func TestCollectReportsBrokenTemplates(t *testing.T) {
+	c := testChart(t, map[string]string{"/repo/templates/broken.yaml": "{{ if }}\n"})
+
+	resources, err := Collect(c, []string{"prod"})
+	if err == nil || !strings.Contains(err.Error(), "templates/broken.yaml") {
+		t.Errorf("Expected an error for templates/broken.yaml, got %v", err)
+	}
+	if len(resources) != 3 {
+		t.Errorf("Expected the other resources to be collected, got %d", len(resources))
+	}
+}
+
+func TestBuildGraph(t *testing.T) {
+	c := testChart(t, map[string]string{
+		"/repo/templates/root.yaml": `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: root
+spec:
+  project: platform
+  source:
+    repoURL: https://example.com/repo
+    path: charts/demo
+`,
+		// An Application from another repository manages nothing of the chart
+		"/repo/templates/external.yaml": `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: external
+spec:
+  project: platform
+  source:
+    repoURL: https://github.com/other/unrelated.git
+    path: .
+`,
+		"/repo/examples/teams.yaml": `apiVersion: argoproj.io/v1alpha1
+kind: ApplicationSet
+metadata:
+  name: teams
+spec:
+  generators:
+    - list:
+        elements:
+          - team: a
+  template:
+    metadata:
+      name: '{{ "{{team}}" }}'
+    spec:
+      project: demo
+      source:
+        repoURL: https://example.com/repo.git
+        path: charts/demo/examples
+      destination:
+        name: in-cluster
+`,
+	})
+	resources, err := Collect(c, []string{""})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	g := BuildGraph(resources, GraphOptions{ChartPath: "charts/demo", RepoURL: "https://example.com/repo.git"})
+	var edges []string
+	for _, e := range g.Edges {
+		edges = append(edges, e.From+" "+e.Kind+" "+e.To)
+	}
+	expected := []string{
+		"ApplicationSet/teams generates Application/a",
+		"ApplicationSet/apps project AppProject/demo",
+		"ApplicationSet/teams project AppProject/demo",
+		"Application/external project AppProject/platform",
+		"Application/root project AppProject/platform",
+		"Application/root manages Application/external",
+		"Application/root manages AppProject/demo",
+		"Application/root manages Application/web",
+		"Application/web project AppProject/demo",
+		"Application/a project AppProject/demo",
+		"Application/a manages ApplicationSet/apps",
+		"Application/a manages ApplicationSet/teams",
+	}
+	if !reflect.DeepEqual(edges, expected) {
+		t.Errorf("Expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(edges, "\n"))
+	}
+	for _, e := range g.Edges {
+		if e.From == "Application/external" && e.Kind == EdgeManages {
+			t.Errorf("Expected the Application of another repository to manage nothing, got %s %s %s", e.From, e.Kind, e.To)
+		}
+	}
+
+	var out strings.Builder
+	if err := g.Write(&out, "mermaid"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), `[("AppProject: platform")]`) {
+		t.Errorf("Expected the platform project in the Mermaid output:\n%s", out.String())
+	}
+	if err := g.Write(&out, "svg"); err == nil {
+		t.Errorf("Expected error for an unsupported format")
+	}
+}
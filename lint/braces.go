@@ -0,0 +1,131 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/rebelopsio/argo-helper/chart"
+)
+
+// applicationSetKindPattern matches templates defining an ApplicationSet
+var applicationSetKindPattern = regexp.MustCompile(`(?m)^kind:\s*ApplicationSet\s*$`)
+
+// applicationSetParamPattern matches the parameters ApplicationSet
+// generators provide, in fasttemplate ({{path.basename}}) and Go template
+// ({{ .path.basename }}) syntax
+var applicationSetParamPattern = regexp.MustCompile(`^(\.)?(path|server|cluster|url|name|nameNormalized|values|metadata|branch|branch_slug|number|head_sha|head_short_sha|target_branch|repository|organization|labels)\b`)
+
+// action is a {{ }} action in a template source
+type action struct {
+	text         string
+	line, column int
+}
+
+// checkApplicationSetBraces reports ApplicationSet parameters written as
+// plain {{ }} in a Helm template. Helm evaluates them when rendering the
+// chart, so the ApplicationSet controller never sees them: {{path}} fails to
+// render and {{ .path }} silently renders as an empty string.
+func checkApplicationSetBraces(file chart.File) []Finding {
+	if !applicationSetKindPattern.Match(file.Data) {
+		return nil
+	}
+
+	var findings []Finding
+	// blocks tracks the open if, range and with blocks, the dot only refers
+	// to the chart root outside of range and with
+	var blocks []string
+	for _, a := range scanActions(string(file.Data)) {
+		text := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(a.text, "-"), "-"))
+		keyword := strings.Fields(text + " ")[0]
+
+		switch keyword {
+		case "if", "range", "with", "define", "block":
+			blocks = append(blocks, keyword)
+			continue
+		case "end":
+			if len(blocks) > 0 {
+				blocks = blocks[:len(blocks)-1]
+			}
+			continue
+		}
+
+		match := applicationSetParamPattern.FindStringSubmatch(text)
+		if match == nil || (match[1] == "." && changesDot(blocks)) {
+			continue
+		}
+		findings = append(findings, Finding{
+			File:   file.Name,
+			Line:   a.line,
+			Column: a.column,
+			Message: fmt.Sprintf("ApplicationSet parameter {{%s}} is evaluated by Helm, escape it as {{ `{{%s}}` }}",
+				text, text),
+		})
+	}
+	return findings
+}
+
+// changesDot reports whether a range or with block is open
+func changesDot(blocks []string) bool {
+	for _, block := range blocks {
+		if block == "range" || block == "with" {
+			return true
+		}
+	}
+	return false
+}
+
+// scanActions returns the {{ }} actions of a template source. String
+// literals inside actions may contain braces, so escaped parameters like
+// {{ "{{path}}" }} are returned as a single action.
+func scanActions(source string) []action {
+	var actions []action
+	line, column := 1, 1
+	for i := 0; i < len(source); {
+		if !strings.HasPrefix(source[i:], "{{") {
+			line, column = advance(source[i], line, column)
+			i++
+			continue
+		}
+
+		end := actionEnd(source, i+2)
+		if end < 0 {
+			break
+		}
+		actions = append(actions, action{text: source[i+2 : end], line: line, column: column})
+		for ; i < end+2; i++ {
+			line, column = advance(source[i], line, column)
+		}
+	}
+	return actions
+}
+
+// actionEnd returns the index of the }} closing the action starting at
+// start, skipping string literals, or -1 if the action is not closed
+func actionEnd(source string, start int) int {
+	var quote byte
+	for i := start; i < len(source); i++ {
+		c := source[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '`':
+			quote = c
+		case strings.HasPrefix(source[i:], "}}"):
+			return i
+		}
+	}
+	return -1
+}
+
+// advance moves a line and column position past a character
+func advance(c byte, line, column int) (int, int) {
+	if c == '\n' {
+		return line + 1, 1
+	}
+	return line, column + 1
+}
@@ -0,0 +1,299 @@
+// Package lint checks a chart for risky Argo CD configurations. Rules
+// check the template sources and the resources the chart renders for every
+// environment. Rules register themselves with Register, their severity can
+// be changed or disabled through Config.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rebelopsio/argo-helper/chart"
+	"github.com/rebelopsio/argo-helper/yamledit"
+)
+
+// Severity is how serious a finding is
+type Severity string
+
+// Severities, from most to least serious
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+	// SeverityOff disables a rule
+	SeverityOff Severity = "off"
+)
+
+// ParseSeverity parses a severity name
+func ParseSeverity(name string) (Severity, error) {
+	switch severity := Severity(strings.ToLower(name)); severity {
+	case SeverityError, SeverityWarning, SeverityInfo, SeverityOff:
+		return severity, nil
+	default:
+		return "", fmt.Errorf("unsupported severity: %s (must be one of error, warning, info, off)", name)
+	}
+}
+
+// rank orders severities, higher is more serious
+func (s Severity) rank() int {
+	switch s {
+	case SeverityError:
+		return 3
+	case SeverityWarning:
+		return 2
+	case SeverityInfo:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// AtLeast reports whether s is as serious as other
+func (s Severity) AtLeast(other Severity) bool {
+	return s.rank() >= other.rank()
+}
+
+// Finding is an issue reported by a rule
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	// File is the template or values file the finding is in, relative to
+	// the chart root
+	File string `json:"file"`
+	// Line and Column locate the finding in File, zero if unknown. Resource
+	// findings are located on the template line that rendered them, or in
+	// the values file that set them.
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (f Finding) String() string {
+	location := f.File
+	if f.Line > 0 {
+		location = fmt.Sprintf("%s:%d", f.File, f.Line)
+	}
+	return fmt.Sprintf("%s: %s: %s (%s)", location, f.Severity, f.Message, f.Rule)
+}
+
+// Resource is a rendered resource checked by resource rules
+type Resource struct {
+	// File is the template the resource was rendered from
+	File string
+	// Env is the environment the chart was rendered for, empty for the
+	// defaults in values.yaml
+	Env string
+	// Production reports whether Env is a production environment
+	Production bool
+
+	APIVersion string
+	Kind       string
+	Name       string
+	// Node is the mapping node of the resource
+	Node *yaml.Node
+
+	// manifest is the manifest the resource was rendered in
+	manifest chart.Manifest
+}
+
+// Config configures a lint run
+type Config struct {
+	// Severities override the default severity of rules by rule id
+	Severities map[string]Severity
+	// ProductionEnvs are the environments production rules apply to,
+	// defaults to prod and production
+	ProductionEnvs []string
+}
+
+// DefaultProductionEnvs are the production environments when none are
+// configured
+var DefaultProductionEnvs = []string{"prod", "production"}
+
+// severity returns the configured severity of a rule
+func (c Config) severity(rule Rule) Severity {
+	if severity, ok := c.Severities[rule.ID]; ok {
+		return severity
+	}
+	return rule.Severity
+}
+
+func (c Config) isProduction(env string) bool {
+	envs := c.ProductionEnvs
+	if len(envs) == 0 {
+		envs = DefaultProductionEnvs
+	}
+	for _, production := range envs {
+		if env == production {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks that the configuration only names registered rules
+func (c Config) validate() error {
+	for id := range c.Severities {
+		if _, ok := LookupRule(id); !ok {
+			return fmt.Errorf("unknown lint rule: %s (must be one of %s)", id, strings.Join(RuleIDs(), ", "))
+		}
+	}
+	return nil
+}
+
+// Run lints a chart. Template rules run on every template, resource rules
+// on the resources rendered with the default values and with the values of
+// every environment. Templates that fail to render are skipped, validate
+// reports them. Findings are sorted by file and line.
+func Run(c *chart.Chart, cfg Config) ([]Finding, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	var enabled []Rule
+	for _, rule := range Rules() {
+		if cfg.severity(rule) != SeverityOff {
+			enabled = append(enabled, rule)
+		}
+	}
+
+	findings := findingSet{seen: map[Finding]bool{}}
+	for _, rule := range enabled {
+		if rule.CheckTemplate == nil {
+			continue
+		}
+		for _, file := range c.Templates {
+			findings.add(rule, cfg, rule.CheckTemplate(file))
+		}
+	}
+
+	envs, err := c.Environments()
+	if err != nil {
+		return nil, err
+	}
+	for _, env := range append([]string{""}, envs...) {
+		resources, err := renderResources(c, env, cfg)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range enabled {
+			if rule.CheckResource == nil {
+				continue
+			}
+			for _, resource := range resources {
+				found := rule.CheckResource(resource)
+				for i := range found {
+					found[i] = locate(c, resource, found[i])
+				}
+				findings.add(rule, cfg, found)
+			}
+		}
+	}
+
+	sort.SliceStable(findings.list, func(i, j int) bool {
+		a, b := findings.list[i], findings.list[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Rule < b.Rule
+	})
+	return findings.list, nil
+}
+
+// renderResources renders the chart for an environment and returns its
+// resources
+func renderResources(c *chart.Chart, env string, cfg Config) ([]*Resource, error) {
+	values, err := c.ValuesFor(env)
+	if err != nil {
+		return nil, err
+	}
+
+	// Broken templates are reported by validate, lint checks the rest
+	manifests, _ := c.Render(values, chart.RenderOptions{Env: env})
+
+	var resources []*Resource
+	for _, manifest := range manifests {
+		docs, _ := manifest.Documents()
+		for _, doc := range docs {
+			node := doc.Content[0]
+			if node.Kind != yaml.MappingNode {
+				continue
+			}
+			resources = append(resources, &Resource{
+				File:       manifest.Name,
+				Env:        env,
+				Production: cfg.isProduction(env),
+				APIVersion: yamledit.Scalar(node, "apiVersion"),
+				Kind:       yamledit.Scalar(node, "kind"),
+				Name:       yamledit.Scalar(node, "metadata", "name"),
+				Node:       node,
+				manifest:   manifest,
+			})
+		}
+	}
+	return resources, nil
+}
+
+// locate moves a finding of a resource rule from the rendered manifest to
+// the template line that rendered it, or to the values file that set it
+func locate(c *chart.Chart, resource *Resource, finding Finding) Finding {
+	if finding.Line == 0 {
+		return finding
+	}
+	path := pathAt(resource.Node, finding.Line, finding.Column)
+	loc := c.Locate(resource.manifest, resource.Env, finding.Line, path)
+	finding.File, finding.Line, finding.Column = loc.File, loc.Line, loc.Column
+	return finding
+}
+
+// pathAt returns the keys of the deepest node at a position below node,
+// sequence items by index, or nil if there is none
+func pathAt(node *yaml.Node, line, column int) []string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if path := pathAt(value, line, column); path != nil {
+				return append([]string{key.Value}, path...)
+			}
+			if key.Line == line && key.Column == column {
+				return []string{key.Value}
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			if path := pathAt(item, line, column); path != nil {
+				return append([]string{strconv.Itoa(i)}, path...)
+			}
+		}
+	}
+	if node.Line == line && node.Column == column {
+		return []string{}
+	}
+	return nil
+}
+
+// findingSet collects findings, dropping the duplicates reported when the
+// same template renders the same way for several environments
+type findingSet struct {
+	list []Finding
+	seen map[Finding]bool
+}
+
+func (s *findingSet) add(rule Rule, cfg Config, findings []Finding) {
+	for _, finding := range findings {
+		finding.Rule = rule.ID
+		finding.Severity = cfg.severity(rule)
+		if s.seen[finding] {
+			continue
+		}
+		s.seen[finding] = true
+		s.list = append(s.list, finding)
+	}
+}
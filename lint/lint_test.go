@@ -0,0 +1,189 @@
+package lint
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+
+	"github.com/rebelopsio/argo-helper/chart"
+	"github.com/rebelopsio/argo-helper/scaffold"
+)
+
+// scaffoldChart writes a scaffold with examples plus extra templates to an
+// in-memory filesystem and loads it
+func scaffoldChart(t *testing.T, templates map[string]string) *chart.Chart {
+	t.Helper()
+
+	fsys := afero.NewMemMapFs()
+	if _, err := scaffold.Init(context.Background(), scaffold.InitOptions{
+		Path:         "/repo",
+		Project:      "demo",
+		Examples:     true,
+		WriteOptions: scaffold.WriteOptions{Fs: fsys},
+	}); err != nil {
+		t.Fatalf("Failed to scaffold: %v", err)
+	}
+	for name, content := range templates {
+		if err := afero.WriteFile(fsys, "/repo/"+name, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	c, err := chart.Load(fsys, "/repo")
+	if err != nil {
+		t.Fatalf("Failed to load chart: %v", err)
+	}
+	return c
+}
+
+// summarize returns the findings as rule@file:line
+func summarize(findings []Finding) []string {
+	var summary []string
+	for _, finding := range findings {
+		summary = append(summary, fmt.Sprintf("%s@%s:%d", finding.Rule, finding.File, finding.Line))
+	}
+	return summary
+}
+
+func TestRunScaffold(t *testing.T) {
+	c := scaffoldChart(t, nil)
+
+	findings, err := Run(c, Config{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"missing-resources-finalizer@templates/apps/example-app.yaml:4",
+		"project-wildcard-source-repos@templates/projects/project.yaml:13",
+		"project-wildcard-destination@templates/projects/project.yaml:17",
+		"project-cluster-wide-whitelist@templates/projects/project.yaml:22",
+		// Values rendered as is are reported where they are set
+		"prod-floating-revision@values.yaml:8",
+		"prod-automated-prune@values.yaml:31",
+	}
+	if summary := summarize(findings); !reflect.DeepEqual(summary, expected) {
+		t.Errorf("Expected findings:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(summary, "\n"))
+	}
+	for _, finding := range findings {
+		if finding.Rule == "prod-automated-prune" && !strings.Contains(finding.Message, "in prod") {
+			t.Errorf("Expected the production environment in the message, got %q", finding.Message)
+		}
+		// Columns of the rendered manifest do not apply to the template
+		inTemplate := strings.HasPrefix(finding.File, "templates/")
+		if inTemplate && finding.Column != 0 || !inTemplate && finding.Column == 0 {
+			t.Errorf("Unexpected column for %s at %s:%d: %d", finding.Rule, finding.File, finding.Line, finding.Column)
+		}
+	}
+}
+
+func TestRunConfig(t *testing.T) {
+	c := scaffoldChart(t, nil)
+
+	findings, err := Run(c, Config{
+		Severities: map[string]Severity{
+			"missing-resources-finalizer":    SeverityOff,
+			"prod-floating-revision":         SeverityOff,
+			"project-wildcard-destination":   SeverityOff,
+			"project-cluster-wide-whitelist": SeverityOff,
+			"project-wildcard-source-repos":  SeverityError,
+		},
+		ProductionEnvs: []string{"staging"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(findings) != 1 || findings[0].Rule != "project-wildcard-source-repos" || findings[0].Severity != SeverityError {
+		t.Errorf("Expected only the wildcard source repos error, got %v", findings)
+	}
+
+	if _, err := Run(c, Config{Severities: map[string]Severity{"no-such-rule": SeverityOff}}); err == nil {
+		t.Errorf("Expected error for an unknown rule")
+	}
+}
+
+func TestApplicationSetBraces(t *testing.T) {
+	source := `apiVersion: argoproj.io/v1alpha1
+kind: ApplicationSet
+metadata:
+  name: apps
+spec:
+  goTemplate: true
+  template:
+    metadata:
+      name: '{{ "{{path.basename}}" }}-{{ .path.basename }}'
+      labels:
+        {{- include "common.labels" . | nindent 8 }}
+    spec:
+      project: {{ .Values.global.project }}
+      source:
+        path: '{{` + "`{{ .path.path }}`" + `}}'
+      destination:
+        namespace: '{{path.basename}}'
+      {{- range .Values.destinations }}
+        name: {{ .name }}
+      {{- end }}
+`
+	findings := checkApplicationSetBraces(chart.File{Name: "templates/appset.yaml", Data: []byte(source)})
+
+	var got []string
+	for _, finding := range findings {
+		got = append(got, fmt.Sprintf("%d: %s", finding.Line, finding.Message))
+	}
+	expected := []string{
+		"9: ApplicationSet parameter {{.path.basename}} is evaluated by Helm, escape it as {{ `{{.path.basename}}` }}",
+		"17: ApplicationSet parameter {{path.basename}} is evaluated by Helm, escape it as {{ `{{path.basename}}` }}",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+
+	if findings := checkApplicationSetBraces(chart.File{Name: "templates/app.yaml", Data: []byte("kind: Application\nname: {{ .name }}\n")}); findings != nil {
+		t.Errorf("Expected templates without an ApplicationSet to be skipped, got %v", findings)
+	}
+}
+
+func TestReport(t *testing.T) {
+	findings := []Finding{
+		{Rule: "default-project", Severity: SeverityWarning, File: "templates/apps/web.yaml", Line: 9, Column: 12, Message: "Application web uses the default project"},
+	}
+
+	var text bytes.Buffer
+	if err := Report(&text, "text", findings, "", ""); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(text.String(), "templates/apps/web.yaml:9: warning: Application web uses the default project (default-project)\n") {
+		t.Errorf("Unexpected text report:\n%s", text.String())
+	}
+
+	var sarif bytes.Buffer
+	if err := Report(&sarif, "sarif", findings, "gitops", "1.2.3"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(sarif.Bytes(), &log); err != nil {
+		t.Fatalf("Invalid SARIF: %v", err)
+	}
+	result := log.Runs[0].Results[0]
+	if log.Version != "2.1.0" || result.Level != "warning" || result.RuleID != "default-project" {
+		t.Errorf("Unexpected SARIF result: %+v", result)
+	}
+	location := result.Locations[0].PhysicalLocation
+	if location.ArtifactLocation.URI != "gitops/templates/apps/web.yaml" || location.Region.StartLine != 9 {
+		t.Errorf("Unexpected SARIF location: %+v", location)
+	}
+	if len(log.Runs[0].Tool.Driver.Rules) != len(Rules()) {
+		t.Errorf("Expected every rule to be described in the SARIF driver")
+	}
+
+	if err := Report(&bytes.Buffer{}, "xml", findings, "", ""); err == nil {
+		t.Errorf("Expected error for an unsupported format")
+	}
+}
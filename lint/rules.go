@@ -0,0 +1,263 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rebelopsio/argo-helper/chart"
+	"github.com/rebelopsio/argo-helper/yamledit"
+)
+
+// Rule is a lint rule. A rule checks template sources, rendered resources
+// or both.
+type Rule struct {
+	// ID identifies the rule in findings and in the configuration
+	ID          string
+	Description string
+	// Severity is the severity of the findings unless configured otherwise
+	Severity Severity
+	// CheckTemplate checks the source of a template before it is rendered
+	CheckTemplate func(file chart.File) []Finding
+	// CheckResource checks a rendered resource
+	CheckResource func(resource *Resource) []Finding
+}
+
+// rules holds the registered rules by id
+var rules = map[string]Rule{}
+
+// Register makes a rule available to Run. It panics if a rule with the same
+// id is already registered.
+func Register(rule Rule) {
+	if _, exists := rules[rule.ID]; exists {
+		panic(fmt.Sprintf("lint rule %s registered twice", rule.ID))
+	}
+	rules[rule.ID] = rule
+}
+
+// LookupRule returns the rule registered under id
+func LookupRule(id string) (Rule, bool) {
+	rule, ok := rules[id]
+	return rule, ok
+}
+
+// Rules returns all registered rules sorted by id
+func Rules() []Rule {
+	list := make([]Rule, 0, len(rules))
+	for _, rule := range rules {
+		list = append(list, rule)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// RuleIDs returns the ids of all registered rules, sorted
+func RuleIDs() []string {
+	var ids []string
+	for _, rule := range Rules() {
+		ids = append(ids, rule.ID)
+	}
+	return ids
+}
+
+// resourcesFinalizer makes Argo CD delete the resources of an application
+// when the application is deleted
+const resourcesFinalizer = "resources-finalizer.argocd.argoproj.io"
+
+func init() {
+	Register(Rule{
+		ID:            "unescaped-applicationset-braces",
+		Description:   "ApplicationSet parameters in Helm templates must be escaped so Helm leaves them to the ApplicationSet controller",
+		Severity:      SeverityError,
+		CheckTemplate: checkApplicationSetBraces,
+	})
+	Register(Rule{
+		ID:            "project-wildcard-source-repos",
+		Description:   "AppProjects should list their source repositories instead of allowing all of them",
+		Severity:      SeverityWarning,
+		CheckResource: checkWildcardSourceRepos,
+	})
+	Register(Rule{
+		ID:            "project-wildcard-destination",
+		Description:   "AppProjects should list their destination clusters and namespaces instead of allowing all of them",
+		Severity:      SeverityWarning,
+		CheckResource: checkWildcardDestinations,
+	})
+	Register(Rule{
+		ID:            "project-cluster-wide-whitelist",
+		Description:   "AppProjects should not allow every cluster-scoped resource",
+		Severity:      SeverityWarning,
+		CheckResource: checkClusterWideWhitelist,
+	})
+	Register(Rule{
+		ID:            "prod-automated-prune",
+		Description:   "Automated sync should not prune resources in production environments",
+		Severity:      SeverityWarning,
+		CheckResource: checkProdAutomatedPrune,
+	})
+	Register(Rule{
+		ID:            "missing-resources-finalizer",
+		Description:   "Applications should have the " + resourcesFinalizer + " finalizer so deleting them deletes their resources",
+		Severity:      SeverityWarning,
+		CheckResource: checkResourcesFinalizer,
+	})
+	Register(Rule{
+		ID:            "default-project",
+		Description:   "Applications should belong to a dedicated AppProject rather than the unrestricted default project",
+		Severity:      SeverityWarning,
+		CheckResource: checkDefaultProject,
+	})
+	Register(Rule{
+		ID:            "prod-floating-revision",
+		Description:   "Production environments should deploy a pinned revision rather than HEAD",
+		Severity:      SeverityInfo,
+		CheckResource: checkProdFloatingRevision,
+	})
+}
+
+// at returns a finding located at a node
+func at(resource *Resource, node *yaml.Node, format string, args ...interface{}) Finding {
+	return Finding{File: resource.File, Line: node.Line, Column: node.Column, Message: fmt.Sprintf(format, args...)}
+}
+
+func isAppProject(resource *Resource) bool {
+	return strings.HasPrefix(resource.APIVersion, "argoproj.io/") && resource.Kind == "AppProject"
+}
+
+// applicationTemplate returns the Application a resource is or generates:
+// the resource itself for an Application, spec.template for an
+// ApplicationSet
+func applicationTemplate(resource *Resource) *yaml.Node {
+	if !strings.HasPrefix(resource.APIVersion, "argoproj.io/") {
+		return nil
+	}
+	switch resource.Kind {
+	case "Application":
+		return resource.Node
+	case "ApplicationSet":
+		return yamledit.Lookup(resource.Node, "spec", "template")
+	default:
+		return nil
+	}
+}
+
+func checkWildcardSourceRepos(resource *Resource) []Finding {
+	if !isAppProject(resource) {
+		return nil
+	}
+
+	var findings []Finding
+	for _, repo := range yamledit.Items(resource.Node, "spec", "sourceRepos") {
+		if repo.Value == "*" {
+			findings = append(findings, at(resource, repo, "AppProject %s allows applications from any repository", resource.Name))
+		}
+	}
+	return findings
+}
+
+func checkWildcardDestinations(resource *Resource) []Finding {
+	if !isAppProject(resource) {
+		return nil
+	}
+
+	var findings []Finding
+	for _, destination := range yamledit.Items(resource.Node, "spec", "destinations") {
+		for _, field := range []string{"server", "name", "namespace"} {
+			if value := yamledit.Lookup(destination, field); value != nil && value.Value == "*" {
+				findings = append(findings, at(resource, value, "AppProject %s allows deploying to any %s", resource.Name, destinationNoun(field)))
+			}
+		}
+	}
+	return findings
+}
+
+func destinationNoun(field string) string {
+	if field == "namespace" {
+		return "namespace"
+	}
+	return "cluster"
+}
+
+func checkClusterWideWhitelist(resource *Resource) []Finding {
+	if !isAppProject(resource) {
+		return nil
+	}
+
+	var findings []Finding
+	for _, entry := range yamledit.Items(resource.Node, "spec", "clusterResourceWhitelist") {
+		if yamledit.Scalar(entry, "group") == "*" && yamledit.Scalar(entry, "kind") == "*" {
+			findings = append(findings, at(resource, entry, "AppProject %s allows every cluster-scoped resource", resource.Name))
+		}
+	}
+	return findings
+}
+
+func checkProdAutomatedPrune(resource *Resource) []Finding {
+	app := applicationTemplate(resource)
+	if !resource.Production || app == nil {
+		return nil
+	}
+
+	prune := yamledit.Lookup(app, "spec", "syncPolicy", "automated", "prune")
+	if prune == nil || prune.Value != "true" {
+		return nil
+	}
+	return []Finding{at(resource, prune, "%s %s prunes resources automatically in %s", resource.Kind, resource.Name, resource.Env)}
+}
+
+func checkResourcesFinalizer(resource *Resource) []Finding {
+	app := applicationTemplate(resource)
+	if app == nil {
+		return nil
+	}
+
+	for _, finalizer := range yamledit.Items(app, "metadata", "finalizers") {
+		if finalizer.Value == resourcesFinalizer || strings.HasPrefix(finalizer.Value, resourcesFinalizer+"/") {
+			return nil
+		}
+	}
+
+	node := yamledit.Lookup(app, "metadata")
+	if node == nil {
+		node = app
+	}
+	if resource.Kind == "ApplicationSet" {
+		return []Finding{at(resource, node, "Applications of ApplicationSet %s have no %s finalizer, deleting them leaves their resources behind", resource.Name, resourcesFinalizer)}
+	}
+	return []Finding{at(resource, node, "Application %s has no %s finalizer, deleting it leaves its resources behind", resource.Name, resourcesFinalizer)}
+}
+
+func checkDefaultProject(resource *Resource) []Finding {
+	app := applicationTemplate(resource)
+	project := yamledit.Lookup(app, "spec", "project")
+	if project == nil || project.Value != "default" {
+		return nil
+	}
+	return []Finding{at(resource, project, "%s %s uses the default project", resource.Kind, resource.Name)}
+}
+
+func checkProdFloatingRevision(resource *Resource) []Finding {
+	app := applicationTemplate(resource)
+	if !resource.Production || app == nil {
+		return nil
+	}
+
+	sources := yamledit.Items(app, "spec", "sources")
+	if source := yamledit.Lookup(app, "spec", "source"); source != nil {
+		sources = append(sources, source)
+	}
+
+	var findings []Finding
+	for _, source := range sources {
+		revision := yamledit.Lookup(source, "targetRevision")
+		switch {
+		case revision == nil:
+			findings = append(findings, at(resource, source, "%s %s deploys HEAD in %s", resource.Kind, resource.Name, resource.Env))
+		case revision.Value == "" || revision.Value == "HEAD":
+			findings = append(findings, at(resource, revision, "%s %s deploys HEAD in %s", resource.Kind, resource.Name, resource.Env))
+		}
+	}
+	return findings
+}
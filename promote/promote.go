@@ -0,0 +1,225 @@
+// Package promote moves the versions of an application from one
+// environment of a chart to another by editing the values file of the
+// target environment in place.
+package promote
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rebelopsio/argo-helper/chart"
+	"github.com/rebelopsio/argo-helper/envdiff"
+	"github.com/rebelopsio/argo-helper/yamledit"
+)
+
+// Keys are the keys holding versions below applications.<app>, at any
+// depth: Git revisions, image tags and Helm chart versions
+var Keys = []string{"targetRevision", "revision", "tag", "imageTag", "version", "chartVersion"}
+
+// Promotion is the edit promoting an application
+type Promotion struct {
+	App  string
+	From string
+	To   string
+	// File is the values file of the target environment, relative to the
+	// chart root
+	File string
+	// Changes are the versions that differ, From being the version of the
+	// target environment and To the promoted one
+	Changes []envdiff.ValueChange
+	// Content is the edited values file
+	Content string
+}
+
+// Plan computes the promotion of the versions of an application, the keys
+// below applications.<app> listed in Keys, from one environment to another.
+// Versions are compared as written in the values files of both
+// environments, falling back to values.yaml, so a version the target
+// inherits from values.yaml is only overridden when the source environment
+// differs.
+func Plan(fsys afero.Fs, c *chart.Chart, app, from, to string) (*Promotion, error) {
+	if from == to {
+		return nil, fmt.Errorf("cannot promote from %s to itself", from)
+	}
+	fromValues, err := c.ValuesFor(from)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.ValuesFor(to); err != nil {
+		return nil, err
+	}
+
+	prefix := []string{"applications", app}
+	appValues, ok := lookup(fromValues, prefix).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s has no values for application %s (applications.%s)", from, app, app)
+	}
+
+	file := filepath.Join("values", to, "values.yaml")
+	data, err := afero.ReadFile(fsys, chart.EnvValuesPath(c.Root, to))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	doc, err := yamledit.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+
+	// Versions are copied as written, so 1.10 does not become 1.1
+	fromFiles, err := valuesFiles(fsys, c, from)
+	if err != nil {
+		return nil, err
+	}
+	toFiles, err := valuesFiles(fsys, c, to)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Promotion{App: app, From: from, To: to, File: filepath.ToSlash(file)}
+	for _, path := range versionPaths(appValues, prefix) {
+		version := lookupNode(fromFiles, path)
+		current := lookupNode(toFiles, path)
+		if version == nil || sameVersion(version, current) {
+			continue
+		}
+
+		node := *version
+		node.HeadComment, node.LineComment, node.FootComment = "", "", ""
+		if _, err := doc.Set(path, &node); err != nil {
+			return nil, fmt.Errorf("failed to update %s: %w", file, err)
+		}
+
+		change := envdiff.ValueChange{Path: strings.Join(path, "."), Kind: envdiff.Changed, To: nodeValue(version)}
+		if current == nil {
+			change.Kind = envdiff.Added
+		} else {
+			change.From = nodeValue(current)
+		}
+		p.Changes = append(p.Changes, change)
+	}
+	p.Content = string(doc.Bytes())
+	return p, nil
+}
+
+// versionPaths returns the paths of the version keys below values, sorted
+func versionPaths(values map[string]interface{}, prefix []string) [][]string {
+	var paths [][]string
+	for key, value := range values {
+		path := append(append([]string{}, prefix...), key)
+		if nested, ok := value.(map[string]interface{}); ok {
+			paths = append(paths, versionPaths(nested, path)...)
+			continue
+		}
+		for _, k := range Keys {
+			if key == k && value != nil {
+				paths = append(paths, path)
+			}
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return strings.Join(paths[i], ".") < strings.Join(paths[j], ".")
+	})
+	return paths
+}
+
+// lookup returns the value at a path, or nil
+func lookup(values map[string]interface{}, path []string) interface{} {
+	var value interface{} = values
+	for _, key := range path {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
+
+// valuesFiles parses the values files the effective values of env come
+// from, the values file of env first
+func valuesFiles(fsys afero.Fs, c *chart.Chart, env string) ([]*yaml.Node, error) {
+	var files []*yaml.Node
+	for _, path := range []string{chart.EnvValuesPath(c.Root, env), filepath.Join(c.Root, "values.yaml")} {
+		data, err := afero.ReadFile(fsys, path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		files = append(files, &doc)
+	}
+	return files, nil
+}
+
+// lookupNode returns the node of the first values file setting a path, or
+// nil when none does
+func lookupNode(files []*yaml.Node, path []string) *yaml.Node {
+	for _, file := range files {
+		if node := yamledit.Lookup(file, path...); node != nil {
+			if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null" {
+				return nil
+			}
+			return node
+		}
+	}
+	return nil
+}
+
+// sameVersion reports whether two versions are written the same way.
+// Scalars are compared by type and text, 1.1 and 1.10 differ.
+func sameVersion(a, b *yaml.Node) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Kind == yaml.ScalarNode && b.Kind == yaml.ScalarNode {
+		return a.ShortTag() == b.ShortTag() && a.Value == b.Value
+	}
+	return reflect.DeepEqual(nodeValue(a), nodeValue(b))
+}
+
+// nodeValue returns the value of a node, numbers as they are written
+func nodeValue(node *yaml.Node) interface{} {
+	if node.Kind == yaml.ScalarNode && (node.ShortTag() == "!!int" || node.ShortTag() == "!!float") {
+		return json.Number(node.Value)
+	}
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return node.Value
+	}
+	return value
+}
+
+// Summary describes the promotion in Markdown, for a pull request
+func (p *Promotion) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Promote %s from %s to %s\n\n", p.App, p.From, p.To)
+	if len(p.Changes) == 0 {
+		fmt.Fprintf(&b, "%s already runs the versions of %s.\n", p.To, p.From)
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "Updates `%s`:\n\n", p.File)
+	b.WriteString("| Value | Before | After |\n|---|---|---|\n")
+	for _, change := range p.Changes {
+		current := "(not set)"
+		if change.Kind != envdiff.Added {
+			current = "`" + envdiff.FormatValue(change.From) + "`"
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | `%s` |\n", change.Path, current, envdiff.FormatValue(change.To))
+	}
+	return b.String()
+}
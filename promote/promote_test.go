@@ -0,0 +1,122 @@
+package promote
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+
+	"github.com/rebelopsio/argo-helper/chart"
+)
+
+func TestPlan(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	files := map[string]string{
+		"/repo/Chart.yaml":  "apiVersion: v2\nname: demo\nversion: 0.1.0\n",
+		"/repo/values.yaml": "applications:\n  web:\n    chart:\n      version: 1.0.0\n",
+		"/repo/values/dev/values.yaml": `applications:
+  web:
+    targetRevision: v1.3.0
+    image:
+      tag: "2.1"
+    replicas: 1
+`,
+		"/repo/values/prod/values.yaml": `# Production
+applications:
+  web:
+    targetRevision: v1.2.0 # pinned
+    replicas: 3
+`,
+	}
+	for path, content := range files {
+		if err := afero.WriteFile(fsys, path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	c, err := chart.Load(fsys, "/repo")
+	if err != nil {
+		t.Fatalf("Failed to load chart: %v", err)
+	}
+
+	p, err := Plan(fsys, c, "web", "dev", "prod")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The chart version comes from values.yaml in both environments
+	expected := `# Production
+applications:
+  web:
+    targetRevision: v1.3.0 # pinned
+    replicas: 3
+    image:
+      tag: "2.1"
+`
+	if p.File != "values/prod/values.yaml" || p.Content != expected {
+		t.Errorf("Expected %s to become:\n%s\ngot %s:\n%s", "values/prod/values.yaml", expected, p.File, p.Content)
+	}
+	for _, want := range []string{
+		"## Promote web from dev to prod",
+		"| `applications.web.image.tag` | (not set) | `\"2.1\"` |",
+		"| `applications.web.targetRevision` | `\"v1.2.0\"` | `\"v1.3.0\"` |",
+	} {
+		if !strings.Contains(p.Summary(), want) {
+			t.Errorf("Expected summary to contain %q:\n%s", want, p.Summary())
+		}
+	}
+
+	if _, err := Plan(fsys, c, "api", "dev", "prod"); err == nil {
+		t.Errorf("Expected error for an application without values")
+	}
+	if _, err := Plan(fsys, c, "web", "dev", "dev"); err == nil {
+		t.Errorf("Expected error promoting to the same environment")
+	}
+}
+
+func TestPlanKeepsVersionText(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	files := map[string]string{
+		"/repo/Chart.yaml":              "apiVersion: v2\nname: demo\nversion: 0.1.0\n",
+		"/repo/values.yaml":             "applications:\n  web:\n    version: 1.0\n",
+		"/repo/values/dev/values.yaml":  "applications:\n  web:\n    chartVersion: 1.10\n    imageTag: 1.0\n",
+		"/repo/values/prod/values.yaml": "applications:\n  web:\n    chartVersion: 1.1\n",
+	}
+	for path, content := range files {
+		if err := afero.WriteFile(fsys, path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	c, err := chart.Load(fsys, "/repo")
+	if err != nil {
+		t.Fatalf("Failed to load chart: %v", err)
+	}
+
+	p, err := Plan(fsys, c, "web", "dev", "prod")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "applications:\n  web:\n    chartVersion: 1.10\n    imageTag: 1.0\n"
+	if p.Content != expected {
+		t.Errorf("Expected values/prod/values.yaml to become:\n%s\ngot:\n%s", expected, p.Content)
+	}
+	for _, want := range []string{
+		"| `applications.web.chartVersion` | `1.1` | `1.10` |",
+		"| `applications.web.imageTag` | (not set) | `1.0` |",
+	} {
+		if !strings.Contains(p.Summary(), want) {
+			t.Errorf("Expected summary to contain %q:\n%s", want, p.Summary())
+		}
+	}
+
+	// Promoting again changes nothing
+	if err := afero.WriteFile(fsys, "/repo/values/prod/values.yaml", []byte(p.Content), 0644); err != nil {
+		t.Fatalf("Failed to write values: %v", err)
+	}
+	again, err := Plan(fsys, c, "web", "dev", "prod")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(again.Changes) > 0 || again.Content != p.Content {
+		t.Errorf("Expected no changes promoting again, got %v:\n%s", again.Changes, again.Content)
+	}
+}
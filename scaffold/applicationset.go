@@ -0,0 +1,607 @@
+package scaffold
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Generators are the supported ApplicationSet generators
+var Generators = []string{
+	"git-directories",
+	"git-files",
+	"list",
+	"clusters",
+	"matrix",
+	"merge",
+	"scm-provider",
+	"pull-request",
+	"cluster-decision-resource",
+}
+
+// Default child generators for the composite generators
+var defaultChildGenerators = map[string][]string{
+	"matrix": {"git-directories", "clusters"},
+	"merge":  {"clusters", "list"},
+}
+
+// generatorParams are the parameters the generators produce, besides the
+// keys of the list elements and the cluster labels and annotations
+var generatorParams = map[string][]string{
+	"git-directories":           {"path", "path.basename", "path.basenameNormalized"},
+	"git-files":                 {"path", "path.basename", "path.basenameNormalized", "path.filename", "path.filenameNormalized"},
+	"clusters":                  {"name", "nameNormalized", "server"},
+	"cluster-decision-resource": {"name", "server"},
+	"scm-provider":              {"organization", "repository", "url", "branch", "branchNormalized", "sha", "short_sha", "labels"},
+	"pull-request":              {"number", "branch", "branch_slug", "target_branch", "head_sha", "head_short_sha", "labels"},
+}
+
+// ApplicationSetOptions configures an ApplicationSet. Empty fields fall back
+// to the defaults of the selected generator.
+type ApplicationSetOptions struct {
+	// Generator is one of Generators, defaults to git-directories
+	Generator string
+	// GitPath is the path of the git generators, defaults to apps/* for
+	// directories and apps/**/config.json for files
+	GitPath string
+	// ListElements are the list generator elements
+	ListElements []map[string]string
+	// ClusterSelector selects clusters by label, all clusters when empty
+	ClusterSelector map[string]string
+	// ChildGenerators are the generators composed by matrix and merge
+	ChildGenerators []string
+	// MergeKeys are the merge generator keys, defaults to server
+	MergeKeys []string
+	// SCMProvider is github, gitlab or gitea, defaults to github
+	SCMProvider string
+	// SCMOrganization is the organization, group or owner to scan
+	SCMOrganization string
+	// PRProvider is github, gitlab or gitea, defaults to github
+	PRProvider string
+	PROwner    string
+	PRRepo     string
+	// PRLabels filter pull requests, defaults to preview
+	PRLabels []string
+	// DecisionConfigMap is the cluster-decision-resource ConfigMap
+	DecisionConfigMap string
+	// DecisionLabels select the placement decisions
+	DecisionLabels map[string]string
+}
+
+// withDefaults returns a copy of the options with all defaults applied
+func (o ApplicationSetOptions) withDefaults(name string) ApplicationSetOptions {
+	if o.Generator == "" {
+		o.Generator = "git-directories"
+	}
+	if len(o.ChildGenerators) == 0 {
+		o.ChildGenerators = defaultChildGenerators[o.Generator]
+	}
+	if len(o.MergeKeys) == 0 {
+		o.MergeKeys = []string{"server"}
+	}
+	if len(o.ListElements) == 0 {
+		o.ListElements = []map[string]string{
+			{"name": "app1", "namespace": "app1"},
+			{"name": "app2", "namespace": "app2"},
+		}
+		if o.Generator == "merge" {
+			// Overlay the in-cluster destination on top of the clusters generator
+			o.ListElements = []map[string]string{{"server": DefaultDestinationServer, "namespace": name}}
+		}
+		if o.Generator == "matrix" && o.childProduces("name") {
+			// Matrix parameters must be unique, name the apps with app instead
+			for _, element := range o.ListElements {
+				element["app"] = element["name"]
+				delete(element, "name")
+			}
+		}
+	}
+	if o.SCMProvider == "" {
+		o.SCMProvider = "github"
+	}
+	if o.SCMOrganization == "" {
+		o.SCMOrganization = "your-org"
+	}
+	if o.PRProvider == "" {
+		o.PRProvider = "github"
+	}
+	if o.PROwner == "" {
+		o.PROwner = "your-org"
+	}
+	if o.PRRepo == "" {
+		o.PRRepo = "your-repo"
+	}
+	if len(o.PRLabels) == 0 {
+		o.PRLabels = []string{"preview"}
+	}
+	if o.DecisionConfigMap == "" {
+		o.DecisionConfigMap = "ocm-placement-generator"
+	}
+	if len(o.DecisionLabels) == 0 {
+		o.DecisionLabels = map[string]string{"cluster.open-cluster-management.io/placement": name}
+	}
+	return o
+}
+
+func (o ApplicationSetOptions) validate() error {
+	if !containsString(Generators, o.Generator) {
+		return fmt.Errorf("unsupported generator: %s (must be one of %v)", o.Generator, Generators)
+	}
+
+	if isCompositeGenerator(o.Generator) {
+		for _, child := range o.ChildGenerators {
+			if !containsString(Generators, child) || isCompositeGenerator(child) {
+				return fmt.Errorf("unsupported child generator for %s: %s", o.Generator, child)
+			}
+		}
+		if o.Generator == "matrix" && len(o.ChildGenerators) != 2 {
+			return fmt.Errorf("matrix generator requires exactly 2 child generators, got %d", len(o.ChildGenerators))
+		}
+		if o.Generator == "matrix" {
+			first, second := o.ChildGenerators[0], o.ChildGenerators[1]
+			for _, param := range o.params(first) {
+				if containsString(o.params(second), param) {
+					return fmt.Errorf("matrix child generators %s and %s both produce the %s parameter, Argo CD rejects duplicate parameters", first, second, param)
+				}
+			}
+		}
+		if o.Generator == "merge" && len(o.ChildGenerators) < 2 {
+			return fmt.Errorf("merge generator requires at least 2 child generators, got %d", len(o.ChildGenerators))
+		}
+	}
+
+	if o.Generator == "list" || o.Generator == "matrix" && containsString(o.ChildGenerators, "list") {
+		if err := o.validateListNames(); err != nil {
+			return err
+		}
+	}
+
+	switch o.SCMProvider {
+	case "github", "gitlab", "gitea":
+	default:
+		return fmt.Errorf("unsupported SCM provider: %s (must be one of github, gitlab, gitea)", o.SCMProvider)
+	}
+
+	switch o.PRProvider {
+	case "github", "gitlab", "gitea":
+	default:
+		return fmt.Errorf("unsupported pull request provider: %s (must be one of github, gitlab, gitea)", o.PRProvider)
+	}
+
+	return nil
+}
+
+// validateListNames checks that list elements generating Applications name
+// them with a unique app or name key, the key of the first element. Without
+// one, the Applications would all be named after the ApplicationSet.
+func (o ApplicationSetOptions) validateListNames() error {
+	if len(o.ListElements) < 2 {
+		return nil
+	}
+	key := ""
+	for _, k := range []string{"app", "name"} {
+		if _, ok := o.ListElements[0][k]; ok {
+			key = k
+		}
+	}
+
+	seen := map[string]bool{}
+	for i, element := range o.ListElements {
+		value, ok := element[key]
+		if key == "" || !ok {
+			return fmt.Errorf("list element %d (%s) has no app or name key, every element needs a unique one to name its Application", i+1, formatListElement(element))
+		}
+		if seen[value] {
+			return fmt.Errorf("list elements share the %s %s, every element needs a unique one to name its Application", key, value)
+		}
+		seen[value] = true
+	}
+	return nil
+}
+
+// formatListElement formats a list element like ParseListElements reads it
+func formatListElement(element map[string]string) string {
+	pairs := make([]string, 0, len(element))
+	for _, key := range sortedKeys(element) {
+		pairs = append(pairs, key+"="+element[key])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// params returns the parameters a child generator produces
+func (o ApplicationSetOptions) params(generator string) []string {
+	if generator != "list" {
+		return generatorParams[generator]
+	}
+	var params []string
+	for _, element := range o.ListElements {
+		for key := range element {
+			if !containsString(params, key) {
+				params = append(params, key)
+			}
+		}
+	}
+	sort.Strings(params)
+	return params
+}
+
+// childProduces reports whether a child generator other than list produces
+// the parameter
+func (o ApplicationSetOptions) childProduces(param string) bool {
+	for _, child := range o.ChildGenerators {
+		if child != "list" && containsString(generatorParams[child], param) {
+			return true
+		}
+	}
+	return false
+}
+
+func isCompositeGenerator(generator string) bool {
+	_, ok := defaultChildGenerators[generator]
+	return ok
+}
+
+// ParseListElements parses key=value,key=value list generator elements
+func ParseListElements(entries []string) ([]map[string]string, error) {
+	elements := make([]map[string]string, 0, len(entries))
+	for _, entry := range entries {
+		element := map[string]string{}
+		for _, pair := range strings.Split(entry, ",") {
+			key, value, found := strings.Cut(pair, "=")
+			if !found || key == "" {
+				return nil, fmt.Errorf("invalid list element %q (expected key=value,key=value)", entry)
+			}
+			element[key] = value
+		}
+		elements = append(elements, element)
+	}
+
+	return elements, nil
+}
+
+// appSetTemplateFields holds the ApplicationSet template values a generator
+// provides through its parameters, already escaped for Helm. Empty fields fall
+// back to the chart defaults.
+type appSetTemplateFields struct {
+	name           []string
+	repoURL        string
+	targetRevision string
+	path           string
+	server         string
+	namespace      string
+}
+
+// applicationSetTemplateData is the data available to the applicationset template
+type applicationSetTemplateData struct {
+	Name           string
+	Generators     string
+	AppName        string
+	RepoURL        string
+	TargetRevision string
+	Path           string
+	Server         string
+	Namespace      string
+}
+
+// argoParam escapes an ApplicationSet parameter so Helm leaves it untouched
+func argoParam(param string) string {
+	return fmt.Sprintf(`{{ "{{ %s }}" }}`, param)
+}
+
+func init() {
+	Register(applicationSetType{})
+}
+
+// applicationSetType creates an ApplicationSet with a selectable generator
+type applicationSetType struct{}
+
+func (applicationSetType) Name() string { return TypeApplicationSet }
+
+func (applicationSetType) Description() string {
+	return "An ApplicationSet generating multiple Applications"
+}
+
+func (applicationSetType) OutputPath() string { return "templates/apps" }
+
+func (applicationSetType) Parameters() []Parameter {
+	appSet := func(o *NewOptions) *ApplicationSetOptions { return &o.ApplicationSet }
+	return []Parameter{
+		choiceParam("generator", "ApplicationSet generator: "+strings.Join(Generators, ", "), "git-directories", Generators,
+			func(o *NewOptions) *string { return &appSet(o).Generator }),
+		stringParam("git-path", "git generator path (default is apps/* for directories, apps/**/config.json for files)",
+			func(o *NewOptions) *string { return &appSet(o).GitPath }),
+		{
+			Name:        "list-element",
+			Description: "list generator element as key=value,key=value (repeatable)",
+			Kind:        RepeatedParameter,
+			Apply: func(o *NewOptions, values []string) error {
+				elements, err := ParseListElements(values)
+				appSet(o).ListElements = elements
+				return err
+			},
+		},
+		mapParam("cluster-selector", "clusters generator label selector as key=value (all clusters when empty)",
+			func(o *NewOptions) *map[string]string { return &appSet(o).ClusterSelector }),
+		listParam("child-generators", "child generators for matrix or merge (default is git-directories,clusters for matrix, clusters,list for merge)",
+			func(o *NewOptions) *[]string { return &appSet(o).ChildGenerators }),
+		listParam("merge-keys", "merge generator keys (default is server)",
+			func(o *NewOptions) *[]string { return &appSet(o).MergeKeys }),
+		choiceParam("scm-provider", "scm-provider generator provider: github, gitlab or gitea", "github", []string{"github", "gitlab", "gitea"},
+			func(o *NewOptions) *string { return &appSet(o).SCMProvider }),
+		stringParam("scm-organization", "scm-provider generator organization, group or owner (default is your-org)",
+			func(o *NewOptions) *string { return &appSet(o).SCMOrganization }),
+		choiceParam("pr-provider", "pull-request generator provider: github, gitlab or gitea", "github", []string{"github", "gitlab", "gitea"},
+			func(o *NewOptions) *string { return &appSet(o).PRProvider }),
+		stringParam("pr-owner", "pull-request generator repository owner (default is your-org)",
+			func(o *NewOptions) *string { return &appSet(o).PROwner }),
+		stringParam("pr-repo", "pull-request generator repository name (default is your-repo)",
+			func(o *NewOptions) *string { return &appSet(o).PRRepo }),
+		listParam("pr-labels", "pull-request generator labels to filter on (default is preview)",
+			func(o *NewOptions) *[]string { return &appSet(o).PRLabels }),
+		stringParam("decision-configmap", "cluster-decision-resource generator ConfigMap (default is ocm-placement-generator)",
+			func(o *NewOptions) *string { return &appSet(o).DecisionConfigMap }),
+		mapParam("decision-labels", "cluster-decision-resource label selector as key=value (cluster.open-cluster-management.io/placement=<name> when empty)",
+			func(o *NewOptions) *map[string]string { return &appSet(o).DecisionLabels }),
+		valuesFileParam(),
+	}
+}
+
+// Render adds the ApplicationSet template and the values edits registering
+// the target revision of its Applications
+func (applicationSetType) Render(opts NewOptions, plan *Plan) error {
+	data, err := applicationSetData(opts)
+	if err != nil {
+		return err
+	}
+	if err := addResourceFile(opts, plan, "new/applicationset.yaml", data); err != nil {
+		return err
+	}
+	return planAppValues(opts, plan)
+}
+
+func applicationSetData(opts NewOptions) (applicationSetTemplateData, error) {
+	appSet := opts.ApplicationSet.withDefaults(opts.Name)
+	if err := appSet.validate(); err != nil {
+		return applicationSetTemplateData{}, err
+	}
+
+	w := generatorWriter{opts: appSet, name: opts.Name}
+	fields := w.write(appSet.Generator, "    ")
+
+	// Fall back to the chart defaults for anything the generators don't provide
+	data := applicationSetTemplateData{
+		Name:           opts.Name,
+		Generators:     w.b.String(),
+		AppName:        opts.Name,
+		RepoURL:        "{{ .Values.global.repoURL }}",
+		TargetRevision: appTargetRevision(opts.Name),
+		Path:           fmt.Sprintf("'apps/%s'", opts.Name),
+		Server:         `{{ .Values.destination.server | default "https://kubernetes.default.svc" }}`,
+		Namespace:      fmt.Sprintf("'%s'", opts.Name),
+	}
+
+	if len(fields.name) > 0 {
+		data.AppName = strings.Join(fields.name, "-")
+		if fields.path == "" {
+			// Generators that don't vary the source path deploy the same app many times
+			data.AppName = opts.Name + "-" + data.AppName
+		}
+	}
+	if fields.repoURL != "" {
+		data.RepoURL = fmt.Sprintf("'%s'", fields.repoURL)
+	}
+	if fields.targetRevision != "" {
+		data.TargetRevision = fmt.Sprintf("'%s'", fields.targetRevision)
+	}
+	if fields.path != "" {
+		data.Path = fmt.Sprintf("'%s'", fields.path)
+	}
+	if fields.server != "" {
+		data.Server = fmt.Sprintf("'%s'", fields.server)
+	}
+	if fields.namespace != "" {
+		data.Namespace = fmt.Sprintf("'%s'", fields.namespace)
+	}
+
+	return data, nil
+}
+
+// generatorWriter writes the spec.generators block of an ApplicationSet
+type generatorWriter struct {
+	opts ApplicationSetOptions
+	name string
+	b    strings.Builder
+}
+
+// write writes a single generator list item at the given indent and returns
+// the template fields it provides
+func (w *generatorWriter) write(generator string, indent string) appSetTemplateFields {
+	opts := w.opts
+	line := func(depth int, format string, args ...interface{}) {
+		w.b.WriteString(indent + strings.Repeat("  ", depth) + fmt.Sprintf(format, args...) + "\n")
+	}
+
+	switch generator {
+	case "git-directories":
+		path := opts.GitPath
+		if path == "" {
+			path = "apps/*"
+		}
+		line(0, "- git:")
+		line(2, "repoURL: {{ .Values.global.repoURL }}")
+		line(2, "revision: {{ .Values.global.targetRevision }}")
+		line(2, "directories:")
+		line(3, "- path: %s", path)
+		return appSetTemplateFields{
+			name:      []string{argoParam("path.basename")},
+			path:      argoParam("path"),
+			namespace: argoParam("path.basename"),
+		}
+
+	case "git-files":
+		path := opts.GitPath
+		if path == "" {
+			path = "apps/**/config.json"
+		}
+		line(0, "- git:")
+		line(2, "repoURL: {{ .Values.global.repoURL }}")
+		line(2, "revision: {{ .Values.global.targetRevision }}")
+		line(2, "files:")
+		line(3, "- path: %s", path)
+		return appSetTemplateFields{
+			name:      []string{argoParam("path.basename")},
+			path:      argoParam("path"),
+			namespace: argoParam("path.basename"),
+		}
+
+	case "list":
+		line(0, "- list:")
+		line(2, "elements:")
+		for _, element := range opts.ListElements {
+			for i, key := range sortedKeys(element) {
+				prefix := "  "
+				if i == 0 {
+					prefix = "- "
+				}
+				line(3, "%s%s: %q", prefix, key, element[key])
+			}
+		}
+		var fields appSetTemplateFields
+		for _, key := range []string{"app", "name"} {
+			if _, ok := opts.ListElements[0][key]; ok {
+				fields.name = []string{argoParam(key)}
+				fields.path = "apps/" + argoParam(key)
+			}
+		}
+		if _, ok := opts.ListElements[0]["path"]; ok {
+			fields.path = argoParam("path")
+		}
+		if _, ok := opts.ListElements[0]["namespace"]; ok {
+			fields.namespace = argoParam("namespace")
+		}
+		if _, ok := opts.ListElements[0]["server"]; ok {
+			fields.server = argoParam("server")
+		}
+		return fields
+
+	case "clusters":
+		if len(opts.ClusterSelector) == 0 {
+			line(0, "- clusters: {}")
+		} else {
+			line(0, "- clusters:")
+			line(2, "selector:")
+			line(3, "matchLabels:")
+			for _, key := range sortedKeys(opts.ClusterSelector) {
+				line(4, "%s: %q", key, opts.ClusterSelector[key])
+			}
+		}
+		return appSetTemplateFields{name: []string{argoParam("name")}, server: argoParam("server")}
+
+	case "cluster-decision-resource":
+		line(0, "- clusterDecisionResource:")
+		line(2, "configMapRef: %s", opts.DecisionConfigMap)
+		line(2, "labelSelector:")
+		line(3, "matchLabels:")
+		for _, key := range sortedKeys(opts.DecisionLabels) {
+			line(4, "%s: %q", key, opts.DecisionLabels[key])
+		}
+		line(2, "requeueAfterSeconds: 180")
+		return appSetTemplateFields{name: []string{argoParam("name")}, server: argoParam("server")}
+
+	case "scm-provider":
+		line(0, "- scmProvider:")
+		line(2, "cloneProtocol: https")
+		line(2, "%s:", opts.SCMProvider)
+		switch opts.SCMProvider {
+		case "github":
+			line(3, "organization: %s", opts.SCMOrganization)
+		case "gitlab":
+			line(3, "group: %q", opts.SCMOrganization)
+		case "gitea":
+			line(3, "owner: %s", opts.SCMOrganization)
+		}
+		line(3, "allBranches: false")
+		return appSetTemplateFields{
+			name:           []string{argoParam("repository")},
+			repoURL:        argoParam("url"),
+			targetRevision: argoParam("branch"),
+			path:           ".",
+			namespace:      argoParam("repository"),
+		}
+
+	case "pull-request":
+		line(0, "- pullRequest:")
+		line(2, "%s:", opts.PRProvider)
+		switch opts.PRProvider {
+		case "github", "gitea":
+			line(3, "owner: %s", opts.PROwner)
+			line(3, "repo: %s", opts.PRRepo)
+		case "gitlab":
+			line(3, "project: %q", opts.PROwner+"/"+opts.PRRepo)
+		}
+		if opts.PRProvider != "gitea" {
+			line(3, "labels:")
+			for _, label := range opts.PRLabels {
+				line(4, "- %s", label)
+			}
+		}
+		line(2, "requeueAfterSeconds: 1800")
+		return appSetTemplateFields{
+			name:           []string{argoParam("branch_slug"), argoParam("number")},
+			targetRevision: argoParam("head_sha"),
+			namespace:      w.name + "-" + argoParam("number"),
+		}
+
+	case "matrix", "merge":
+		line(0, "- %s:", generator)
+		line(2, "generators:")
+		var fields appSetTemplateFields
+		for _, child := range opts.ChildGenerators {
+			fields = mergeTemplateFields(fields, w.write(child, indent+"      "))
+		}
+		if generator == "merge" {
+			line(2, "mergeKeys:")
+			for _, mergeKey := range opts.MergeKeys {
+				line(3, "- %s", mergeKey)
+			}
+		}
+		return fields
+	}
+
+	return appSetTemplateFields{}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// mergeTemplateFields combines the fields of composed generators: names are
+// joined, all other fields are taken from the first generator providing them
+func mergeTemplateFields(base, other appSetTemplateFields) appSetTemplateFields {
+	for _, name := range other.name {
+		if !containsString(base.name, name) {
+			base.name = append(base.name, name)
+		}
+	}
+	if base.repoURL == "" {
+		base.repoURL = other.repoURL
+	}
+	if base.targetRevision == "" {
+		base.targetRevision = other.targetRevision
+	}
+	if base.path == "" {
+		base.path = other.path
+	}
+	if base.server == "" {
+		base.server = other.server
+	}
+	if base.namespace == "" {
+		base.namespace = other.namespace
+	}
+	return base
+}
@@ -0,0 +1,57 @@
+package scaffold
+
+import (
+	"context"
+
+	"github.com/spf13/afero"
+)
+
+// WriteOptions configure how Init and New write their plan
+type WriteOptions struct {
+	// Fs is the filesystem written to, the OS filesystem when nil. Pass an
+	// in-memory filesystem for a dry run.
+	Fs afero.Fs
+	// Conflicts decides what happens to existing files, defaults to
+	// ConflictFail
+	Conflicts ConflictPolicy
+	// Prompt decides per file with ConflictAsk
+	Prompt Prompter
+}
+
+// Result is what Init or New did
+type Result struct {
+	// Plan is the plan that was written, without any skipped files
+	Plan *Plan
+	// Changes are the effects of the plan on the filesystem
+	Changes []Change
+}
+
+func (o WriteOptions) fs() afero.Fs {
+	if o.Fs == nil {
+		return afero.NewOsFs()
+	}
+	return o.Fs
+}
+
+// apply resolves the conflicts of a plan and writes it below root
+func apply(ctx context.Context, plan *Plan, root string, opts WriteOptions) (*Result, error) {
+	fsys := opts.fs()
+
+	changes, err := plan.Changes(fsys, root)
+	if err != nil {
+		return nil, err
+	}
+	if err := plan.ResolveConflicts(changes, opts.Conflicts, opts.Prompt); err != nil {
+		return nil, err
+	}
+
+	// Prompts may take a while, the caller may have given up in the meantime
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := plan.WriteFS(fsys, root); err != nil {
+		return nil, err
+	}
+
+	return &Result{Plan: plan, Changes: changes}, nil
+}
@@ -0,0 +1,197 @@
+package scaffold
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rebelopsio/argo-helper/yamledit"
+)
+
+// DefaultDestinationServer is the in-cluster Kubernetes API server
+const DefaultDestinationServer = "https://kubernetes.default.svc"
+
+// AppProjectOptions configures an AppProject. The defaults are least
+// privilege: only the chart repository as source, a single destination
+// namespace named after the project and no cluster-scoped resources.
+type AppProjectOptions struct {
+	Description                string
+	SourceRepos                []string
+	Destinations               []Destination
+	ClusterResourceWhitelist   []GroupKind
+	ClusterResourceBlacklist   []GroupKind
+	NamespaceResourceWhitelist []GroupKind
+	NamespaceResourceBlacklist []GroupKind
+}
+
+// GroupKind is a resource group and kind as used by the AppProject
+// resource white- and blacklists
+type GroupKind struct {
+	Group string
+	Kind  string
+}
+
+// Destination is a namespace and server pair an AppProject may deploy to
+type Destination struct {
+	Namespace string
+	Server    string
+}
+
+// ParseDestinations parses namespace[@server] entries, defaulting the
+// server to the local cluster
+func ParseDestinations(entries []string) ([]Destination, error) {
+	destinations := make([]Destination, 0, len(entries))
+	for _, entry := range entries {
+		namespace, server, found := strings.Cut(entry, "@")
+		if !found {
+			server = DefaultDestinationServer
+		}
+		if namespace == "" || server == "" {
+			return nil, fmt.Errorf("invalid destination %q (expected namespace[@server])", entry)
+		}
+		destinations = append(destinations, Destination{Namespace: namespace, Server: server})
+	}
+
+	return destinations, nil
+}
+
+// ParseGroupKinds parses group:kind entries, where an empty group selects
+// the core API group (e.g. ":ConfigMap")
+func ParseGroupKinds(entries []string) ([]GroupKind, error) {
+	groupKinds := make([]GroupKind, 0, len(entries))
+	for _, entry := range entries {
+		group, kind, found := strings.Cut(entry, ":")
+		if !found || kind == "" {
+			return nil, fmt.Errorf("invalid resource %q (expected group:kind)", entry)
+		}
+		groupKinds = append(groupKinds, GroupKind{Group: group, Kind: kind})
+	}
+
+	return groupKinds, nil
+}
+
+func init() {
+	Register(appProjectType{})
+}
+
+// appProjectType creates an AppProject registered in values.yaml
+type appProjectType struct{}
+
+func (appProjectType) Name() string { return TypeAppProject }
+
+func (appProjectType) Description() string {
+	return "An AppProject with least-privilege defaults, registered in values.yaml"
+}
+
+func (appProjectType) OutputPath() string { return "templates/projects" }
+
+func (appProjectType) Parameters() []Parameter {
+	project := func(o *NewOptions) *AppProjectOptions { return &o.AppProject }
+	return []Parameter{
+		stringParam("description", "project description (default is \"<name> ArgoCD Project\")",
+			func(o *NewOptions) *string { return &project(o).Description }),
+		listParam("source-repos", "allowed source repositories (default is .Values.global.repoURL)",
+			func(o *NewOptions) *[]string { return &project(o).SourceRepos }),
+		{
+			Name:        "destinations",
+			Description: "allowed destinations as namespace[@server] (default is <name>)",
+			Kind:        ListParameter,
+			Apply: func(o *NewOptions, values []string) error {
+				destinations, err := ParseDestinations(values)
+				project(o).Destinations = destinations
+				return err
+			},
+		},
+		groupKindsParam("cluster-resource-whitelist", "allowed cluster-scoped resources as group:kind (default is none)",
+			func(o *NewOptions) *[]GroupKind { return &project(o).ClusterResourceWhitelist }),
+		groupKindsParam("cluster-resource-blacklist", "denied cluster-scoped resources as group:kind",
+			func(o *NewOptions) *[]GroupKind { return &project(o).ClusterResourceBlacklist }),
+		groupKindsParam("namespace-resource-whitelist", "allowed namespaced resources as group:kind (default is all)",
+			func(o *NewOptions) *[]GroupKind { return &project(o).NamespaceResourceWhitelist }),
+		groupKindsParam("namespace-resource-blacklist", "denied namespaced resources as group:kind",
+			func(o *NewOptions) *[]GroupKind { return &project(o).NamespaceResourceBlacklist }),
+		valuesFileParam(),
+	}
+}
+
+// Render adds the AppProject template and the values.yaml and
+// values.schema.json edits registering its settings
+func (appProjectType) Render(opts NewOptions, plan *Plan) error {
+	if err := addResourceFile(opts, plan, "new/appproject.yaml", struct{ Name string }{Name: opts.Name}); err != nil {
+		return err
+	}
+	if err := planProjectValues(opts, plan); err != nil {
+		return err
+	}
+	return planProjectSchema(opts, plan, opts.valuesFile())
+}
+
+// projectValuesTemplateData is the data available to the appproject-values template
+type projectValuesTemplateData struct {
+	Name        string
+	Description string
+	AppProjectOptions
+}
+
+// renderProjectValues returns the projects.<name> block for values.yaml,
+// indented to sit under the top-level projects key
+func renderProjectValues(opts NewOptions) (string, error) {
+	project := opts.AppProject
+
+	description := project.Description
+	if description == "" {
+		description = fmt.Sprintf("%s ArgoCD Project", opts.Name)
+	}
+	if len(project.Destinations) == 0 {
+		project.Destinations = []Destination{{Namespace: opts.Name, Server: DefaultDestinationServer}}
+	}
+
+	return templateSet(opts.Templates).Render("new/appproject-values.yaml", projectValuesTemplateData{
+		Name:              opts.Name,
+		Description:       description,
+		AppProjectOptions: project,
+	})
+}
+
+// planProjectValues adds the values file edit registering the project under
+// the top-level projects key, leaving the rest of the file untouched
+func planProjectValues(opts NewOptions, plan *Plan) error {
+	valuesFile := opts.valuesFile()
+
+	block, err := renderProjectValues(opts)
+	if err != nil {
+		return err
+	}
+
+	doc, err := readValues(opts.fs(), valuesFile)
+	if err != nil {
+		return err
+	}
+	if doc == nil {
+		plan.Notes = append(plan.Notes, fmt.Sprintf("%s not found, add the following to your values file:\n\nprojects:\n%s", valuesFile, block))
+		return nil
+	}
+
+	path := []string{"projects", opts.Name}
+	if doc.Lookup(path) != nil {
+		plan.Notes = append(plan.Notes, fmt.Sprintf("Project '%s' already present in %s, leaving it unchanged", opts.Name, valuesFile))
+		return nil
+	}
+
+	// The block is rendered below the projects key, parse it as such
+	var rendered yaml.Node
+	if err := yaml.Unmarshal([]byte("projects:\n"+block), &rendered); err != nil {
+		return fmt.Errorf("failed to parse the values of project %s: %w", opts.Name, err)
+	}
+	project := yamledit.Lookup(&rendered, path...)
+	if project == nil {
+		return fmt.Errorf("the values template of project %s does not define projects.%s", opts.Name, opts.Name)
+	}
+
+	if _, err := doc.SetCommented(path, project, "# Additional ArgoCD projects"); err != nil {
+		return fmt.Errorf("failed to edit %s: %w", valuesFile, err)
+	}
+	plan.Files = append(plan.Files, File{Path: valuesFile, Content: string(doc.Bytes())})
+	return nil
+}
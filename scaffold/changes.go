@@ -0,0 +1,155 @@
+package scaffold
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/afero"
+)
+
+// ChangeStatus tells what writing a plan does to a path
+type ChangeStatus string
+
+// Change statuses
+const (
+	StatusCreate    ChangeStatus = "create"
+	StatusOverwrite ChangeStatus = "overwrite"
+	StatusUnchanged ChangeStatus = "unchanged"
+	StatusSkip      ChangeStatus = "skip"
+	StatusRemove    ChangeStatus = "remove"
+)
+
+// Change is the effect of a plan on a single directory or file
+type Change struct {
+	// Path is the planned path, as in the plan
+	Path string
+	// Dir is set for planned directories
+	Dir bool
+	// Edit is set for files the plan edits rather than renders, see
+	// File.TemplateID
+	Edit   bool
+	Status ChangeStatus
+	// Diff is a unified diff of the file, empty for directories and
+	// unchanged files
+	Diff string
+}
+
+// Changes compares the plan with the current state of the filesystem below
+// root. Directories that already exist and removed files that do not are left
+// out.
+func (p *Plan) Changes(fsys afero.Fs, root string) ([]Change, error) {
+	var changes []Change
+
+	for _, dir := range p.Dirs {
+		exists, err := afero.DirExists(fsys, resolve(root, dir))
+		if err != nil {
+			return nil, fmt.Errorf("failed to check directory %s: %w", dir, err)
+		}
+		if !exists {
+			changes = append(changes, Change{Path: dir, Dir: true, Status: StatusCreate})
+		}
+	}
+
+	for _, file := range p.Files {
+		change := Change{Path: file.Path, Edit: file.TemplateID == "", Status: StatusCreate}
+
+		current, err := afero.ReadFile(fsys, resolve(root, file.Path))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return nil, fmt.Errorf("failed to read %s: %w", file.Path, err)
+		case string(current) == file.Content:
+			change.Status = StatusUnchanged
+		default:
+			change.Status = StatusOverwrite
+		}
+
+		if change.Status != StatusUnchanged {
+			if change.Diff, err = unifiedDiff(file.Path, string(current), file.Content, change.Status == StatusCreate); err != nil {
+				return nil, err
+			}
+		}
+
+		changes = append(changes, change)
+	}
+
+	for _, path := range p.Removes {
+		current, err := afero.ReadFile(fsys, resolve(root, path))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		change := Change{Path: path, Status: StatusRemove}
+		if change.Diff, err = removalDiff(path, string(current)); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// unifiedDiff returns a git-style unified diff between the current and the
+// planned content of a file
+func unifiedDiff(path, current, planned string, created bool) (string, error) {
+	from, to := "a/"+path, "b/"+path
+	if filepath.IsAbs(path) {
+		from, to = path, path
+	}
+	if created {
+		from = "/dev/null"
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(current),
+		B:        splitLines(planned),
+		FromFile: from,
+		ToFile:   to,
+		Context:  3,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to diff %s: %w", path, err)
+	}
+	return diff, nil
+}
+
+// removalDiff returns a git-style unified diff deleting a file
+func removalDiff(path, current string) (string, error) {
+	from := "a/" + path
+	if filepath.IsAbs(path) {
+		from = path
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(current),
+		FromFile: from,
+		ToFile:   "/dev/null",
+		Context:  3,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to diff %s: %w", path, err)
+	}
+	return diff, nil
+}
+
+// splitLines splits content into lines for diffing. Unlike
+// difflib.SplitLines it does not add an empty line for the final newline.
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	} else {
+		lines[len(lines)-1] += "\n"
+	}
+	return lines
+}
@@ -0,0 +1,119 @@
+package scaffold
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// ErrScaffoldExists is returned when initializing a directory that already
+// contains an argo-helper scaffold
+var ErrScaffoldExists = errors.New("target already contains an argo-helper scaffold")
+
+// ConflictPolicy decides what happens to planned files that would replace an
+// existing file with different content
+type ConflictPolicy int
+
+const (
+	// ConflictFail refuses to write the plan if any file conflicts
+	ConflictFail ConflictPolicy = iota
+	// ConflictOverwrite replaces the existing files
+	ConflictOverwrite
+	// ConflictSkip keeps the existing files
+	ConflictSkip
+	// ConflictAsk asks for every conflicting file
+	ConflictAsk
+)
+
+// Prompter asks whether to overwrite the file of a conflicting change
+type Prompter func(change Change) (overwrite bool, err error)
+
+// ConflictError lists the existing files a plan would overwrite
+type ConflictError struct {
+	Paths []string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%d existing file(s) would be overwritten: %s", len(e.Paths), strings.Join(e.Paths, ", "))
+}
+
+// Conflicts returns the changes that replace an existing file. Files the plan
+// edits on purpose, like the values file registering a project, are not
+// conflicts.
+func Conflicts(changes []Change) []Change {
+	var conflicts []Change
+	for _, change := range changes {
+		if change.Status == StatusOverwrite && !change.Edit {
+			conflicts = append(conflicts, change)
+		}
+	}
+	return conflicts
+}
+
+// ResolveConflicts applies the policy to the conflicting changes. Files that
+// are kept are removed from the plan and their change is marked as skipped.
+func (p *Plan) ResolveConflicts(changes []Change, policy ConflictPolicy, ask Prompter) error {
+	conflicts := Conflicts(changes)
+	if len(conflicts) == 0 || policy == ConflictOverwrite {
+		return nil
+	}
+
+	if policy == ConflictFail {
+		paths := make([]string, 0, len(conflicts))
+		for _, conflict := range conflicts {
+			paths = append(paths, conflict.Path)
+		}
+		return &ConflictError{Paths: paths}
+	}
+
+	skip := map[string]bool{}
+	for _, conflict := range conflicts {
+		overwrite := false
+		if policy == ConflictAsk {
+			var err error
+			if overwrite, err = ask(conflict); err != nil {
+				return err
+			}
+		}
+		if !overwrite {
+			skip[conflict.Path] = true
+		}
+	}
+
+	files := p.Files[:0]
+	for _, file := range p.Files {
+		if !skip[file.Path] {
+			files = append(files, file)
+		}
+	}
+	p.Files = files
+
+	for i := range changes {
+		if !changes[i].Dir && skip[changes[i].Path] {
+			changes[i].Status = StatusSkip
+			changes[i].Diff = ""
+		}
+	}
+
+	return nil
+}
+
+// HasScaffold reports whether root already contains an argo-helper scaffold,
+// recognized by its chart and helper templates
+func HasScaffold(fsys afero.Fs, root string) (bool, error) {
+	if exists, err := afero.Exists(fsys, resolve(root, "Chart.yaml")); err != nil || !exists {
+		return false, err
+	}
+
+	helpers, err := afero.ReadFile(fsys, resolve(root, "templates/_helpers.tpl"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read templates/_helpers.tpl: %w", err)
+	}
+	return strings.Contains(string(helpers), `define "common.projectName"`), nil
+}
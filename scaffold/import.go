@@ -0,0 +1,491 @@
+package scaffold
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rebelopsio/argo-helper/templates"
+	"github.com/rebelopsio/argo-helper/yamledit"
+)
+
+// importTemplateID is the template imported Applications are rendered with
+const importTemplateID = "import/application.yaml"
+
+// commonLabels are the labels set by the common.labels helper
+var commonLabels = []string{"app.kubernetes.io/managed-by", "app.kubernetes.io/instance", "app.kubernetes.io/part-of"}
+
+// clusterMetadata are the metadata fields set by the cluster rather than the
+// manifest author
+var clusterMetadata = []string{
+	"uid", "resourceVersion", "generation", "creationTimestamp", "deletionTimestamp",
+	"deletionGracePeriodSeconds", "managedFields", "selfLink",
+}
+
+// lastAppliedAnnotation is the copy of the manifest kubectl apply keeps
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// ImportOptions configures the import of existing Application manifests
+type ImportOptions struct {
+	// Path is a manifest file or a directory of them (required). Files may
+	// hold several documents and Kubernetes lists, like the output of
+	// kubectl get applications -o yaml or argocd admin export.
+	Path string
+	// OutputPath is the directory the templates are created in, defaults to
+	// templates/apps
+	OutputPath string
+	// ValuesFile is the values file of the chart, defaults to values.yaml
+	ValuesFile string
+	// Env is the environment the manifests come from. Their values are
+	// written to values/<env>/values.yaml next to the values file, and to the
+	// values file for the Applications it does not have yet. Without it they
+	// are written to the values file.
+	Env string
+	// Templates overrides the built-in templates when set
+	Templates *templates.Set
+
+	WriteOptions
+}
+
+// importTemplateData is the data available to the import template. Blocks
+// are rendered YAML, indented for their place in the template and ending
+// with a newline, or empty.
+type importTemplateData struct {
+	Name      string
+	Namespace string
+	Project   string
+	// Labels are the labels besides the common ones
+	Labels string
+	// Metadata are the metadata fields besides name, namespace and labels
+	Metadata string
+	// HasSource is set for single source Applications, whose repoURL and
+	// targetRevision come from the values
+	HasSource bool
+	// Source are the source fields besides repoURL and targetRevision
+	Source string
+	// DestName is the destination cluster name, when the destination is
+	// given by name rather than server
+	DestName      string
+	DestNamespace string
+	// Spec are the spec fields besides project, source and destination
+	Spec string
+}
+
+// Import converts existing Application manifests into templates of the
+// chart and their values. Relative paths are resolved against the current
+// directory.
+func Import(ctx context.Context, opts ImportOptions) (*Result, error) {
+	plan, err := PlanImport(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return apply(ctx, plan, "", opts.WriteOptions)
+}
+
+// PlanImport plans the templates and values edits importing Application
+// manifests. Every Application gets a template using the common helpers,
+// with its repoURL, targetRevision and destination moved to the values under
+// applications.<name>. Names and namespaces are kept, so ArgoCD adopts the
+// existing Applications rather than recreating them. Documents that are
+// skipped and fields that are kept as-is rather than templated are
+// reported in the notes of the plan.
+func PlanImport(opts ImportOptions) (*Plan, error) {
+	if opts.Path == "" {
+		return nil, fmt.Errorf("path to import is required")
+	}
+	if opts.OutputPath == "" {
+		opts.OutputPath = "templates/apps"
+	}
+
+	files, err := manifestFiles(opts.fs(), opts.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Applications of the chart project use the common.projectName helper
+	valuesFile := NewOptions{ValuesFile: opts.ValuesFile}.valuesFile()
+	values, err := importValues(opts.fs(), valuesFile)
+	if err != nil {
+		return nil, err
+	}
+	chartProject := scalarAt(values.doc, []string{"global", "project"}, "")
+	var envValues *importedValues
+	if opts.Env != "" {
+		envFile := filepath.Join(filepath.Dir(valuesFile), "values", opts.Env, "values.yaml")
+		if envValues, err = importValues(opts.fs(), envFile); err != nil {
+			return nil, err
+		}
+	}
+
+	plan := &Plan{Dirs: []string{opts.OutputPath}}
+	imported := map[string]string{}
+	for _, file := range files {
+		apps, err := readApplications(opts.fs(), file, plan)
+		if err != nil {
+			return nil, err
+		}
+		for _, app := range apps {
+			name := app.name()
+			if previous, ok := imported[name]; ok {
+				plan.Notes = append(plan.Notes, fmt.Sprintf("%s: skipped Application %s, already imported from %s", file, name, previous))
+				continue
+			}
+			imported[name] = file
+
+			data, appValues, notes, err := app.convert(chartProject)
+			if err != nil {
+				return nil, err
+			}
+			for _, note := range notes {
+				plan.Notes = append(plan.Notes, fmt.Sprintf("%s: Application %s: %s", file, name, note))
+			}
+
+			content, err := templateSet(opts.Templates).Render(importTemplateID, data)
+			if err != nil {
+				return nil, err
+			}
+			plan.Files = append(plan.Files, File{
+				Path:       filepath.Join(opts.OutputPath, FileName(TypeApplication, name)),
+				Content:    content,
+				TemplateID: importTemplateID,
+			})
+
+			for _, value := range appValues {
+				path := append([]string{"applications", name}, value.path...)
+				// The first environment imported provides the defaults
+				if envValues == nil || values.doc.Lookup(path) == nil {
+					if err := values.set(path, value.value); err != nil {
+						return nil, err
+					}
+				}
+				if envValues != nil {
+					if err := envValues.set(path, value.value); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+	}
+	if len(imported) == 0 {
+		return nil, fmt.Errorf("no Applications found in %s", opts.Path)
+	}
+
+	for _, v := range []*importedValues{values, envValues} {
+		if v != nil && v.changed {
+			plan.Files = append(plan.Files, File{Path: v.file, Content: string(v.doc.Bytes())})
+		}
+	}
+	return plan, nil
+}
+
+// importedValues is a values file imported values are written to
+type importedValues struct {
+	file    string
+	doc     *yamledit.Document
+	changed bool
+}
+
+// importValues reads a values file for an import, empty when it does not
+// exist
+func importValues(fsys afero.Fs, file string) (*importedValues, error) {
+	doc, err := readValues(fsys, file)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		if doc, err = yamledit.Parse(nil); err != nil {
+			return nil, err
+		}
+	}
+	return &importedValues{file: file, doc: doc}, nil
+}
+
+// set sets a string value
+func (v *importedValues) set(path []string, value string) error {
+	changed, err := v.doc.Set(path, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value})
+	if err != nil {
+		return fmt.Errorf("failed to edit %s: %w", v.file, err)
+	}
+	v.changed = v.changed || changed
+	return nil
+}
+
+// manifestFiles returns the YAML and JSON files of a path, sorted
+func manifestFiles(fsys afero.Fs, path string) ([]string, error) {
+	info, err := fsys.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	err = afero.Walk(fsys, path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".yaml", ".yml", ".json":
+			if !info.IsDir() {
+				files = append(files, file)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// importedApp is an Application manifest being imported
+type importedApp struct {
+	node *yaml.Node
+}
+
+// importedValue is a value of an imported Application, at a path below
+// applications.<name>
+type importedValue struct {
+	path  []string
+	value string
+}
+
+// readApplications returns the Applications of a manifest file, including
+// the items of lists. Other documents are reported in the notes of the plan.
+func readApplications(fsys afero.Fs, file string, plan *Plan) ([]importedApp, error) {
+	data, err := afero.ReadFile(fsys, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+
+	var apps []importedApp
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for i := 1; ; i++ {
+		var doc yaml.Node
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			plan.Notes = append(plan.Notes, fmt.Sprintf("%s: skipped, failed to parse: %v", file, err))
+			return apps, nil
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+
+		resources := []*yaml.Node{doc.Content[0]}
+		if items := yamledit.Lookup(doc.Content[0], "items"); items != nil && strings.HasSuffix(yamledit.Scalar(doc.Content[0], "kind"), "List") {
+			resources = items.Content
+		}
+		for _, resource := range resources {
+			app := importedApp{node: resource}
+			kind := yamledit.Scalar(resource, "kind")
+			switch {
+			case kind == "Application" && strings.HasPrefix(yamledit.Scalar(resource, "apiVersion"), "argoproj.io/"):
+				if owner := app.applicationSet(); owner != "" {
+					plan.Notes = append(plan.Notes, fmt.Sprintf("%s: skipped Application %s, it is generated by ApplicationSet %s", file, app.name(), owner))
+					continue
+				}
+				if app.name() == "" {
+					plan.Notes = append(plan.Notes, fmt.Sprintf("%s: skipped document %d, an Application without a name", file, i))
+					continue
+				}
+				apps = append(apps, app)
+			case kind == "":
+				plan.Notes = append(plan.Notes, fmt.Sprintf("%s: skipped document %d, not a Kubernetes resource", file, i))
+			default:
+				plan.Notes = append(plan.Notes, fmt.Sprintf("%s: skipped %s %s, only Applications are imported", file, kind, app.name()))
+			}
+		}
+	}
+	return apps, nil
+}
+
+func (a importedApp) name() string {
+	return yamledit.Scalar(yamledit.Lookup(a.node, "metadata"), "name")
+}
+
+// applicationSet returns the ApplicationSet owning the Application, if any
+func (a importedApp) applicationSet() string {
+	owners := yamledit.Lookup(yamledit.Lookup(a.node, "metadata"), "ownerReferences")
+	if owners == nil {
+		return ""
+	}
+	for _, owner := range owners.Content {
+		if yamledit.Scalar(owner, "kind") == "ApplicationSet" {
+			return yamledit.Scalar(owner, "name")
+		}
+	}
+	return ""
+}
+
+// convert returns the template data and values of the Application, and
+// notes about what could not be templated
+func (a importedApp) convert(chartProject string) (importTemplateData, []importedValue, []string, error) {
+	var notes []string
+	metadata := yamledit.Lookup(a.node, "metadata")
+	spec := yamledit.Lookup(a.node, "spec")
+
+	data := importTemplateData{
+		Name:      a.name(),
+		Namespace: yamledit.Scalar(metadata, "namespace"),
+		Project:   yamledit.Scalar(spec, "project"),
+	}
+	if data.Namespace == "" {
+		data.Namespace = "argocd"
+	}
+	switch data.Project {
+	case "":
+		data.Project = "default"
+	case chartProject:
+		data.Project = `{{ include "common.projectName" . }}`
+	}
+
+	labels := withoutKeys(yamledit.Lookup(metadata, "labels"), commonLabels...)
+	for _, key := range commonLabels {
+		if value := yamledit.Scalar(yamledit.Lookup(metadata, "labels"), key); value != "" {
+			notes = append(notes, fmt.Sprintf("label %s=%s replaced by common.labels", key, value))
+		}
+	}
+	metadata = withoutKeys(metadata, append([]string{"name", "namespace", "labels", "ownerReferences"}, clusterMetadata...)...)
+	if annotations := withoutKeys(yamledit.Lookup(metadata, "annotations"), lastAppliedAnnotation); len(annotations.Content) > 0 {
+		replaceValue(metadata, "annotations", annotations)
+	} else {
+		metadata = withoutKeys(metadata, "annotations")
+	}
+
+	var values []importedValue
+	source := yamledit.Lookup(spec, "source")
+	if source != nil && source.Kind == yaml.MappingNode {
+		revision := yamledit.Scalar(source, "targetRevision")
+		if revision == "" {
+			// ArgoCD tracks the default branch when the revision is not set
+			revision = "HEAD"
+		}
+		values = append(values,
+			importedValue{path: []string{"repoURL"}, value: yamledit.Scalar(source, "repoURL")},
+			importedValue{path: []string{"targetRevision"}, value: revision},
+		)
+		data.HasSource = true
+		source = withoutKeys(source, "repoURL", "targetRevision")
+	} else {
+		source = nil
+		if yamledit.Lookup(spec, "sources") != nil {
+			notes = append(notes, "spec.sources kept as-is, multiple sources are not templated")
+		}
+	}
+
+	destination := yamledit.Lookup(spec, "destination")
+	if name := yamledit.Scalar(destination, "name"); name != "" && yamledit.Scalar(destination, "server") == "" {
+		data.DestName = name
+		values = append(values, importedValue{path: []string{"destination", "name"}, value: name})
+	} else {
+		values = append(values, importedValue{path: []string{"destination", "server"}, value: yamledit.Scalar(destination, "server")})
+	}
+	if namespace := yamledit.Scalar(destination, "namespace"); namespace != "" {
+		data.DestNamespace = namespace
+		values = append(values, importedValue{path: []string{"destination", "namespace"}, value: namespace})
+	}
+	spec = withoutKeys(spec, "project", "source", "destination")
+
+	var err error
+	escaped := false
+	blocks := []struct {
+		node   *yaml.Node
+		indent int
+		text   *string
+	}{
+		{labels, 4, &data.Labels},
+		{metadata, 2, &data.Metadata},
+		{source, 4, &data.Source},
+		{spec, 2, &data.Spec},
+	}
+	for _, b := range blocks {
+		var blockEscaped bool
+		if *b.text, blockEscaped, err = block(b.node, b.indent); err != nil {
+			return data, nil, nil, fmt.Errorf("failed to render Application %s: %w", data.Name, err)
+		}
+		escaped = escaped || blockEscaped
+	}
+	if escaped {
+		notes = append(notes, "template actions {{ }} in its fields escaped so Helm keeps them, check them")
+	}
+
+	return data, values, notes, nil
+}
+
+// block renders the fields of a mapping as YAML indented by indent spaces.
+// Template actions in its strings are escaped so Helm keeps them, escaped
+// tells whether there were any.
+func block(mapping *yaml.Node, indent int) (text string, escaped bool, err error) {
+	if mapping == nil || len(mapping.Content) == 0 {
+		return "", false, nil
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(mapping); err != nil {
+		return "", false, err
+	}
+	if err := enc.Close(); err != nil {
+		return "", false, err
+	}
+
+	text = buf.String()
+	if strings.Contains(text, "{{") {
+		escaped = true
+		text = strings.ReplaceAll(text, "{{", `{{ "{{" }}`)
+	}
+
+	prefix := strings.Repeat(" ", indent)
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if line != "" {
+			b.WriteString(prefix + line)
+		}
+	}
+	return b.String(), escaped, nil
+}
+
+// withoutKeys returns a copy of a mapping node without some keys, an empty
+// mapping when node is not a mapping
+func withoutKeys(node *yaml.Node, keys ...string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+	copied := *node
+	copied.Content = nil
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		remove := false
+		for _, key := range keys {
+			remove = remove || node.Content[i].Value == key
+		}
+		if !remove {
+			copied.Content = append(copied.Content, node.Content[i], node.Content[i+1])
+		}
+	}
+	return &copied
+}
+
+// replaceValue replaces the value of a key of a mapping node
+func replaceValue(node *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content[i+1] = value
+		}
+	}
+}
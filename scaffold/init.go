@@ -0,0 +1,120 @@
+package scaffold
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rebelopsio/argo-helper/templates"
+)
+
+// InitOptions configures the repository scaffold
+type InitOptions struct {
+	// Path is the repository root, defaults to the current directory
+	Path string
+	// Project is the name of the ArgoCD project (required)
+	Project string
+	// Examples adds example applications, an ApplicationSet and
+	// environment values
+	Examples bool
+	// Date is recorded in Chart.yaml, defaults to today
+	Date time.Time
+	// Templates overrides the built-in templates when set
+	Templates *templates.Set
+
+	WriteOptions
+}
+
+// Init creates the repository scaffold. Unless a conflict policy other than
+// ConflictFail is set, it refuses to run when the repository already
+// contains a scaffold.
+func Init(ctx context.Context, opts InitOptions) (*Result, error) {
+	root := opts.Path
+	if root == "" {
+		root = "."
+	}
+
+	if opts.Conflicts == ConflictFail {
+		exists, err := HasScaffold(opts.fs(), root)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, fmt.Errorf("%s: %w", root, ErrScaffoldExists)
+		}
+	}
+
+	plan, err := PlanInit(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return apply(ctx, plan, root, opts.WriteOptions)
+}
+
+// initTemplateData is the data available to the init scaffold templates
+type initTemplateData struct {
+	Project string
+	Date    string
+}
+
+// PlanInit plans the repository structure created by init. File paths are
+// relative to the repository root.
+func PlanInit(opts InitOptions) (*Plan, error) {
+	if opts.Project == "" {
+		return nil, fmt.Errorf("project name is required")
+	}
+
+	date := opts.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	plan := &Plan{
+		Dirs: []string{
+			"custom-resources",
+			"values",
+			"templates/apps",
+			"templates/projects",
+		},
+	}
+
+	prefixes := []string{"init"}
+	if opts.Examples {
+		plan.Dirs = append(plan.Dirs, "examples", "values/dev", "values/prod")
+		prefixes = append(prefixes, "init-examples")
+	}
+
+	set := templateSet(opts.Templates)
+	data := initTemplateData{
+		Project: opts.Project,
+		Date:    date.Format("2006-01-02"),
+	}
+
+	for _, prefix := range prefixes {
+		list, err := set.ListPrefix(prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range list {
+			content, err := set.Render(t.ID, data)
+			if err != nil {
+				return nil, err
+			}
+			plan.Files = append(plan.Files, File{
+				Path:       strings.TrimPrefix(t.ID, prefix+"/"),
+				Content:    content,
+				TemplateID: t.ID,
+			})
+		}
+	}
+
+	manifest, err := Manifest{Version: ScaffoldVersion, Project: opts.Project, Created: data.Date}.file()
+	if err != nil {
+		return nil, err
+	}
+	plan.Files = append(plan.Files, manifest)
+
+	return plan, nil
+}
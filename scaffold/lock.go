@@ -0,0 +1,226 @@
+package scaffold
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+// LockFile records the files argo-helper generated, relative to the
+// repository root
+const LockFile = ".argo-helper/lock.yaml"
+
+// ErrNoLock is returned for repositories without a LockFile
+var ErrNoLock = errors.New("no generated files are recorded")
+
+// Lock is the content of LockFile
+type Lock struct {
+	// Files are the generated files by path, slash separated
+	Files map[string]LockedFile `yaml:"files"`
+}
+
+// LockedFile is how a generated file was last written
+type LockedFile struct {
+	// Template is the template the file was rendered from
+	Template string `yaml:"template" json:"template"`
+	// Version is the scaffold version the template was rendered at
+	Version int `yaml:"version" json:"version"`
+	// SHA256 is the hash of the content argo-helper last wrote
+	SHA256 string `yaml:"sha256" json:"sha256"`
+}
+
+// FileState tells whether a generated file changed since it was written
+type FileState string
+
+// File states
+const (
+	StatePristine FileState = "pristine"
+	StateModified FileState = "modified"
+	StateMissing  FileState = "missing"
+)
+
+// FileStatus is the state of a generated file
+type FileStatus struct {
+	Path       string `yaml:"path" json:"path"`
+	LockedFile `yaml:",inline"`
+	State      FileState `yaml:"state" json:"state"`
+}
+
+// ReadLock reads the lock file of the repository in root, nil when it has
+// none, like repositories created before the lock file
+func ReadLock(fsys afero.Fs, root string) (*Lock, error) {
+	data, err := afero.ReadFile(fsys, resolve(root, LockFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", LockFile, err)
+	}
+
+	lock := &Lock{}
+	if err := yaml.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", LockFile, err)
+	}
+	if lock.Files == nil {
+		lock.Files = map[string]LockedFile{}
+	}
+	return lock, nil
+}
+
+// Status compares the generated files of the repository in root with the
+// content argo-helper last wrote, sorted by path. Edits made by argo-helper
+// itself, like new registering an application in values.yaml, keep files
+// pristine.
+func Status(fsys afero.Fs, root string) ([]FileStatus, error) {
+	lock, err := ReadLock(fsys, root)
+	if err != nil {
+		return nil, err
+	}
+	if lock == nil {
+		return nil, fmt.Errorf("%s: %w", root, ErrNoLock)
+	}
+
+	statuses := make([]FileStatus, 0, len(lock.Files))
+	for path, locked := range lock.Files {
+		status := FileStatus{Path: path, LockedFile: locked, State: StatePristine}
+		content, err := afero.ReadFile(fsys, resolve(root, filepath.FromSlash(path)))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			status.State = StateMissing
+		case err != nil:
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		case hash(string(content)) != locked.SHA256:
+			status.State = StateModified
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Path < statuses[j].Path })
+	return statuses, nil
+}
+
+// lockFile returns the lock file of root updated for the plan, or false when
+// the plan does not change it. Rendered files are recorded with their
+// template. Edited files keep their record and stay pristine if they were,
+// their hand edits are kept by the edit. Removed files are forgotten.
+// Repositories without a lock file only get one from plans starting it, see
+// startsLock.
+func (p *Plan) lockFile(fsys afero.Fs, root string) (File, bool, error) {
+	lock, err := ReadLock(fsys, root)
+	if err != nil {
+		return File{}, false, err
+	}
+	if lock == nil {
+		starts, err := p.startsLock(fsys, root)
+		if err != nil || !starts {
+			return File{}, false, err
+		}
+		lock = &Lock{Files: map[string]LockedFile{}}
+	}
+
+	changed := false
+	for _, file := range p.Files {
+		path, ok := lockPath(root, file.Path)
+		if !ok {
+			continue
+		}
+		sum := hash(file.Content)
+
+		if file.TemplateID != "" {
+			locked := LockedFile{Template: file.TemplateID, Version: ScaffoldVersion, SHA256: sum}
+			if lock.Files[path] != locked {
+				lock.Files[path] = locked
+				changed = true
+			}
+			continue
+		}
+
+		locked, ok := lock.Files[path]
+		if !ok || locked.SHA256 == sum {
+			continue
+		}
+		current, err := afero.ReadFile(fsys, resolve(root, file.Path))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return File{}, false, fmt.Errorf("failed to read %s: %w", file.Path, err)
+		}
+		if err == nil && hash(string(current)) != locked.SHA256 {
+			continue
+		}
+		locked.SHA256 = sum
+		lock.Files[path] = locked
+		changed = true
+	}
+
+	for _, removed := range p.Removes {
+		if path, ok := lockPath(root, removed); ok {
+			if _, ok := lock.Files[path]; ok {
+				delete(lock.Files, path)
+				changed = true
+			}
+		}
+	}
+
+	if !changed {
+		return File{}, false, nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("# Files generated by argo-helper, see argo-helper status\n")
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(lock); err != nil {
+		return File{}, false, fmt.Errorf("failed to encode %s: %w", LockFile, err)
+	}
+	if err := enc.Close(); err != nil {
+		return File{}, false, fmt.Errorf("failed to encode %s: %w", LockFile, err)
+	}
+	return File{Path: LockFile, Content: buf.String()}, true, nil
+}
+
+// startsLock reports whether the plan starts the lock file of a repository
+// without one: plans writing the scaffold manifest, like init and upgrade,
+// and plans for repositories with a scaffold created before the lock file.
+// A template created by new in a plain directory is not tracked.
+func (p *Plan) startsLock(fsys afero.Fs, root string) (bool, error) {
+	for _, file := range p.Files {
+		if resolve(root, file.Path) == resolve(root, ManifestFile) {
+			return true, nil
+		}
+	}
+	return HasScaffold(fsys, root)
+}
+
+// lockPath returns the path of a planned file in the lock file, false for
+// files outside root and the files of argo-helper itself
+func lockPath(root, path string) (string, bool) {
+	if filepath.IsAbs(path) {
+		absRoot, err := filepath.Abs(root)
+		if err != nil {
+			return "", false
+		}
+		if path, err = filepath.Rel(absRoot, path); err != nil {
+			return "", false
+		}
+	}
+
+	path = filepath.ToSlash(filepath.Clean(path))
+	if path == ".." || strings.HasPrefix(path, "../") || strings.HasPrefix(path, ".argo-helper/") {
+		return "", false
+	}
+	return path, true
+}
+
+// hash returns the hex SHA-256 of content
+func hash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,128 @@
+package scaffold
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// MergeConflict is a region both the user and the scaffold changed
+type MergeConflict struct {
+	// Line is the first line of the region in the user's file, starting at 1
+	Line int
+	// Ours, Base and Theirs are the lines of the region in the user's file,
+	// in the previous scaffold and in the new scaffold
+	Ours, Base, Theirs []string
+}
+
+// String formats the conflict with git's diff3 conflict markers
+func (c MergeConflict) String() string {
+	var b strings.Builder
+	b.WriteString("<<<<<<< yours\n")
+	b.WriteString(strings.Join(c.Ours, ""))
+	b.WriteString("||||||| previous scaffold\n")
+	b.WriteString(strings.Join(c.Base, ""))
+	b.WriteString("=======\n")
+	b.WriteString(strings.Join(c.Theirs, ""))
+	b.WriteString(">>>>>>> new scaffold\n")
+	return b.String()
+}
+
+// hunk is a change of a range of base lines
+type hunk struct {
+	// from and to are the replaced base lines, from == to for insertions
+	from, to int
+	lines    []string
+	theirs   bool
+}
+
+// merge3 applies the changes from base to theirs onto ours, line by line.
+// Changes of the same base lines, or insertions at the same line, are
+// merged when both sides made the same change and are conflicts otherwise.
+// The merged content is only valid without conflicts.
+func merge3(base, ours, theirs string) (string, []MergeConflict) {
+	baseLines := splitLines(base)
+	oursHunks := hunks(baseLines, splitLines(ours), false)
+	theirsHunks := hunks(baseLines, splitLines(theirs), true)
+	all := append(oursHunks, theirsHunks...)
+	// Insertions go before the changes starting at the same line
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].from != all[j].from {
+			return all[i].from < all[j].from
+		}
+		return all[i].to < all[j].to
+	})
+
+	var merged []string
+	var conflicts []MergeConflict
+	pos := 0
+	for i := 0; i < len(all); {
+		// Group the hunks overlapping the first one, insertions at the
+		// same line overlap
+		from, to := all[i].from, all[i].to
+		j := i + 1
+		for j < len(all) && (all[j].from < to || all[j].from == from && all[j].to == from && to == from) {
+			if all[j].to > to {
+				to = all[j].to
+			}
+			j++
+		}
+		group := all[i:j]
+		i = j
+
+		merged = append(merged, baseLines[pos:from]...)
+		pos = to
+
+		var oursGroup, theirsGroup []hunk
+		for _, h := range group {
+			if h.theirs {
+				theirsGroup = append(theirsGroup, h)
+			} else {
+				oursGroup = append(oursGroup, h)
+			}
+		}
+		oursLines := apply3(baseLines, from, to, oursGroup)
+		theirsLines := apply3(baseLines, from, to, theirsGroup)
+		switch {
+		case len(theirsGroup) == 0:
+			merged = append(merged, oursLines...)
+		case len(oursGroup) == 0 || strings.Join(oursLines, "") == strings.Join(theirsLines, ""):
+			merged = append(merged, theirsLines...)
+		default:
+			conflicts = append(conflicts, MergeConflict{
+				Line:   len(merged) + 1,
+				Ours:   oursLines,
+				Base:   baseLines[from:to],
+				Theirs: theirsLines,
+			})
+			merged = append(merged, oursLines...)
+		}
+	}
+	merged = append(merged, baseLines[pos:]...)
+	return strings.Join(merged, ""), conflicts
+}
+
+// hunks returns the changes from base to lines
+func hunks(base, lines []string, theirs bool) []hunk {
+	var result []hunk
+	for _, op := range difflib.NewMatcher(base, lines).GetOpCodes() {
+		if op.Tag == 'e' {
+			continue
+		}
+		result = append(result, hunk{from: op.I1, to: op.I2, lines: lines[op.J1:op.J2], theirs: theirs})
+	}
+	return result
+}
+
+// apply3 returns the base lines from..to with the hunks of one side applied
+func apply3(base []string, from, to int, side []hunk) []string {
+	var lines []string
+	pos := from
+	for _, h := range side {
+		lines = append(lines, base[pos:h.from]...)
+		lines = append(lines, h.lines...)
+		pos = h.to
+	}
+	return append(lines, base[pos:to]...)
+}
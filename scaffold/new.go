@@ -0,0 +1,222 @@
+package scaffold
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/rebelopsio/argo-helper/templates"
+)
+
+// Supported resource types
+const (
+	TypeApplication    = "application"
+	TypeApplicationSet = "applicationset"
+	TypeAppProject     = "appproject"
+)
+
+// SyncPolicies are the supported application sync policies
+var SyncPolicies = []string{"default", "automated", "manual"}
+
+// NewOptions configures a new resource
+type NewOptions struct {
+	// Type is the resource type (required)
+	Type string
+	// Name is the resource name (required)
+	Name string
+	// OutputPath is the directory the resource is created in, defaults to
+	// templates/apps or templates/projects depending on the type
+	OutputPath string
+	// ValuesFile is the values file resources register their values in,
+	// defaults to values.yaml
+	ValuesFile string
+	// Templates overrides the built-in templates when set
+	Templates *templates.Set
+
+	Application    ApplicationOptions
+	ApplicationSet ApplicationSetOptions
+	AppProject     AppProjectOptions
+
+	WriteOptions
+}
+
+// ApplicationOptions configures an Application. Empty fields fall back to
+// the chart defaults.
+type ApplicationOptions struct {
+	SourcePath    string
+	DestNamespace string
+	DestServer    string
+	Project       string
+	// SyncPolicy is one of SyncPolicies, defaults to "default"
+	SyncPolicy string
+}
+
+// valuesFile returns the values file resources register their values in
+func (o NewOptions) valuesFile() string {
+	if o.ValuesFile == "" {
+		return "values.yaml"
+	}
+	return o.ValuesFile
+}
+
+// valuesFileParam is the parameter of the resource types registering their
+// values in the values file
+func valuesFileParam() Parameter {
+	return stringParam("values-file", "values file to register the resource in, next to values/<env>/values.yaml (default is values.yaml)",
+		func(o *NewOptions) *string { return &o.ValuesFile })
+}
+
+// FileName returns the name of the file a resource is written to
+func FileName(resourceType, name string) string {
+	return fmt.Sprintf("%s-%s.yaml", resourceType, name)
+}
+
+// New creates a new resource. Relative paths are resolved against the
+// current directory.
+func New(ctx context.Context, opts NewOptions) (*Result, error) {
+	plan, err := PlanNew(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return apply(ctx, plan, "", opts.WriteOptions)
+}
+
+// PlanNew plans the files created for a new resource
+func PlanNew(opts NewOptions) (*Plan, error) {
+	resourceType, ok := LookupResourceType(opts.Type)
+	if !ok {
+		return nil, fmt.Errorf("unsupported resource type: %s (must be one of %s)",
+			opts.Type, strings.Join(ResourceTypeNames(), ", "))
+	}
+
+	if opts.Name == "" {
+		return nil, fmt.Errorf("resource name is required")
+	}
+
+	if opts.OutputPath == "" {
+		opts.OutputPath = resourceType.OutputPath()
+	}
+
+	plan := &Plan{Dirs: []string{opts.OutputPath}}
+	if err := resourceType.Render(opts, plan); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// addResourceFile adds the main file of a resource, rendered from the given
+// template, to the plan
+func addResourceFile(opts NewOptions, plan *Plan, templateID string, data interface{}) error {
+	content, err := templateSet(opts.Templates).Render(templateID, data)
+	if err != nil {
+		return err
+	}
+
+	plan.Files = append(plan.Files, File{
+		Path:       filepath.Join(opts.OutputPath, FileName(opts.Type, opts.Name)),
+		Content:    content,
+		TemplateID: templateID,
+	})
+	return nil
+}
+
+func init() {
+	Register(applicationType{})
+}
+
+// applicationType creates a single ArgoCD Application
+type applicationType struct{}
+
+func (applicationType) Name() string { return TypeApplication }
+
+func (applicationType) Description() string { return "A single ArgoCD Application" }
+
+func (applicationType) OutputPath() string { return "templates/apps" }
+
+func (applicationType) Parameters() []Parameter {
+	return []Parameter{
+		stringParam("path", "application source path in the repository (default is apps/<name>)",
+			func(o *NewOptions) *string { return &o.Application.SourcePath }),
+		stringParam("dest-namespace", "application destination namespace (default is <name>)",
+			func(o *NewOptions) *string { return &o.Application.DestNamespace }),
+		stringParam("dest-server", "application destination server (default is .Values.destination.server)",
+			func(o *NewOptions) *string { return &o.Application.DestServer }),
+		stringParam("project", "ArgoCD project of the application (default is the chart project)",
+			func(o *NewOptions) *string { return &o.Application.Project }),
+		choiceParam("sync-policy", "application sync policy: default, automated or manual", "default", SyncPolicies,
+			func(o *NewOptions) *string { return &o.Application.SyncPolicy }),
+		valuesFileParam(),
+	}
+}
+
+// Render adds the Application template and the values edits registering
+// its target revision
+func (applicationType) Render(opts NewOptions, plan *Plan) error {
+	data, err := applicationData(opts)
+	if err != nil {
+		return err
+	}
+	if err := addResourceFile(opts, plan, "new/application.yaml", data); err != nil {
+		return err
+	}
+	return planAppValues(opts, plan)
+}
+
+// applicationTemplateData is the data available to the application template
+type applicationTemplateData struct {
+	Name          string
+	Project       string
+	SourcePath    string
+	DestServer    string
+	DestNamespace string
+	SyncPolicy    string
+}
+
+func applicationData(opts NewOptions) (applicationTemplateData, error) {
+	app := opts.Application
+
+	syncPolicy := app.SyncPolicy
+	if syncPolicy == "" {
+		syncPolicy = "default"
+	}
+	if !containsString(SyncPolicies, syncPolicy) {
+		return applicationTemplateData{}, fmt.Errorf("unsupported sync policy: %s (must be one of %v)", syncPolicy, SyncPolicies)
+	}
+
+	// Fall back to the chart-wide defaults for anything not set
+	data := applicationTemplateData{
+		Name:          opts.Name,
+		Project:       `{{ include "common.projectName" . }}`,
+		SourcePath:    "apps/" + opts.Name,
+		DestServer:    `{{ .Values.destination.server | default "https://kubernetes.default.svc" }}`,
+		DestNamespace: opts.Name,
+		SyncPolicy:    syncPolicy,
+	}
+
+	if app.SourcePath != "" {
+		data.SourcePath = app.SourcePath
+	}
+	if app.DestNamespace != "" {
+		data.DestNamespace = app.DestNamespace
+	}
+	if app.DestServer != "" {
+		data.DestServer = app.DestServer
+	}
+	if app.Project != "" {
+		data.Project = app.Project
+	}
+
+	return data, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
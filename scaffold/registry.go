@@ -0,0 +1,173 @@
+package scaffold
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ResourceType is a resource the new command can create. Resource types are
+// registered with Register and read by the CLI flags, help text, shell
+// completion and the TUI form.
+type ResourceType interface {
+	// Name is the resource type as passed to the new command
+	Name() string
+	// Description is a one-line summary for help text and the TUI
+	Description() string
+	// Parameters are the settings specific to this resource type
+	Parameters() []Parameter
+	// OutputPath is the default directory the resource is created in
+	OutputPath() string
+	// Render adds the files of the resource to the plan. opts.OutputPath is
+	// always set when Render is called.
+	Render(opts NewOptions, plan *Plan) error
+}
+
+// ParameterKind tells how a parameter value is entered
+type ParameterKind int
+
+const (
+	// StringParameter is a single value
+	StringParameter ParameterKind = iota
+	// ListParameter is a comma-separated list of values
+	ListParameter
+	// RepeatedParameter is a list of values that may contain commas,
+	// entered one at a time
+	RepeatedParameter
+	// MapParameter is a comma-separated list of key=value pairs
+	MapParameter
+)
+
+// Parameter is a setting of a resource type
+type Parameter struct {
+	// Name is the parameter name, used as the CLI flag name
+	Name string
+	// Description is a one-line summary including the default behavior
+	Description string
+	// Kind tells how the value is entered
+	Kind ParameterKind
+	// Default is the value used when the parameter is not set, for display
+	Default string
+	// Choices are the allowed values, empty if any value is allowed
+	Choices []string
+	// Apply sets the parameter on the options. Scalars receive a single
+	// value, maps receive key=value entries.
+	Apply func(opts *NewOptions, values []string) error
+}
+
+var registry = map[string]ResourceType{}
+
+// Register adds a resource type to the registry. It panics if a resource
+// type with the same name is already registered.
+func Register(t ResourceType) {
+	if _, exists := registry[t.Name()]; exists {
+		panic(fmt.Sprintf("scaffold: resource type %s registered twice", t.Name()))
+	}
+	registry[t.Name()] = t
+}
+
+// LookupResourceType returns the registered resource type with the given name
+func LookupResourceType(name string) (ResourceType, bool) {
+	t, ok := registry[name]
+	return t, ok
+}
+
+// ResourceTypes returns all registered resource types sorted by name
+func ResourceTypes() []ResourceType {
+	types := make([]ResourceType, 0, len(registry))
+	for _, t := range registry {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Name() < types[j].Name() })
+	return types
+}
+
+// ResourceTypeNames returns the names of all registered resource types
+func ResourceTypeNames() []string {
+	types := ResourceTypes()
+	names := make([]string, 0, len(types))
+	for _, t := range types {
+		names = append(names, t.Name())
+	}
+	return names
+}
+
+// stringParam returns a parameter setting a single string option
+func stringParam(name, description string, target func(*NewOptions) *string) Parameter {
+	return Parameter{
+		Name:        name,
+		Description: description,
+		Kind:        StringParameter,
+		Apply: func(opts *NewOptions, values []string) error {
+			*target(opts) = strings.Join(values, ",")
+			return nil
+		},
+	}
+}
+
+// choiceParam returns a parameter setting a string option from a fixed set
+func choiceParam(name, description, def string, choices []string, target func(*NewOptions) *string) Parameter {
+	p := stringParam(name, description, target)
+	p.Default = def
+	p.Choices = choices
+	p.Apply = func(opts *NewOptions, values []string) error {
+		value := strings.Join(values, ",")
+		if !containsString(choices, value) {
+			return fmt.Errorf("invalid --%s: %s (must be one of %s)", name, value, strings.Join(choices, ", "))
+		}
+		*target(opts) = value
+		return nil
+	}
+	return p
+}
+
+// listParam returns a parameter setting a list option
+func listParam(name, description string, target func(*NewOptions) *[]string) Parameter {
+	return Parameter{
+		Name:        name,
+		Description: description,
+		Kind:        ListParameter,
+		Apply: func(opts *NewOptions, values []string) error {
+			*target(opts) = values
+			return nil
+		},
+	}
+}
+
+// mapParam returns a parameter setting a key=value map option
+func mapParam(name, description string, target func(*NewOptions) *map[string]string) Parameter {
+	return Parameter{
+		Name:        name,
+		Description: description,
+		Kind:        MapParameter,
+		Apply: func(opts *NewOptions, values []string) error {
+			m := make(map[string]string, len(values))
+			for _, entry := range values {
+				key, value, found := strings.Cut(entry, "=")
+				if !found || key == "" {
+					return fmt.Errorf("invalid --%s entry %q (expected key=value)", name, entry)
+				}
+				m[key] = value
+			}
+			*target(opts) = m
+			return nil
+		},
+	}
+}
+
+// groupKindsParam returns a parameter setting a list of group:kind resources
+func groupKindsParam(name, description string, target func(*NewOptions) *[]GroupKind) Parameter {
+	return Parameter{
+		Name:        name,
+		Description: description,
+		Kind:        ListParameter,
+		Apply: func(opts *NewOptions, values []string) error {
+			groupKinds, err := ParseGroupKinds(values)
+			if err != nil {
+				return fmt.Errorf("invalid --%s: %w", name, err)
+			}
+			*target(opts) = groupKinds
+			return nil
+		},
+	}
+}
@@ -0,0 +1,68 @@
+// Package scaffold is the generation engine behind the init, new, import and
+// upgrade commands. Planners take an options struct and return the
+// directories and files they would create, so the CLI, the TUI and dry runs
+// all share the exact same output.
+//
+// Init and New plan and write in one step. They are the entry points for
+// embedding argo-helper in other tools:
+//
+//	result, err := scaffold.Init(ctx, scaffold.InitOptions{
+//		Path:    "gitops",
+//		Project: "platform",
+//	})
+package scaffold
+
+import (
+	"path/filepath"
+
+	"github.com/spf13/afero"
+
+	"github.com/rebelopsio/argo-helper/templates"
+)
+
+// File is a file planned by a generator
+type File struct {
+	// Path is relative to the plan root, or absolute
+	Path string
+	// Content is the full content of the file
+	Content string
+	// TemplateID is the template the file was rendered from, empty for
+	// files that are edited rather than rendered
+	TemplateID string
+}
+
+// Plan is the list of directories and files a generator creates
+type Plan struct {
+	// Dirs are directories to create, even when they stay empty
+	Dirs []string
+	// Files are files to create or replace
+	Files []File
+	// Removes are files to delete, e.g. templates replaced by another one.
+	// Copy-on-write filesystems cannot remove the files of their base layer,
+	// dry runs of plans removing files compare them with Changes instead.
+	Removes []string
+	// Notes are messages for the user about the plan, e.g. skipped edits
+	Notes []string
+}
+
+// Write creates the planned directories and files below root. Paths in the
+// plan that are absolute are written as-is. The write is all or nothing, see
+// WriteFS.
+func (p *Plan) Write(root string) error {
+	return p.WriteFS(afero.NewOsFs(), root)
+}
+
+func resolve(root, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(root, path)
+}
+
+// templateSet returns set, or the built-in templates if set is nil
+func templateSet(set *templates.Set) *templates.Set {
+	if set == nil {
+		return templates.New("")
+	}
+	return set
+}
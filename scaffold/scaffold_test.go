@@ -0,0 +1,963 @@
+package scaffold
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+func TestPlanInit(t *testing.T) {
+	plan, err := PlanInit(InitOptions{
+		Project:  "demo",
+		Examples: true,
+		Date:     time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	files := map[string]string{}
+	for _, file := range plan.Files {
+		files[file.Path] = file.Content
+	}
+
+	for _, path := range []string{
+		".helmignore",
+		"Chart.yaml",
+		"values.yaml",
+		"values.schema.json",
+		"README.md",
+		"templates/_helpers.tpl",
+		"templates/projects/project.yaml",
+		"templates/apps/example-app.yaml",
+		"examples/applicationset.yaml",
+		"values/dev/values.yaml",
+		"values/prod/values.yaml",
+	} {
+		if _, ok := files[path]; !ok {
+			t.Errorf("Expected %s to be planned", path)
+		}
+	}
+
+	if !strings.Contains(files["Chart.yaml"], "created: 2024-01-02") {
+		t.Errorf("Expected Chart.yaml to contain the creation date:\n%s", files["Chart.yaml"])
+	}
+
+	if _, err := PlanInit(InitOptions{}); err == nil {
+		t.Errorf("Expected error for missing project name")
+	}
+}
+
+func TestPlanWrite(t *testing.T) {
+	root := t.TempDir()
+
+	plan, err := PlanInit(InitOptions{Project: "demo"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := plan.Write(root); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, dir := range plan.Dirs {
+		if info, err := os.Stat(filepath.Join(root, dir)); err != nil || !info.IsDir() {
+			t.Errorf("Expected directory %s to be created", dir)
+		}
+	}
+	for _, file := range plan.Files {
+		data, err := os.ReadFile(filepath.Join(root, file.Path))
+		if err != nil {
+			t.Errorf("Expected file %s to be created: %v", file.Path, err)
+			continue
+		}
+		if string(data) != file.Content {
+			t.Errorf("Content of %s differs from the plan", file.Path)
+		}
+	}
+}
+
+func TestPlanNewApplication(t *testing.T) {
+	testCases := []struct {
+		syncPolicy string
+		expected   string
+	}{
+		{"", "toYaml .Values.applications.defaults.syncPolicy"},
+		{"automated", "selfHeal: true"},
+		{"manual", "syncOptions:"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.syncPolicy, func(t *testing.T) {
+			plan, err := PlanNew(NewOptions{
+				Type:        TypeApplication,
+				Name:        "web",
+				Application: ApplicationOptions{SyncPolicy: tc.syncPolicy},
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			file := plan.Files[0]
+			if file.Path != filepath.Join("templates/apps", "application-web.yaml") {
+				t.Errorf("Unexpected path %s", file.Path)
+			}
+			if !strings.Contains(file.Content, tc.expected) {
+				t.Errorf("Expected content to contain %q:\n%s", tc.expected, file.Content)
+			}
+		})
+	}
+
+	_, err := PlanNew(NewOptions{
+		Type:        TypeApplication,
+		Name:        "web",
+		Application: ApplicationOptions{SyncPolicy: "sometimes"},
+	})
+	if err == nil {
+		t.Errorf("Expected error for unsupported sync policy")
+	}
+}
+
+func TestPlanNewApplicationSetGenerators(t *testing.T) {
+	testCases := []struct {
+		generator string
+		children  []string
+		expected  []string
+	}{
+		{"git-directories", nil, []string{"directories:", "- path: apps/*", `name: '{{ "{{ path.basename }}" }}'`}},
+		{"git-files", nil, []string{"files:", "- path: apps/**/config.json"}},
+		{"list", nil, []string{"- list:", `- name: "app1"`, `path: 'apps/{{ "{{ name }}" }}'`}},
+		{"clusters", nil, []string{"- clusters: {}", `server: '{{ "{{ server }}" }}'`}},
+		{"matrix", nil, []string{"- matrix:", "- git:", "- clusters: {}", `name: '{{ "{{ path.basename }}" }}-{{ "{{ name }}" }}'`}},
+		{"merge", nil, []string{"- merge:", "mergeKeys:", "- server"}},
+		{"matrix", []string{"list", "clusters"}, []string{"- list:", `- app: "app1"`, "- clusters: {}", `path: 'apps/{{ "{{ app }}" }}'`, `name: '{{ "{{ app }}" }}-{{ "{{ name }}" }}'`}},
+		{"scm-provider", nil, []string{"- scmProvider:", "organization: your-org", `repoURL: '{{ "{{ url }}" }}'`}},
+		{"pull-request", nil, []string{"- pullRequest:", "repo: your-repo", `targetRevision: '{{ "{{ head_sha }}" }}'`}},
+		{"cluster-decision-resource", nil, []string{"- clusterDecisionResource:", "configMapRef: ocm-placement-generator"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(strings.Join(append([]string{tc.generator}, tc.children...), "-"), func(t *testing.T) {
+			plan, err := PlanNew(NewOptions{
+				Type:           TypeApplicationSet,
+				Name:           "test-apps",
+				ApplicationSet: ApplicationSetOptions{Generator: tc.generator, ChildGenerators: tc.children},
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			content := plan.Files[0].Content
+			for _, want := range tc.expected {
+				if !strings.Contains(content, want) {
+					t.Errorf("Expected template to contain %q:\n%s", want, content)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanNewApplicationSetInvalidGenerator(t *testing.T) {
+	invalid := []ApplicationSetOptions{
+		{Generator: "unknown"},
+		{Generator: "matrix", ChildGenerators: []string{"list", "merge"}},
+		{Generator: "matrix", ChildGenerators: []string{"list"}},
+		// Both children produce the name parameter
+		{Generator: "matrix", ChildGenerators: []string{"list", "clusters"}, ListElements: []map[string]string{{"name": "web"}}},
+		{Generator: "matrix", ChildGenerators: []string{"scm-provider", "pull-request"}},
+		{Generator: "scm-provider", SCMProvider: "svn"},
+		// List elements without a unique name would generate Applications
+		// with the same name
+		{Generator: "list", ListElements: []map[string]string{{"env": "dev"}, {"env": "prod"}}},
+		{Generator: "list", ListElements: []map[string]string{{"name": "web"}, {"env": "prod"}}},
+		{Generator: "list", ListElements: []map[string]string{{"name": "web"}, {"name": "web"}}},
+		{Generator: "matrix", ChildGenerators: []string{"list", "git-directories"}, ListElements: []map[string]string{{"env": "dev"}, {"env": "prod"}}},
+	}
+
+	for _, opts := range invalid {
+		_, err := PlanNew(NewOptions{Type: TypeApplicationSet, Name: "test-apps", ApplicationSet: opts})
+		if err == nil {
+			t.Errorf("Expected error for %+v", opts)
+		}
+	}
+}
+
+func TestPlanNewApplicationValues(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	files := map[string]string{
+		"/repo/values.yaml":             "# Shared values\nglobal:\n  targetRevision: main # default branch\n",
+		"/repo/values/dev/values.yaml":  "global:\n  environment: dev\n",
+		"/repo/values/prod/values.yaml": "global:\n  targetRevision: v1.0.0\n",
+	}
+	for path, content := range files {
+		if err := afero.WriteFile(fsys, path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	opts := NewOptions{Type: TypeApplication, Name: "web", ValuesFile: "/repo/values.yaml", OutputPath: "/repo/templates/apps", WriteOptions: WriteOptions{Fs: fsys}}
+	if _, err := New(context.Background(), opts); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Every environment pins the revision it runs, the rest of the files is kept
+	expected := map[string]string{
+		"/repo/values.yaml":             files["/repo/values.yaml"] + "\napplications:\n  web:\n    targetRevision: main\n",
+		"/repo/values/dev/values.yaml":  files["/repo/values/dev/values.yaml"] + "\napplications:\n  web:\n    targetRevision: main\n",
+		"/repo/values/prod/values.yaml": files["/repo/values/prod/values.yaml"] + "\napplications:\n  web:\n    targetRevision: v1.0.0\n",
+	}
+	for path, content := range expected {
+		if data, _ := afero.ReadFile(fsys, path); string(data) != content {
+			t.Errorf("Expected %s to be:\n%s\ngot:\n%s", path, content, data)
+		}
+	}
+
+	// Running again only renders the template: the values are registered
+	plan, err := PlanNew(opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(plan.Files) != 1 {
+		t.Errorf("Expected no values edits on the second run, got %+v", plan.Files)
+	}
+}
+
+func TestPlanNewAppProjectValues(t *testing.T) {
+	valuesFile := filepath.Join(t.TempDir(), "values.yaml")
+	if err := os.WriteFile(valuesFile, []byte("projects:\n  existing:\n    description: x\n"), 0644); err != nil {
+		t.Fatalf("Failed to write values file: %v", err)
+	}
+
+	plan, err := PlanNew(NewOptions{Type: TypeAppProject, Name: "team-a", ValuesFile: valuesFile})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(plan.Files) != 2 {
+		t.Fatalf("Expected template and values edit, got %d files", len(plan.Files))
+	}
+
+	// The existing projects keep their place, team-a comes after them
+	values := plan.Files[1].Content
+	if !strings.HasPrefix(values, "projects:\n  existing:\n    description: x\n  team-a:\n") {
+		t.Errorf("Expected team-a to be added to the projects key:\n%s", values)
+	}
+
+	// A project that is already registered leaves the values untouched
+	plan, err = PlanNew(NewOptions{Type: TypeAppProject, Name: "existing", ValuesFile: valuesFile})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(plan.Files) != 1 || len(plan.Notes) != 1 {
+		t.Errorf("Expected no values edit and a note, got %d files and %d notes", len(plan.Files), len(plan.Notes))
+	}
+}
+
+func TestPlanNewAppProjectSchema(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	if _, err := Init(context.Background(), InitOptions{Path: "/repo", Project: "demo", WriteOptions: WriteOptions{Fs: fsys}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	original, err := afero.ReadFile(fsys, "/repo/values.schema.json")
+	if err != nil {
+		t.Fatalf("Failed to read schema: %v", err)
+	}
+
+	opts := NewOptions{Type: TypeAppProject, Name: "team-a", ValuesFile: "/repo/values.yaml", WriteOptions: WriteOptions{Fs: fsys}}
+	plan, err := PlanNew(opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(plan.Files) != 3 || plan.Files[2].Path != "/repo/values.schema.json" {
+		t.Fatalf("Expected template, values and schema edits, got %+v", plan.Files)
+	}
+
+	// The edit only adds the project, the rest of the schema keeps its layout
+	projects := `    },
+    "projects": {
+      "description": "Additional ArgoCD projects, one per AppProject template",
+      "type": "object",
+      "properties": {
+        "team-a": {
+          "$ref": "#/definitions/project"
+        }
+      },
+      "additionalProperties": false
+    }
+  },
+  "definitions"`
+	expected := strings.Replace(string(original), "    }\n  },\n  \"definitions\"", projects, 1)
+	if plan.Files[2].Content != expected {
+		t.Errorf("Expected the projects property to be appended, got:\n%s", plan.Files[2].Content)
+	}
+
+	if _, err := New(context.Background(), opts); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if plan, err = PlanNew(opts); err != nil || len(plan.Files) != 1 {
+		t.Errorf("Expected no edits for a registered project, got %d files, %v", len(plan.Files), err)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	names := ResourceTypeNames()
+	for _, want := range []string{TypeApplication, TypeApplicationSet, TypeAppProject} {
+		if !containsString(names, want) {
+			t.Errorf("Expected %s to be registered, got %v", want, names)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected registering a duplicate resource type to panic")
+		}
+	}()
+	Register(applicationType{})
+}
+
+func TestParameterApply(t *testing.T) {
+	rt, _ := LookupResourceType(TypeApplicationSet)
+
+	var opts NewOptions
+	for _, param := range rt.Parameters() {
+		var err error
+		switch param.Name {
+		case "generator":
+			err = param.Apply(&opts, []string{"list"})
+		case "list-element":
+			err = param.Apply(&opts, []string{"name=web,namespace=web"})
+		case "cluster-selector":
+			err = param.Apply(&opts, []string{"env=prod"})
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %v", param.Name, err)
+		}
+	}
+
+	appSet := opts.ApplicationSet
+	if appSet.Generator != "list" || appSet.ListElements[0]["name"] != "web" || appSet.ClusterSelector["env"] != "prod" {
+		t.Errorf("Unexpected options %+v", appSet)
+	}
+
+	for _, param := range rt.Parameters() {
+		if param.Name == "generator" {
+			if err := param.Apply(&opts, []string{"unknown"}); err == nil {
+				t.Errorf("Expected error for an unsupported choice")
+			}
+		}
+	}
+}
+
+func TestPlanChanges(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	if err := afero.WriteFile(fsys, "/repo/same.yaml", []byte("a: 1\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := afero.WriteFile(fsys, "/repo/changed.yaml", []byte("a: 1\nb: 2\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := afero.WriteFile(fsys, "/repo/removed.yaml", []byte("d: 1\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	plan := &Plan{
+		Dirs: []string{"new-dir"},
+		Files: []File{
+			{Path: "same.yaml", Content: "a: 1\n"},
+			{Path: "changed.yaml", Content: "a: 1\nb: 3\n"},
+			{Path: "created.yaml", Content: "c: 1\n"},
+		},
+		Removes: []string{"removed.yaml", "missing.yaml"},
+	}
+
+	changes, err := plan.Changes(fsys, "/repo")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []struct {
+		path   string
+		status ChangeStatus
+		diff   string
+	}{
+		{"new-dir", StatusCreate, ""},
+		{"same.yaml", StatusUnchanged, ""},
+		{"changed.yaml", StatusOverwrite, "--- a/changed.yaml\n+++ b/changed.yaml\n@@ -1,2 +1,2 @@\n a: 1\n-b: 2\n+b: 3\n"},
+		{"created.yaml", StatusCreate, "--- /dev/null\n+++ b/created.yaml\n@@ -0,0 +1 @@\n+c: 1\n"},
+		{"removed.yaml", StatusRemove, "--- a/removed.yaml\n+++ /dev/null\n@@ -1 +0,0 @@\n-d: 1\n"},
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d changes, got %+v", len(expected), changes)
+	}
+	for i, want := range expected {
+		got := changes[i]
+		if got.Path != want.path || got.Status != want.status || got.Diff != want.diff {
+			t.Errorf("Expected %s %s with diff:\n%s\ngot %s %s with diff:\n%s", want.status, want.path, want.diff, got.Status, got.Path, got.Diff)
+		}
+	}
+
+	// Writing to an in-memory layer leaves the underlying filesystem
+	// untouched. The layer cannot remove files of the underlying one.
+	removes := plan.Removes
+	plan.Removes = nil
+	overlay := afero.NewCopyOnWriteFs(afero.NewReadOnlyFs(fsys), afero.NewMemMapFs())
+	if err := plan.WriteFS(overlay, "/repo"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if exists, _ := afero.Exists(fsys, "/repo/created.yaml"); exists {
+		t.Errorf("Expected the base filesystem to be untouched")
+	}
+
+	plan.Files, plan.Removes = nil, removes
+	if err := plan.WriteFS(fsys, "/repo"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if exists, _ := afero.Exists(fsys, "/repo/removed.yaml"); exists {
+		t.Errorf("Expected removed.yaml to be removed")
+	}
+}
+
+func TestResolveConflicts(t *testing.T) {
+	newChanges := func() (*Plan, []Change) {
+		plan := &Plan{Files: []File{
+			{Path: "a.yaml", Content: "a", TemplateID: "a"},
+			{Path: "b.yaml", Content: "b", TemplateID: "b"},
+			{Path: "values.yaml", Content: "v"},
+		}}
+		return plan, []Change{
+			{Path: "a.yaml", Status: StatusOverwrite},
+			{Path: "b.yaml", Status: StatusCreate},
+			{Path: "values.yaml", Status: StatusOverwrite, Edit: true},
+		}
+	}
+
+	plan, changes := newChanges()
+	err := plan.ResolveConflicts(changes, ConflictFail, nil)
+	var conflictErr *ConflictError
+	if !errors.As(err, &conflictErr) || len(conflictErr.Paths) != 1 || conflictErr.Paths[0] != "a.yaml" {
+		t.Errorf("Expected a conflict error for a.yaml, got %v", err)
+	}
+
+	plan, changes = newChanges()
+	if err := plan.ResolveConflicts(changes, ConflictSkip, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(plan.Files) != 2 || changes[0].Status != StatusSkip {
+		t.Errorf("Expected a.yaml to be skipped, got %+v", changes)
+	}
+
+	plan, changes = newChanges()
+	asked := 0
+	err = plan.ResolveConflicts(changes, ConflictAsk, func(change Change) (bool, error) {
+		asked++
+		return true, nil
+	})
+	if err != nil || asked != 1 || len(plan.Files) != 3 {
+		t.Errorf("Expected a single prompt keeping all files, got %d prompts, %d files, %v", asked, len(plan.Files), err)
+	}
+}
+
+func TestHasScaffold(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	if exists, err := HasScaffold(fsys, "/repo"); err != nil || exists {
+		t.Errorf("Expected no scaffold in an empty directory, got %v, %v", exists, err)
+	}
+
+	plan, err := PlanInit(InitOptions{Project: "demo"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := plan.WriteFS(fsys, "/repo"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if exists, err := HasScaffold(fsys, "/repo"); err != nil || !exists {
+		t.Errorf("Expected a scaffold after init, got %v, %v", exists, err)
+	}
+}
+
+func TestPlanWriteRollsBack(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "values.yaml"), []byte("old\n"), 0644); err != nil {
+		t.Fatalf("Failed to write values file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "blocker"), []byte("not a directory\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	plan := &Plan{
+		Dirs: []string{"templates/apps"},
+		Files: []File{
+			{Path: "values.yaml", Content: "new\n"},
+			{Path: "templates/apps/app.yaml", Content: "app\n"},
+			// Fails because its parent is a regular file
+			{Path: "blocker/app.yaml", Content: "app\n"},
+		},
+	}
+	if err := plan.Write(root); err == nil {
+		t.Fatalf("Expected error for a file below a regular file")
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatalf("Failed to read directory: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if strings.Join(names, ",") != "blocker,values.yaml" {
+		t.Errorf("Expected the tree to be left as it was, got %v", names)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "values.yaml"))
+	if err != nil || string(data) != "old\n" {
+		t.Errorf("Expected values.yaml to be restored, got %q, %v", data, err)
+	}
+}
+
+func TestInitAndNew(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	write := WriteOptions{Fs: fsys}
+
+	result, err := Init(context.Background(), InitOptions{Path: "/repo", Project: "demo", WriteOptions: write})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if exists, _ := afero.Exists(fsys, "/repo/Chart.yaml"); !exists || len(result.Changes) == 0 {
+		t.Errorf("Expected the scaffold to be written, got %+v", result.Changes)
+	}
+
+	// A second init refuses unless told what to do with existing files
+	if _, err := Init(context.Background(), InitOptions{Path: "/repo", Project: "demo", WriteOptions: write}); !errors.Is(err, ErrScaffoldExists) {
+		t.Errorf("Expected ErrScaffoldExists, got %v", err)
+	}
+
+	result, err = New(context.Background(), NewOptions{
+		Type:         TypeApplication,
+		Name:         "web",
+		OutputPath:   "/repo/templates/apps",
+		WriteOptions: write,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Changes[0].Status != StatusCreate {
+		t.Errorf("Expected the application to be created, got %+v", result.Changes)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := New(ctx, NewOptions{Type: TypeApplication, Name: "api", OutputPath: "/repo", WriteOptions: write}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a canceled context to stop the write, got %v", err)
+	}
+}
+
+const exportedApps = `apiVersion: v1
+kind: List
+items:
+- apiVersion: argoproj.io/v1alpha1
+  kind: Application
+  metadata:
+    labels:
+      app.kubernetes.io/instance: root
+      team: payments
+    name: billing
+    namespace: argocd
+    resourceVersion: "123"
+    uid: 5f0c
+  spec:
+    destination:
+      namespace: billing
+      server: https://prod.example.com
+    project: demo
+    source:
+      path: charts/billing
+      repoURL: https://github.com/acme/billing.git
+      targetRevision: v2.3.1
+    syncPolicy:
+      automated:
+        prune: true
+  status:
+    sync:
+      status: Synced
+- apiVersion: argoproj.io/v1alpha1
+  kind: Application
+  metadata:
+    name: billing-eu
+    ownerReferences:
+    - kind: ApplicationSet
+      name: billing
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: argocd-cm
+`
+
+func TestImport(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	if err := afero.WriteFile(fsys, "/repo/values.yaml", []byte("global:\n  project: demo\n"), 0644); err != nil {
+		t.Fatalf("Failed to write values file: %v", err)
+	}
+	if err := afero.WriteFile(fsys, "/export/apps.yaml", []byte(exportedApps), 0644); err != nil {
+		t.Fatalf("Failed to write export: %v", err)
+	}
+
+	opts := ImportOptions{
+		Path:         "/export",
+		OutputPath:   "/repo/templates/apps",
+		ValuesFile:   "/repo/values.yaml",
+		Env:          "prod",
+		WriteOptions: WriteOptions{Fs: fsys},
+	}
+	result, err := Import(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"/repo/templates/apps/application-billing.yaml": `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: billing
+  namespace: argocd
+  labels:
+    {{- include "common.labels" . | nindent 4 }}
+    team: payments
+spec:
+  project: {{ include "common.projectName" . }}
+  source:
+    repoURL: {{ dig "billing" "repoURL" .Values.global.repoURL .Values.applications }}
+    targetRevision: {{ dig "billing" "targetRevision" .Values.global.targetRevision .Values.applications }}
+    path: charts/billing
+  destination:
+    server: {{ dig "billing" "destination" "server" .Values.destination.server .Values.applications }}
+    namespace: {{ dig "billing" "destination" "namespace" "billing" .Values.applications }}
+  syncPolicy:
+    automated:
+      prune: true
+`,
+		// values.yaml gets the values of the first environment as defaults
+		"/repo/values.yaml": `global:
+  project: demo
+
+applications:
+  billing:
+    repoURL: https://github.com/acme/billing.git
+    targetRevision: v2.3.1
+    destination:
+      server: https://prod.example.com
+      namespace: billing
+`,
+		"/repo/values/prod/values.yaml": `applications:
+  billing:
+    repoURL: https://github.com/acme/billing.git
+    targetRevision: v2.3.1
+    destination:
+      server: https://prod.example.com
+      namespace: billing
+`,
+	}
+	for path, content := range expected {
+		if data, _ := afero.ReadFile(fsys, path); string(data) != content {
+			t.Errorf("Expected %s to be:\n%s\ngot:\n%s", path, content, data)
+		}
+	}
+
+	notes := strings.Join(result.Plan.Notes, "\n")
+	for _, want := range []string{
+		"skipped Application billing-eu, it is generated by ApplicationSet billing",
+		"skipped ConfigMap argocd-cm",
+		"label app.kubernetes.io/instance=root replaced by common.labels",
+	} {
+		if !strings.Contains(notes, want) {
+			t.Errorf("Expected the notes to report %q:\n%s", want, notes)
+		}
+	}
+
+	// Importing again changes nothing
+	plan, err := PlanImport(opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	changes, err := plan.Changes(fsys, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, change := range changes {
+		if change.Status != StatusUnchanged && !change.Dir {
+			t.Errorf("Expected %s to be unchanged, got %s", change.Path, change.Status)
+		}
+	}
+
+	if _, err := PlanImport(ImportOptions{Path: "/repo/values.yaml", WriteOptions: WriteOptions{Fs: fsys}}); err == nil {
+		t.Errorf("Expected an error for a file without Applications")
+	}
+}
+
+func TestMerge3(t *testing.T) {
+	base := "a\nb\nc\nd\n"
+
+	merged, conflicts := merge3(base, "a\nB\nc\nd\n", "a\nb\nc\nd\ne\n")
+	if len(conflicts) != 0 || merged != "a\nB\nc\nd\ne\n" {
+		t.Errorf("Expected a clean merge, got %q and %v", merged, conflicts)
+	}
+
+	// Insertions next to a change are not conflicts
+	merged, conflicts = merge3(base, "a\nB\nc\nd\n", "a\nb\nx\nc\nd\n")
+	if len(conflicts) != 0 || merged != "a\nB\nx\nc\nd\n" {
+		t.Errorf("Expected a clean merge, got %q and %v", merged, conflicts)
+	}
+
+	// The same change on both sides
+	merged, conflicts = merge3(base, "a\nB\nc\nd\n", "a\nB\nc\nd\n")
+	if len(conflicts) != 0 || merged != "a\nB\nc\nd\n" {
+		t.Errorf("Expected a clean merge, got %q and %v", merged, conflicts)
+	}
+
+	_, conflicts = merge3(base, "a\nB\nc\nd\n", "a\nb2\nc\nd\n")
+	if len(conflicts) != 1 {
+		t.Fatalf("Expected a conflict, got %v", conflicts)
+	}
+	expected := "<<<<<<< yours\nB\n||||||| previous scaffold\nb\n=======\nb2\n>>>>>>> new scaffold\n"
+	if conflicts[0].Line != 2 || conflicts[0].String() != expected {
+		t.Errorf("Unexpected conflict at line %d:\n%s", conflicts[0].Line, conflicts[0])
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	if last := Migrations[len(Migrations)-1].Version; last != ScaffoldVersion {
+		t.Errorf("Expected the last migration to be version %d, got %d", ScaffoldVersion, last)
+	}
+	data := initTemplateData{Project: "demo", Date: "2024-01-02"}
+	for i, m := range Migrations {
+		if m.Version != i+2 {
+			t.Errorf("Expected migration %d to be version %d, got %d", i, i+2, m.Version)
+		}
+		for _, id := range m.Templates {
+			before, _, err := templateAt(id, m.Version-1, data)
+			if err != nil {
+				t.Errorf("Failed to render %s before version %d: %v", id, m.Version, err)
+			}
+			after, _, err := templateAt(id, m.Version, data)
+			if err != nil {
+				t.Errorf("Failed to render %s at version %d: %v", id, m.Version, err)
+			}
+			if before == after {
+				t.Errorf("Expected %s to change in version %d", id, m.Version)
+			}
+		}
+	}
+}
+
+func TestPlanUpgrade(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	plan, err := PlanInit(InitOptions{Project: "demo", Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := plan.WriteFS(fsys, "/repo"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	opts := UpgradeOptions{Path: "/repo", WriteOptions: WriteOptions{Fs: fsys}}
+
+	upgrade, err := PlanUpgrade(opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(upgrade.Migrations) != 0 || upgrade.From != ScaffoldVersion {
+		t.Errorf("Expected a new scaffold to be up to date, got %+v", upgrade)
+	}
+
+	// Downgrade to a scaffold of version 1 without a manifest, with edits
+	data := initTemplateData{Project: "demo", Date: "2024-01-02"}
+	for _, m := range Migrations {
+		for _, id := range m.Templates {
+			path := "/repo/" + strings.TrimPrefix(id, "init/")
+			content, existed, err := templateAt(id, 1, data)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !existed {
+				err = fsys.Remove(path)
+			} else {
+				err = afero.WriteFile(fsys, path, []byte(content), 0644)
+			}
+			if err != nil {
+				t.Fatalf("Failed to downgrade %s: %v", path, err)
+			}
+		}
+	}
+	if err := fsys.Remove("/repo/" + ManifestFile); err != nil {
+		t.Fatalf("Failed to remove manifest: %v", err)
+	}
+	edit := func(path, old, new string) {
+		t.Helper()
+		data, err := afero.ReadFile(fsys, path)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", path, err)
+		}
+		if err := afero.WriteFile(fsys, path, []byte(strings.Replace(string(data), old, new, 1)), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	edit("/repo/values.yaml", "targetRevision: HEAD", "targetRevision: main")
+	edit("/repo/.helmignore", "*.swp\n", "*.swp\n*.orig\n")
+
+	result, err := Upgrade(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.From != 1 || result.To != ScaffoldVersion || len(result.Conflicts) != 0 || len(result.Plan.Notes) != 1 {
+		t.Fatalf("Expected a clean upgrade from version 1 with a note, got %+v", result)
+	}
+	values, err := afero.ReadFile(fsys, "/repo/values.yaml")
+	if err != nil {
+		t.Fatalf("Failed to read values: %v", err)
+	}
+	if !strings.Contains(string(values), "targetRevision: main\n\n# Default destination cluster for applications\ndestination:\n") {
+		t.Errorf("Expected the edit and the migration in values.yaml:\n%s", values)
+	}
+	for _, path := range []string{"/repo/values.schema.json", "/repo/" + ManifestFile} {
+		if exists, _ := afero.Exists(fsys, path); !exists {
+			t.Errorf("Expected %s to be created", path)
+		}
+	}
+	if manifest, err := ReadManifest(fsys, "/repo"); err != nil || manifest.Version != ScaffoldVersion || manifest.Project != "demo" {
+		t.Errorf("Expected the manifest at version %d, got %+v, %v", ScaffoldVersion, manifest, err)
+	}
+	statuses, err := Status(fsys, "/repo")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, s := range statuses {
+		// Merges keeping hand edits stay modified
+		want := StatePristine
+		if s.Path == "values.yaml" || s.Path == ".helmignore" {
+			want = StateModified
+		}
+		if s.State != want {
+			t.Errorf("Expected %s to be %s after the upgrade, got %s", s.Path, want, s.State)
+		}
+	}
+
+	// Conflicting edits are reported and the files left as they are
+	if err := fsys.Remove("/repo/" + ManifestFile); err != nil {
+		t.Fatalf("Failed to remove manifest: %v", err)
+	}
+	edit("/repo/.helmignore", ".argo-helper/\n", ".argo-helper/scaffold.yaml\n")
+	result, err = Upgrade(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Conflicts) != 1 || result.Conflicts[0].Path != ".helmignore" || result.To != 1 {
+		t.Errorf("Expected a conflict in .helmignore, got %+v", result.Conflicts)
+	}
+	if exists, _ := afero.Exists(fsys, "/repo/"+ManifestFile); exists {
+		t.Errorf("Expected no manifest while conflicts are left")
+	}
+}
+
+func TestStatus(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	if _, err := Status(fsys, "/repo"); !errors.Is(err, ErrNoLock) {
+		t.Fatalf("Expected ErrNoLock without a lock file, got %v", err)
+	}
+
+	write := func(plan *Plan, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := plan.WriteFS(fsys, "/repo"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	states := func() map[string]FileState {
+		t.Helper()
+		statuses, err := Status(fsys, "/repo")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		result := map[string]FileState{}
+		for _, s := range statuses {
+			result[s.Path] = s.State
+		}
+		return result
+	}
+
+	write(PlanInit(InitOptions{Project: "demo"}))
+	write(PlanNew(NewOptions{Type: TypeApplication, Name: "web"}))
+	got := states()
+	for _, path := range []string{"Chart.yaml", "values.yaml", "templates/apps/application-web.yaml"} {
+		if got[path] != StatePristine {
+			t.Errorf("Expected %s to be pristine, got %q", path, got[path])
+		}
+	}
+	if _, ok := got[ManifestFile]; ok {
+		t.Errorf("Expected the scaffold manifest not to be tracked")
+	}
+
+	if err := afero.WriteFile(fsys, "/repo/values.yaml", []byte("global: {}\n"), 0644); err != nil {
+		t.Fatalf("Failed to edit values.yaml: %v", err)
+	}
+	if err := fsys.Remove("/repo/Chart.yaml"); err != nil {
+		t.Fatalf("Failed to remove Chart.yaml: %v", err)
+	}
+	// Registering a project edits values.yaml, keeping the hand edits
+	write(PlanNew(NewOptions{Type: TypeAppProject, Name: "team"}))
+	write(&Plan{Removes: []string{"templates/apps/application-web.yaml"}}, nil)
+
+	got = states()
+	if got["values.yaml"] != StateModified || got["Chart.yaml"] != StateMissing || got["templates/projects/appproject-team.yaml"] != StatePristine {
+		t.Errorf("Expected values.yaml modified, Chart.yaml missing and the project pristine, got %v", got)
+	}
+	if _, ok := got["templates/apps/application-web.yaml"]; ok {
+		t.Errorf("Expected removed files to be forgotten")
+	}
+}
+
+func TestLockOutsideScaffold(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	plan, err := PlanNew(NewOptions{Type: TypeApplication, Name: "web"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := plan.WriteFS(fsys, "/plain"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var written []string
+	if err := afero.Walk(fsys, "/plain", func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			written = append(written, path)
+		}
+		return err
+	}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(written) != 1 || written[0] != "/plain/templates/apps/application-web.yaml" {
+		t.Errorf("Expected new outside a scaffold to write only the template, got %v", written)
+	}
+
+	// Scaffolds created before the lock file start one with their next edit
+	for _, plan := range []func() (*Plan, error){
+		func() (*Plan, error) { return PlanInit(InitOptions{Project: "demo"}) },
+		func() (*Plan, error) { return PlanNew(NewOptions{Type: TypeApplication, Name: "api"}) },
+	} {
+		p, err := plan()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := p.WriteFS(fsys, "/repo"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := fsys.Remove("/repo/" + LockFile); err != nil {
+			t.Fatalf("Expected a lock file: %v", err)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package scaffold
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rebelopsio/argo-helper/templates"
+)
+
+// ScaffoldVersion is the version of the scaffold init creates. It goes up
+// with a migration whenever an init template changes.
+const ScaffoldVersion = 4
+
+// ManifestFile records the scaffold of a repository, relative to its root
+const ManifestFile = ".argo-helper/scaffold.yaml"
+
+// Manifest is the content of ManifestFile
+type Manifest struct {
+	// Version is the scaffold version the repository is at
+	Version int `yaml:"version"`
+	// Project and Created are what the init templates were rendered with
+	Project string `yaml:"project"`
+	Created string `yaml:"created"`
+}
+
+// file returns the manifest as a planned file
+func (m Manifest) file() (File, error) {
+	data, err := yaml.Marshal(m)
+	if err != nil {
+		return File{}, fmt.Errorf("failed to encode %s: %w", ManifestFile, err)
+	}
+	content := "# Scaffold of this repository, written by argo-helper init and upgrade\n" + string(data)
+	return File{Path: ManifestFile, Content: content}, nil
+}
+
+// ReadManifest reads the manifest of the repository in root, nil when it
+// has none, like repositories created before scaffold versions
+func ReadManifest(fsys afero.Fs, root string) (*Manifest, error) {
+	data, err := afero.ReadFile(fsys, resolve(root, ManifestFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", ManifestFile, err)
+	}
+
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ManifestFile, err)
+	}
+	if m.Version < 1 {
+		return nil, fmt.Errorf("%s has no scaffold version", ManifestFile)
+	}
+	return &m, nil
+}
+
+// Migration is a change of the init templates. The content of the
+// templates before the change is kept in the template history, so
+// repositories can be upgraded from any earlier version.
+type Migration struct {
+	// Version is the scaffold version the migration upgrades to
+	Version int
+	// Description is a one-line summary of the change
+	Description string
+	// Templates are the init templates changed, the ones without history
+	// are added by the migration
+	Templates []string
+}
+
+// Migrations are the changes of the scaffold since version 1, in order
+var Migrations = []Migration{
+	{
+		Version:     2,
+		Description: "Quote the AppProject fields and add the default destination to values.yaml",
+		Templates:   []string{"init/templates/projects/project.yaml", "init/values.yaml"},
+	},
+	{
+		Version:     3,
+		Description: "Add values.schema.json describing the chart values",
+		Templates:   []string{"init/values.schema.json", "init/README.md"},
+	},
+	{
+		Version:     4,
+		Description: "Keep the scaffold manifest out of packaged charts",
+		Templates:   []string{"init/.helmignore"},
+	},
+}
+
+// UpgradeOptions configures the upgrade of a repository scaffold
+type UpgradeOptions struct {
+	// Path is the repository root, defaults to the current directory
+	Path string
+
+	WriteOptions
+}
+
+// FileConflicts are the conflicts of a file left as it was
+type FileConflicts struct {
+	Path      string
+	Conflicts []MergeConflict
+}
+
+// UpgradeResult is what Upgrade did
+type UpgradeResult struct {
+	Result
+	// From is the scaffold version of the repository, To the version it is
+	// at after the upgrade. To stays From while files have conflicts.
+	From, To int
+	// Migrations are the migrations applied, in order
+	Migrations []Migration
+	// Conflicts are the files both the user and the migrations changed in
+	// the same places, they are not written
+	Conflicts []FileConflicts
+}
+
+// Upgrade applies the migrations after the scaffold version of the
+// repository, see PlanUpgrade. Merged files are written even when others
+// have conflicts; the manifest is only updated once none are left.
+func Upgrade(ctx context.Context, opts UpgradeOptions) (*UpgradeResult, error) {
+	root := opts.Path
+	if root == "" {
+		root = "."
+	}
+
+	upgrade, err := PlanUpgrade(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Merged files keep the user's changes, overwriting them is the point
+	opts.Conflicts = ConflictOverwrite
+	result, err := apply(ctx, upgrade.Plan, root, opts.WriteOptions)
+	if err != nil {
+		return nil, err
+	}
+	upgrade.Result = *result
+	return upgrade, nil
+}
+
+// PlanUpgrade plans the migrations after the scaffold version of the
+// repository. Every file changed by a migration gets a three-way merge of
+// the scaffold changes into the user's version, with the file the previous
+// scaffold created as the base. Repositories without a manifest are
+// upgraded from version 1, changes they already have merge cleanly.
+func PlanUpgrade(opts UpgradeOptions) (*UpgradeResult, error) {
+	root := opts.Path
+	if root == "" {
+		root = "."
+	}
+	fsys := opts.fs()
+
+	plan := &Plan{}
+	manifest, err := ReadManifest(fsys, root)
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		exists, err := HasScaffold(fsys, root)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("%s has no argo-helper scaffold, run argo-helper init first", root)
+		}
+		if manifest, err = guessManifest(fsys, root); err != nil {
+			return nil, err
+		}
+		plan.Notes = append(plan.Notes, fmt.Sprintf("%s is missing, upgrading from scaffold version 1", ManifestFile))
+	}
+	if manifest.Version > ScaffoldVersion {
+		return nil, fmt.Errorf("%s is at scaffold version %d, this argo-helper only knows up to version %d", root, manifest.Version, ScaffoldVersion)
+	}
+
+	upgrade := &UpgradeResult{Result: Result{Plan: plan}, From: manifest.Version, To: manifest.Version}
+	var ids []string
+	for _, m := range Migrations {
+		if m.Version <= manifest.Version {
+			continue
+		}
+		upgrade.Migrations = append(upgrade.Migrations, m)
+		for _, id := range m.Templates {
+			if !containsString(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	data := initTemplateData{Project: manifest.Project, Date: manifest.Created}
+	for _, id := range ids {
+		path := strings.TrimPrefix(id, "init/")
+		base, existed, err := templateAt(id, manifest.Version, data)
+		if err != nil {
+			return nil, err
+		}
+		target, _, err := templateAt(id, ScaffoldVersion, data)
+		if err != nil {
+			return nil, err
+		}
+
+		current, err := afero.ReadFile(fsys, resolve(root, path))
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && existed:
+			plan.Notes = append(plan.Notes, fmt.Sprintf("%s was removed, the scaffold changes to it are not applied", path))
+			continue
+		case errors.Is(err, fs.ErrNotExist):
+			plan.Files = append(plan.Files, File{Path: path, Content: target, TemplateID: id})
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		merged, conflicts := merge3(base, string(current), target)
+		if len(conflicts) > 0 {
+			upgrade.Conflicts = append(upgrade.Conflicts, FileConflicts{Path: path, Conflicts: conflicts})
+			continue
+		}
+		if merged != string(current) {
+			file := File{Path: path, Content: merged}
+			// Files keeping hand edits are edits, not renders of the template
+			if merged == target {
+				file.TemplateID = id
+			}
+			plan.Files = append(plan.Files, file)
+		}
+	}
+
+	if len(upgrade.Conflicts) == 0 {
+		manifest.Version = ScaffoldVersion
+		file, err := manifest.file()
+		if err != nil {
+			return nil, err
+		}
+		plan.Files = append(plan.Files, file)
+		upgrade.To = ScaffoldVersion
+	}
+	return upgrade, nil
+}
+
+// templateAt renders an init template as it was at a scaffold version, and
+// reports whether it existed then
+func templateAt(id string, version int, data initTemplateData) (string, bool, error) {
+	for _, m := range Migrations {
+		if m.Version <= version || !containsString(m.Templates, id) {
+			continue
+		}
+		raw, err := templates.ReadHistory(m.Version, id)
+		if errors.Is(err, templates.ErrNotFound) {
+			return "", false, nil
+		}
+		if err != nil {
+			return "", false, err
+		}
+		return renderAt(id, raw, data)
+	}
+
+	raw, _, err := templates.ReadBuiltin(id)
+	if err != nil {
+		return "", false, err
+	}
+	return renderAt(id, raw, data)
+}
+
+func renderAt(id string, raw []byte, data initTemplateData) (string, bool, error) {
+	content, err := templates.RenderRaw(id, raw, data)
+	if err != nil {
+		return "", false, err
+	}
+	return content, true, nil
+}
+
+// guessManifest returns the manifest of a repository created before
+// scaffold versions, from its values.yaml and Chart.yaml
+func guessManifest(fsys afero.Fs, root string) (*Manifest, error) {
+	m := &Manifest{Version: 1}
+	chartFile, err := readValues(fsys, resolve(root, "Chart.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	values, err := readValues(fsys, resolve(root, "values.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	if chartFile != nil {
+		m.Project = scalarAt(chartFile, []string{"name"}, "")
+		m.Created = scalarAt(chartFile, []string{"created"}, "")
+	}
+	if values != nil {
+		m.Project = scalarAt(values, []string{"global", "project"}, m.Project)
+	}
+	return m, nil
+}
@@ -0,0 +1,121 @@
+package scaffold
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rebelopsio/argo-helper/yamledit"
+)
+
+// defaultTargetRevision is the revision of applications in charts without
+// a global.targetRevision
+const defaultTargetRevision = "HEAD"
+
+// appTargetRevision is the template expression of the target revision of an
+// application: applications.<name>.targetRevision, or the global one
+func appTargetRevision(name string) string {
+	return fmt.Sprintf(`{{ dig %q "targetRevision" .Values.global.targetRevision .Values.applications }}`, name)
+}
+
+// readValues parses a values file for editing, nil when it does not exist
+func readValues(fsys afero.Fs, path string) (*yamledit.Document, error) {
+	data, err := afero.ReadFile(fsys, path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	doc, err := yamledit.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return doc, nil
+}
+
+// envValuesFiles returns the values/<env>/values.yaml files next to a
+// values file, sorted by environment
+func envValuesFiles(fsys afero.Fs, valuesFile string) ([]string, error) {
+	dir := filepath.Join(filepath.Dir(valuesFile), "values")
+	entries, err := afero.ReadDir(fsys, dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read environments: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name(), "values.yaml")
+		if exists, err := afero.Exists(fsys, path); entry.IsDir() && err == nil && exists {
+			files = append(files, path)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// planAppValues adds the values edits registering the target revision of an
+// application under applications.<name>, so it can be pinned and promoted
+// per environment. values.yaml gets the global revision and every
+// environment pins the revision it currently runs. Revisions already set
+// are left alone, and charts without a values file are not edited: the
+// templates fall back to global.targetRevision.
+func planAppValues(opts NewOptions, plan *Plan) error {
+	valuesFile := opts.valuesFile()
+	doc, err := readValues(opts.fs(), valuesFile)
+	if err != nil || doc == nil {
+		return err
+	}
+
+	path := []string{"applications", opts.Name, "targetRevision"}
+	revision := scalarAt(doc, []string{"global", "targetRevision"}, defaultTargetRevision)
+	if err := setDefault(plan, valuesFile, doc, path, revision); err != nil {
+		return err
+	}
+
+	envFiles, err := envValuesFiles(opts.fs(), valuesFile)
+	if err != nil {
+		return err
+	}
+	for _, envFile := range envFiles {
+		envDoc, err := readValues(opts.fs(), envFile)
+		if err != nil {
+			return err
+		}
+		envRevision := scalarAt(envDoc, []string{"global", "targetRevision"}, revision)
+		if err := setDefault(plan, envFile, envDoc, path, envRevision); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setDefault adds the edit setting a value to the plan unless the path is
+// set already
+func setDefault(plan *Plan, file string, doc *yamledit.Document, path []string, value string) error {
+	if doc.Lookup(path) != nil {
+		return nil
+	}
+	if _, err := doc.Set(path, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}); err != nil {
+		return fmt.Errorf("failed to edit %s: %w", file, err)
+	}
+	plan.Files = append(plan.Files, File{Path: file, Content: string(doc.Bytes())})
+	return nil
+}
+
+// scalarAt returns the scalar at a path, or def
+func scalarAt(doc *yamledit.Document, path []string, def string) string {
+	if node := doc.Lookup(path); node != nil && node.Kind == yaml.ScalarNode && node.Tag != "!!null" {
+		return node.Value
+	}
+	return def
+}
@@ -0,0 +1,171 @@
+package scaffold
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rebelopsio/argo-helper/yamledit"
+)
+
+// valuesSchemaFile is the JSON schema of the chart values, created by init
+// next to values.yaml
+const valuesSchemaFile = "values.schema.json"
+
+// projectSchemaRef is the definition of the project settings in the values
+// schema
+const projectSchemaRef = "#/definitions/project"
+
+// planProjectSchema adds the values schema edit declaring projects.<name>,
+// so values files are checked for typos in the project settings. Charts
+// without a schema next to the values file are left alone.
+func planProjectSchema(opts NewOptions, plan *Plan, valuesFile string) error {
+	schemaFile := filepath.Join(filepath.Dir(valuesFile), valuesSchemaFile)
+
+	data, err := afero.ReadFile(opts.fs(), schemaFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", schemaFile, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", schemaFile, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("failed to parse %s: expected a JSON object", schemaFile)
+	}
+	root := doc.Content[0]
+
+	if yamledit.Lookup(yamledit.Lookup(root, "definitions"), "project") == nil {
+		plan.Notes = append(plan.Notes, fmt.Sprintf("%s has no %s definition, add projects.%s to it yourself", schemaFile, projectSchemaRef, opts.Name))
+		return nil
+	}
+
+	properties := ensureMapping(root, "properties")
+	projects := yamledit.Lookup(properties, "projects")
+	if projects == nil {
+		projects = jsonObject(
+			"description", jsonString("Additional ArgoCD projects, one per AppProject template"),
+			"type", jsonString("object"),
+			"properties", jsonObject(),
+			"additionalProperties", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "false"},
+		)
+		setMappingValue(properties, "projects", projects)
+	}
+	projectProperties := ensureMapping(projects, "properties")
+	if yamledit.Lookup(projectProperties, opts.Name) != nil {
+		return nil
+	}
+	setMappingValue(projectProperties, opts.Name, jsonObject("$ref", jsonString(projectSchemaRef)))
+
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, root, ""); err != nil {
+		return fmt.Errorf("failed to update %s: %w", schemaFile, err)
+	}
+	buf.WriteString("\n")
+
+	plan.Files = append(plan.Files, File{Path: schemaFile, Content: buf.String()})
+	return nil
+}
+
+// setMappingValue appends a key to a mapping node
+func setMappingValue(node *yaml.Node, key string, value *yaml.Node) {
+	node.Content = append(node.Content, jsonString(key), value)
+}
+
+// ensureMapping returns the mapping under a key, adding an empty one when
+// the key is missing
+func ensureMapping(node *yaml.Node, key string) *yaml.Node {
+	if value := yamledit.Lookup(node, key); value != nil && value.Kind == yaml.MappingNode {
+		return value
+	}
+	value := jsonObject()
+	setMappingValue(node, key, value)
+	return value
+}
+
+func jsonString(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// jsonObject returns a mapping node of key and value pairs
+func jsonObject(pairs ...interface{}) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		setMappingValue(node, pairs[i].(string), pairs[i+1].(*yaml.Node))
+	}
+	return node
+}
+
+// writeJSON writes a node parsed from JSON back as JSON indented by two
+// spaces, keeping the order of the keys so edits produce minimal diffs
+func writeJSON(buf *bytes.Buffer, node *yaml.Node, indent string) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		if len(node.Content) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteString("{\n")
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			buf.WriteString(indent + "  ")
+			writeJSONString(buf, node.Content[i].Value)
+			buf.WriteString(": ")
+			if err := writeJSON(buf, node.Content[i+1], indent+"  "); err != nil {
+				return err
+			}
+			if i+2 < len(node.Content) {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString(indent + "}")
+	case yaml.SequenceNode:
+		if len(node.Content) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		buf.WriteString("[\n")
+		for i, item := range node.Content {
+			buf.WriteString(indent + "  ")
+			if err := writeJSON(buf, item, indent+"  "); err != nil {
+				return err
+			}
+			if i+1 < len(node.Content) {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString(indent + "]")
+	case yaml.ScalarNode:
+		switch node.ShortTag() {
+		case "!!int", "!!float", "!!bool", "!!null":
+			buf.WriteString(node.Value)
+		default:
+			writeJSONString(buf, node.Value)
+		}
+	default:
+		return fmt.Errorf("unsupported node at line %d", node.Line)
+	}
+	return nil
+}
+
+// writeJSONString writes a quoted JSON string, leaving <, > and & as they
+// are
+func writeJSONString(buf *bytes.Buffer, value string) {
+	var quoted bytes.Buffer
+	enc := json.NewEncoder(&quoted)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(value)
+	buf.WriteString(strings.TrimSuffix(quoted.String(), "\n"))
+}
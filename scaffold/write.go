@@ -0,0 +1,215 @@
+package scaffold
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+
+	"github.com/spf13/afero"
+)
+
+// stagingPrefix is the prefix of the temporary directory files are staged in
+const stagingPrefix = ".argo-helper-staging-"
+
+// WriteFS is Write against the given filesystem. Dry runs pass an in-memory
+// filesystem so they go through the exact same steps as a real run.
+//
+// All files are first staged in a temporary directory below root and then
+// moved into place, and removed files are moved to the staging directory. If
+// anything fails, every directory and file created is removed again and
+// replaced and removed files are restored, leaving the tree exactly as it
+// was.
+//
+// The LockFile of root is updated in the same write, see Status.
+func (p *Plan) WriteFS(fsys afero.Fs, root string) error {
+	files := p.Files
+	lock, ok, err := p.lockFile(fsys, root)
+	if err != nil {
+		return err
+	}
+	if ok {
+		files = append(files[:len(files):len(files)], lock)
+	}
+
+	tx := &transaction{fsys: fsys}
+
+	if err := tx.mkdirAll(resolve(root, ".")); err != nil {
+		return tx.rollback(err, "")
+	}
+
+	staging, err := afero.TempDir(fsys, resolve(root, "."), stagingPrefix)
+	if err != nil {
+		return tx.rollback(fmt.Errorf("failed to create staging directory: %w", err), "")
+	}
+
+	// Stage the content of every file before touching the tree
+	staged := make([]string, len(files))
+	for i, file := range files {
+		staged[i] = filepath.Join(staging, strconv.Itoa(i))
+		if err := afero.WriteFile(fsys, staged[i], []byte(file.Content), 0644); err != nil {
+			return tx.rollback(fmt.Errorf("failed to stage file %s: %w", file.Path, err), staging)
+		}
+	}
+
+	for _, dir := range p.Dirs {
+		if err := tx.mkdirAll(resolve(root, dir)); err != nil {
+			return tx.rollback(fmt.Errorf("failed to create directory %s: %w", dir, err), staging)
+		}
+	}
+
+	for i, file := range files {
+		path := resolve(root, file.Path)
+		// Template overrides may add files outside the planned directories
+		if err := tx.mkdirAll(filepath.Dir(path)); err != nil {
+			return tx.rollback(fmt.Errorf("failed to create directory for %s: %w", file.Path, err), staging)
+		}
+		if err := tx.place(staged[i], path, filepath.Join(staging, "backup-"+strconv.Itoa(i))); err != nil {
+			return tx.rollback(fmt.Errorf("failed to create file %s: %w", file.Path, err), staging)
+		}
+	}
+
+	for i, path := range p.Removes {
+		if err := tx.remove(resolve(root, path), filepath.Join(staging, "removed-"+strconv.Itoa(i))); err != nil {
+			return tx.rollback(fmt.Errorf("failed to remove file %s: %w", path, err), staging)
+		}
+	}
+
+	if err := fsys.RemoveAll(staging); err != nil {
+		return fmt.Errorf("failed to remove staging directory: %w", err)
+	}
+	return nil
+}
+
+// transaction records what a plan write changed so it can be rolled back
+type transaction struct {
+	fsys afero.Fs
+	// dirs are the directories created, parents first
+	dirs []string
+	// files are the files placed, with the backup of the file they replaced
+	files []placedFile
+	// removed are the files removed, with their backup
+	removed []placedFile
+}
+
+type placedFile struct {
+	path   string
+	backup string
+}
+
+// mkdirAll creates a directory and its parents, recording the ones that did
+// not exist
+func (tx *transaction) mkdirAll(dir string) error {
+	var missing []string
+	for path := dir; ; path = filepath.Dir(path) {
+		if _, err := tx.fsys.Stat(path); err == nil {
+			break
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		missing = append(missing, path)
+		if filepath.Dir(path) == path {
+			break
+		}
+	}
+
+	if err := tx.fsys.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		tx.dirs = append(tx.dirs, missing[i])
+	}
+	return nil
+}
+
+// place moves a staged file into place, backing up the file it replaces
+func (tx *transaction) place(staged, path, backup string) error {
+	placed := placedFile{path: path}
+
+	current, err := afero.ReadFile(tx.fsys, path)
+	switch {
+	case err == nil:
+		if err := afero.WriteFile(tx.fsys, backup, current, 0644); err != nil {
+			return fmt.Errorf("failed to back up existing file: %w", err)
+		}
+		placed.backup = backup
+	case !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+
+	if err := move(tx.fsys, staged, path); err != nil {
+		return err
+	}
+	tx.files = append(tx.files, placed)
+	return nil
+}
+
+// remove moves a file to its backup, files that do not exist are ignored
+func (tx *transaction) remove(path, backup string) error {
+	if exists, err := afero.Exists(tx.fsys, path); err != nil || !exists {
+		return err
+	}
+	if err := move(tx.fsys, path, backup); err != nil {
+		return err
+	}
+	tx.removed = append(tx.removed, placedFile{path: path, backup: backup})
+	return nil
+}
+
+// rollback undoes the recorded changes in reverse order and returns cause,
+// along with any error that prevented a complete rollback
+func (tx *transaction) rollback(cause error, staging string) error {
+	errs := []error{cause}
+
+	for i := len(tx.removed) - 1; i >= 0; i-- {
+		file := tx.removed[i]
+		if err := move(tx.fsys, file.backup, file.path); err != nil {
+			errs = append(errs, fmt.Errorf("failed to roll back %s: %w", file.path, err))
+		}
+	}
+
+	for i := len(tx.files) - 1; i >= 0; i-- {
+		file := tx.files[i]
+		var err error
+		if file.backup != "" {
+			err = move(tx.fsys, file.backup, file.path)
+		} else {
+			err = tx.fsys.Remove(file.path)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to roll back %s: %w", file.path, err))
+		}
+	}
+
+	if staging != "" {
+		if err := tx.fsys.RemoveAll(staging); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove staging directory: %w", err))
+		}
+	}
+
+	for i := len(tx.dirs) - 1; i >= 0; i-- {
+		if err := tx.fsys.Remove(tx.dirs[i]); err != nil {
+			errs = append(errs, fmt.Errorf("failed to roll back %s: %w", tx.dirs[i], err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// move renames a file, falling back to copying it when a rename is not
+// possible, e.g. across devices
+func move(fsys afero.Fs, from, to string) error {
+	if err := fsys.Rename(from, to); err == nil {
+		return nil
+	}
+
+	data, err := afero.ReadFile(fsys, from)
+	if err != nil {
+		return err
+	}
+	if err := afero.WriteFile(fsys, to, data, 0644); err != nil {
+		return err
+	}
+	return fsys.Remove(from)
+}
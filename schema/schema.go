@@ -0,0 +1,144 @@
+// Package schema checks Argo CD resources against the OpenAPI schemas of
+// the Argo CD CRDs, without a cluster.
+//
+// The CRDs embedded from crds/ are condensed versions of the upstream
+// argoproj.io/v1alpha1 CRDs: they keep every field of the resource specs,
+// but nested tool configuration such as source.helm or the SCM and pull
+// request generators is only checked to be an object. Upstream CRD files
+// can be dropped in as they are.
+//
+// Resources are validated as yaml.v3 nodes, so every error carries the line
+// of the offending field.
+package schema
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rebelopsio/argo-helper/yamledit"
+)
+
+//go:embed crds/*.yaml
+var crdFiles embed.FS
+
+// Schema is an OpenAPI v3 schema as used by CRDs
+type Schema struct {
+	Type                 string             `yaml:"type"`
+	Properties           map[string]*Schema `yaml:"properties"`
+	AdditionalProperties *Schema            `yaml:"additionalProperties"`
+	Items                *Schema            `yaml:"items"`
+	Required             []string           `yaml:"required"`
+	Enum                 []string           `yaml:"enum"`
+	// PreserveUnknownFields accepts fields missing from Properties
+	PreserveUnknownFields bool `yaml:"x-kubernetes-preserve-unknown-fields"`
+	// IntOrString accepts both integers and strings
+	IntOrString bool `yaml:"x-kubernetes-int-or-string"`
+}
+
+// crd is the part of a CustomResourceDefinition holding the schemas
+type crd struct {
+	Spec struct {
+		Group string `yaml:"group"`
+		Names struct {
+			Kind string `yaml:"kind"`
+		} `yaml:"names"`
+		Versions []struct {
+			Name   string `yaml:"name"`
+			Schema struct {
+				OpenAPIV3Schema *Schema `yaml:"openAPIV3Schema"`
+			} `yaml:"schema"`
+		} `yaml:"versions"`
+	} `yaml:"spec"`
+}
+
+// schemas maps apiVersion and kind to the schema of a resource
+var schemas = loadSchemas()
+
+func loadSchemas() map[string]*Schema {
+	files, err := fs.Glob(crdFiles, "crds/*.yaml")
+	if err != nil {
+		panic(err)
+	}
+
+	loaded := map[string]*Schema{}
+	for _, file := range files {
+		data, err := crdFiles.ReadFile(file)
+		if err != nil {
+			panic(err)
+		}
+
+		var def crd
+		if err := yaml.Unmarshal(data, &def); err != nil {
+			panic(fmt.Sprintf("invalid CRD %s: %v", file, err))
+		}
+		for _, version := range def.Spec.Versions {
+			apiVersion := def.Spec.Group + "/" + version.Name
+			loaded[key(apiVersion, def.Spec.Names.Kind)] = version.Schema.OpenAPIV3Schema
+		}
+	}
+	return loaded
+}
+
+func key(apiVersion, kind string) string {
+	return apiVersion + ", Kind=" + kind
+}
+
+// For returns the schema of a resource, or nil if there is none
+func For(apiVersion, kind string) *Schema {
+	return schemas[key(apiVersion, kind)]
+}
+
+// Error is a field of a resource that does not match its schema
+type Error struct {
+	// Path is the field path, e.g. spec.sources[0].repoURL
+	Path         string
+	Line, Column int
+	Message      string
+}
+
+func (e Error) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// Validate checks a resource against the schema of its apiVersion and
+// kind. Resources without a schema are not checked.
+func Validate(resource *yaml.Node) []Error {
+	if resource.Kind == yaml.DocumentNode && len(resource.Content) > 0 {
+		resource = resource.Content[0]
+	}
+
+	apiVersion, kind := yamledit.Scalar(resource, "apiVersion"), yamledit.Scalar(resource, "kind")
+	s := For(apiVersion, kind)
+	if s == nil {
+		return nil
+	}
+	return s.Validate(resource)
+}
+
+// Validate checks a node against the schema
+func (s *Schema) Validate(node *yaml.Node) []Error {
+	v := validator{}
+	v.validate(s, node, "")
+	return v.errs
+}
+
+// ValidateOverlay checks a node that is merged onto other values before
+// use, like an environment values file: fields must match the schema but
+// required fields may be set elsewhere.
+func (s *Schema) ValidateOverlay(node *yaml.Node) []Error {
+	v := validator{overlay: true}
+	v.validate(s, node, "")
+	return v.errs
+}
+
+// IsArgoCD reports whether an apiVersion belongs to Argo CD
+func IsArgoCD(apiVersion string) bool {
+	return strings.HasPrefix(apiVersion, "argoproj.io/")
+}
@@ -0,0 +1,223 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestFor(t *testing.T) {
+	for _, kind := range []string{"Application", "ApplicationSet", "AppProject"} {
+		if For("argoproj.io/v1alpha1", kind) == nil {
+			t.Errorf("Expected a schema for %s", kind)
+		}
+	}
+	if For("v1", "ConfigMap") != nil {
+		t.Errorf("Expected no schema for ConfigMap")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource string
+		expected []string
+	}{
+		{
+			name: "valid application",
+			resource: `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: web
+  labels:
+    team: a
+spec:
+  project: default
+  source:
+    repoURL: https://example.com/repo.git
+    helm:
+      valueFiles: [values.yaml]
+  destination:
+    server: https://kubernetes.default.svc
+  syncPolicy:
+    automated:
+      prune: true
+`,
+		},
+		{
+			name: "schema errors",
+			resource: `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: web
+spec:
+  project: default
+  source:
+    repoURL:
+    targetRevison: HEAD
+  destination:
+    server: https://kubernetes.default.svc
+  syncPolicy:
+    automated:
+      prune: "yes"
+      prune: true
+    syncOptions: CreateNamespace=true
+`,
+			expected: []string{
+				"9:5 spec.source.targetRevison: unknown field",
+				"8:5 spec.source.repoURL: required field is missing",
+				"14:14 spec.syncPolicy.automated.prune: expected boolean, got string",
+				"15:7 spec.syncPolicy.automated.prune: duplicate field",
+				"16:18 spec.syncPolicy.syncOptions: expected array, got string",
+			},
+		},
+		{
+			name: "applicationset",
+			resource: `apiVersion: argoproj.io/v1alpha1
+kind: ApplicationSet
+metadata:
+  name: apps
+spec:
+  generators:
+    - matrix:
+        generators:
+          - git:
+              repoURL: https://example.com/repo.git
+              directories:
+                - path: apps/*
+          - list:
+              elements:
+                - cluster: dev
+  syncPolicy:
+    applicationsSync: delete
+  template:
+    metadata:
+      name: '{{path.basename}}'
+    spec:
+      project: default
+      source:
+        repoURL: https://example.com/repo.git
+      destination:
+        namespace: '{{path.basename}}'
+`,
+			expected: []string{
+				"10:15 spec.generators[0].matrix.generators[0].git.revision: required field is missing",
+				"17:23 spec.syncPolicy.applicationsSync: unsupported value \"delete\", must be one of create-only, create-update, create-delete, sync",
+			},
+		},
+		{
+			name: "missing spec",
+			resource: `apiVersion: argoproj.io/v1alpha1
+kind: AppProject
+metadata:
+  name: team
+`,
+			expected: []string{"1:1 spec: required field is missing"},
+		},
+		{
+			name: "other resources",
+			resource: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data: [1, 2]
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.resource), &doc); err != nil {
+				t.Fatalf("Failed to parse resource: %v", err)
+			}
+
+			var errs []string
+			for _, err := range Validate(&doc) {
+				errs = append(errs, formatError(err))
+			}
+			if !reflect.DeepEqual(errs, tt.expected) {
+				t.Errorf("Expected errors %q, got %q", tt.expected, errs)
+			}
+		})
+	}
+}
+
+func formatError(err Error) string {
+	return fmt.Sprintf("%d:%d %s", err.Line, err.Column, err.Error())
+}
+
+func TestParseValuesSchema(t *testing.T) {
+	s, err := ParseValuesSchema([]byte(`{
+  "type": "object",
+  "required": ["global"],
+  "properties": {
+    "global": {
+      "type": "object",
+      "required": ["project"],
+      "properties": {
+        "project": {"type": "string"},
+        "replicas": {"type": ["integer", "null"]}
+      },
+      "additionalProperties": false
+    },
+    "team": {"$ref": "#/definitions/team"}
+  },
+  "definitions": {
+    "team": {
+      "type": "object",
+      "properties": {
+        "lead": {"$ref": "#/definitions/team"}
+      },
+      "additionalProperties": {"type": "string"}
+    }
+  }
+}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	values := `global:
+  projekt: demo
+  replicas: two
+team:
+  name: platform
+  size: 3
+  lead:
+    name: ada
+extra: true
+`
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(values), &doc); err != nil {
+		t.Fatalf("Failed to parse values: %v", err)
+	}
+
+	var errs []string
+	for _, err := range s.Validate(doc.Content[0]) {
+		errs = append(errs, formatError(err))
+	}
+	expected := []string{
+		"2:3 global.projekt: unknown field",
+		"3:13 global.replicas: expected integer, got string",
+		"2:3 global.project: required field is missing",
+		"6:9 team.size: expected string, got integer",
+	}
+	if !reflect.DeepEqual(errs, expected) {
+		t.Errorf("Expected errors %q, got %q", expected, errs)
+	}
+
+	// Overlays may leave out required fields
+	errs = nil
+	for _, err := range s.ValidateOverlay(doc.Content[0]) {
+		errs = append(errs, formatError(err))
+	}
+	if len(errs) != 3 {
+		t.Errorf("Expected the required field to be skipped, got %q", errs)
+	}
+
+	if _, err := ParseValuesSchema([]byte(`{"properties": {"a": {"$ref": "#/definitions/missing"}}}`)); err == nil {
+		t.Errorf("Expected error for an unresolved reference")
+	}
+}
@@ -0,0 +1,158 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// validator collects the errors of one validation
+type validator struct {
+	errs []Error
+	// overlay skips the required fields check
+	overlay bool
+}
+
+func (v *validator) errorf(node *yaml.Node, path, format string, args ...interface{}) {
+	v.errs = append(v.errs, Error{
+		Path:    path,
+		Line:    node.Line,
+		Column:  node.Column,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) validate(s *Schema, node *yaml.Node, path string) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	// Like the API server, null fields are dropped rather than rejected,
+	// the parent reports them if they are required
+	if isNull(node) {
+		return
+	}
+
+	if !s.accepts(node) {
+		v.errorf(node, path, "expected %s, got %s", s.typeName(), nodeType(node))
+		return
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		v.validateObject(s, node, path)
+	case yaml.SequenceNode:
+		if s.Items == nil {
+			return
+		}
+		for i, item := range node.Content {
+			v.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case yaml.ScalarNode:
+		if len(s.Enum) > 0 && !contains(s.Enum, node.Value) {
+			v.errorf(node, path, "unsupported value %q, must be one of %s", node.Value, strings.Join(s.Enum, ", "))
+		}
+	}
+}
+
+func (v *validator) validateObject(s *Schema, node *yaml.Node, path string) {
+	seen := map[string]bool{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		fieldPath := join(path, key.Value)
+
+		if seen[key.Value] {
+			v.errorf(key, fieldPath, "duplicate field")
+			continue
+		}
+		if !isNull(value) {
+			seen[key.Value] = true
+		}
+
+		switch {
+		case s.Properties[key.Value] != nil:
+			v.validate(s.Properties[key.Value], value, fieldPath)
+		case s.AdditionalProperties != nil:
+			v.validate(s.AdditionalProperties, value, fieldPath)
+		case s.Properties != nil && !s.PreserveUnknownFields:
+			v.errorf(key, fieldPath, "unknown field")
+		}
+	}
+
+	if v.overlay {
+		return
+	}
+	for _, name := range s.Required {
+		if !seen[name] {
+			v.errorf(node, join(path, name), "required field is missing")
+		}
+	}
+}
+
+// accepts reports whether the node has the type of the schema
+func (s *Schema) accepts(node *yaml.Node) bool {
+	actual := nodeType(node)
+	if s.IntOrString {
+		return actual == "integer" || actual == "string"
+	}
+
+	switch s.Type {
+	case "":
+		return true
+	case "number":
+		return actual == "integer" || actual == "number"
+	default:
+		return actual == s.Type
+	}
+}
+
+func (s *Schema) typeName() string {
+	if s.IntOrString {
+		return "integer or string"
+	}
+	return s.Type
+}
+
+// nodeType returns the OpenAPI type of a node
+func nodeType(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	}
+
+	switch node.ShortTag() {
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	case "!!bool":
+		return "boolean"
+	case "!!null":
+		return "null"
+	default:
+		return "string"
+	}
+}
+
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null"
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// jsonSchema is the subset of a JSON Schema (draft-07) that values schemas
+// are converted from. Keywords outside of it, like pattern or oneOf, are
+// ignored: Helm checks them when installing the chart.
+type jsonSchema struct {
+	Ref                  string                 `yaml:"$ref"`
+	Type                 yaml.Node              `yaml:"type"`
+	Properties           map[string]*jsonSchema `yaml:"properties"`
+	AdditionalProperties yaml.Node              `yaml:"additionalProperties"`
+	Items                *jsonSchema            `yaml:"items"`
+	Required             []string               `yaml:"required"`
+	Enum                 []yaml.Node            `yaml:"enum"`
+	Definitions          map[string]*jsonSchema `yaml:"definitions"`
+	Defs                 map[string]*jsonSchema `yaml:"$defs"`
+}
+
+// ParseValuesSchema parses a Helm values.schema.json. Objects accept fields
+// missing from their properties unless additionalProperties is false, as
+// in JSON Schema. Only local references to definitions and $defs are
+// supported.
+func ParseValuesSchema(data []byte) (*Schema, error) {
+	var root jsonSchema
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+
+	c := converter{root: &root, refs: map[string]*Schema{}}
+	return c.convert(&root)
+}
+
+// converter converts a JSON Schema, sharing the schemas of references
+type converter struct {
+	root *jsonSchema
+	refs map[string]*Schema
+}
+
+func (c *converter) convert(js *jsonSchema) (*Schema, error) {
+	if js == nil {
+		return nil, nil
+	}
+	if js.Ref != "" {
+		return c.resolve(js.Ref)
+	}
+
+	s := &Schema{Required: js.Required}
+	var err error
+	if s.Type, err = schemaType(&js.Type); err != nil {
+		return nil, err
+	}
+	for _, value := range js.Enum {
+		s.Enum = append(s.Enum, value.Value)
+	}
+	if s.Items, err = c.convert(js.Items); err != nil {
+		return nil, err
+	}
+
+	if js.Properties != nil {
+		s.Properties = make(map[string]*Schema, len(js.Properties))
+		for name, property := range js.Properties {
+			if s.Properties[name], err = c.convert(property); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	additional := &js.AdditionalProperties
+	switch {
+	case additional.Kind == 0 || (additional.Kind == yaml.ScalarNode && additional.Value == "true"):
+		s.PreserveUnknownFields = true
+	case additional.Kind == yaml.ScalarNode && additional.Value == "false":
+		// Validate only rejects unknown fields of objects with properties
+		if s.Properties == nil {
+			s.Properties = map[string]*Schema{}
+		}
+	default:
+		var nested jsonSchema
+		if err := additional.Decode(&nested); err != nil {
+			return nil, fmt.Errorf("invalid additionalProperties: %w", err)
+		}
+		if s.AdditionalProperties, err = c.convert(&nested); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// resolve returns the schema a reference like #/definitions/project points
+// to
+func (c *converter) resolve(ref string) (*Schema, error) {
+	if s, ok := c.refs[ref]; ok {
+		return s, nil
+	}
+
+	var definitions map[string]*jsonSchema
+	var name string
+	switch {
+	case strings.HasPrefix(ref, "#/definitions/"):
+		definitions, name = c.root.Definitions, strings.TrimPrefix(ref, "#/definitions/")
+	case strings.HasPrefix(ref, "#/$defs/"):
+		definitions, name = c.root.Defs, strings.TrimPrefix(ref, "#/$defs/")
+	default:
+		return nil, fmt.Errorf("unsupported reference %s", ref)
+	}
+	definition, ok := definitions[name]
+	if !ok {
+		return nil, fmt.Errorf("unresolved reference %s", ref)
+	}
+
+	// Register the schema before converting it so recursive definitions
+	// refer to it
+	s := &Schema{}
+	c.refs[ref] = s
+	converted, err := c.convert(definition)
+	if err != nil {
+		return nil, err
+	}
+	*s = *converted
+	return s, nil
+}
+
+// schemaType returns the type of a JSON schema. A type list is only kept
+// when it is a single type, optionally with null, as Validate treats nulls as
+// absent.
+func schemaType(node *yaml.Node) (string, error) {
+	switch node.Kind {
+	case 0:
+		return "", nil
+	case yaml.ScalarNode:
+		return node.Value, nil
+	case yaml.SequenceNode:
+		var types []string
+		for _, item := range node.Content {
+			if item.Value != "null" {
+				types = append(types, item.Value)
+			}
+		}
+		if len(types) == 1 {
+			return types[0], nil
+		}
+		return "", nil
+	default:
+		return "", fmt.Errorf("invalid type at line %d", node.Line)
+	}
+}
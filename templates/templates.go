@@ -0,0 +1,250 @@
+// Package templates provides the scaffold templates used to generate
+// repository files. The built-in templates are embedded in the binary and
+// can be overridden per file from a templates directory.
+//
+// Scaffold templates use [[ ]] as delimiters so the Helm {{ }} syntax they
+// produce can be written as-is.
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+//go:embed all:files
+var builtin embed.FS
+
+// history holds the built-in templates changed by scaffold migrations, as
+// history/<version>/<id>.tmpl with their content before that version
+//
+//go:embed all:history
+var history embed.FS
+
+const (
+	// Root of the built-in templates within the embedded filesystem
+	builtinRoot = "files"
+
+	// Root of the previous versions of the built-in templates
+	historyRoot = "history"
+
+	// Extension of template files, both built-in and overrides
+	templateExt = ".tmpl"
+)
+
+// Source identifies where a template was loaded from
+type Source string
+
+const (
+	// SourceBuiltin is a template embedded in the binary
+	SourceBuiltin Source = "built-in"
+	// SourceOverride is a template from the templates directory
+	SourceOverride Source = "override"
+)
+
+// ErrNotFound is returned when a template id is unknown
+var ErrNotFound = errors.New("template not found")
+
+// Template describes a single scaffold template
+type Template struct {
+	// ID is the slash-separated template id, e.g. "init/values.yaml"
+	ID string
+	// Source tells whether the built-in template is overridden
+	Source Source
+	// Path is the override file path, empty for built-in templates
+	Path string
+}
+
+// Set is a set of scaffold templates with optional overrides
+type Set struct {
+	overrideDir string
+}
+
+// New creates a template set. Templates in overrideDir take precedence over
+// the built-in ones; an empty overrideDir uses the built-in templates only.
+func New(overrideDir string) *Set {
+	return &Set{overrideDir: overrideDir}
+}
+
+// OverrideDir returns the directory overrides are loaded from
+func (s *Set) OverrideDir() string {
+	return s.overrideDir
+}
+
+// List returns all templates, built-in and from the override directory,
+// sorted by id
+func (s *Set) List() ([]Template, error) {
+	byID := map[string]Template{}
+
+	err := fs.WalkDir(builtin, builtinRoot, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, templateExt) {
+			return err
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(p, builtinRoot+"/"), templateExt)
+		byID[id] = Template{ID: id, Source: SourceBuiltin}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list built-in templates: %w", err)
+	}
+
+	if s.overrideDir != "" {
+		err := filepath.WalkDir(s.overrideDir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(p, templateExt) {
+				return err
+			}
+			rel, err := filepath.Rel(s.overrideDir, p)
+			if err != nil {
+				return err
+			}
+			id := strings.TrimSuffix(filepath.ToSlash(rel), templateExt)
+			byID[id] = Template{ID: id, Source: SourceOverride, Path: p}
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to list templates in %s: %w", s.overrideDir, err)
+		}
+	}
+
+	list := make([]Template, 0, len(byID))
+	for _, t := range byID {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	return list, nil
+}
+
+// ListPrefix returns the templates whose id starts with prefix + "/"
+func (s *Set) ListPrefix(prefix string) ([]Template, error) {
+	all, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Template
+	for _, t := range all {
+		if strings.HasPrefix(t.ID, prefix+"/") {
+			list = append(list, t)
+		}
+	}
+	return list, nil
+}
+
+// Read returns the raw content of a template, preferring the override
+func (s *Set) Read(id string) ([]byte, Source, error) {
+	if s.overrideDir != "" {
+		data, err := os.ReadFile(filepath.Join(s.overrideDir, filepath.FromSlash(id)+templateExt))
+		if err == nil {
+			return data, SourceOverride, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, "", fmt.Errorf("failed to read template %s: %w", id, err)
+		}
+	}
+
+	return ReadBuiltin(id)
+}
+
+// ReadBuiltin returns the raw content of a built-in template
+func ReadBuiltin(id string) ([]byte, Source, error) {
+	data, err := builtin.ReadFile(path.Join(builtinRoot, id+templateExt))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return data, SourceBuiltin, nil
+}
+
+// ReadHistory returns the raw content of a built-in template before the
+// given scaffold version, ErrNotFound when the template did not exist then
+// or did not change in that version
+func ReadHistory(version int, id string) ([]byte, error) {
+	data, err := history.ReadFile(path.Join(historyRoot, strconv.Itoa(version), id+templateExt))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s before version %d", ErrNotFound, id, version)
+	}
+	return data, nil
+}
+
+// Render executes a template with the given data
+func (s *Set) Render(id string, data interface{}) (string, error) {
+	raw, _, err := s.Read(id)
+	if err != nil {
+		return "", err
+	}
+	return RenderRaw(id, raw, data)
+}
+
+// RenderRaw executes the raw content of a template with the given data,
+// like a previous version from ReadHistory
+func RenderRaw(id string, raw []byte, data interface{}) (string, error) {
+	tmpl, err := template.New(id).
+		Delims("[[", "]]").
+		Funcs(funcMap).
+		Option("missingkey=error").
+		Parse(string(raw))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template %s: %w", id, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %w", id, err)
+	}
+
+	return buf.String(), nil
+}
+
+// Eject copies built-in templates into dir so they can be customized.
+// All built-in templates are ejected when no ids are given. Existing files
+// are only replaced when force is set. The written paths are returned.
+func Eject(dir string, ids []string, force bool) ([]string, error) {
+	if len(ids) == 0 {
+		all, err := New("").List()
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range all {
+			ids = append(ids, t.ID)
+		}
+	}
+
+	var written []string
+	for _, id := range ids {
+		data, _, err := ReadBuiltin(id)
+		if err != nil {
+			return written, err
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(id)+templateExt)
+		if _, err := os.Stat(target); err == nil && !force {
+			return written, fmt.Errorf("template %s already exists at %s (use --force to overwrite)", id, target)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return written, fmt.Errorf("failed to create directory for %s: %w", id, err)
+		}
+		if err := os.WriteFile(target, data, 0644); err != nil {
+			return written, fmt.Errorf("failed to write template %s: %w", id, err)
+		}
+		written = append(written, target)
+	}
+
+	return written, nil
+}
+
+var funcMap = template.FuncMap{
+	// quote renders a double-quoted YAML scalar
+	"quote": func(s string) string {
+		return fmt.Sprintf("%q", s)
+	},
+}
@@ -0,0 +1,116 @@
+package templates
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderBuiltin(t *testing.T) {
+	content, err := New("").Render("init/Chart.yaml", struct {
+		Project string
+		Date    string
+	}{Project: "demo", Date: "2024-01-01"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !strings.Contains(content, "name: demo\n") {
+		t.Errorf("Expected rendered project name, got:\n%s", content)
+	}
+}
+
+func TestRenderKeepsHelmSyntax(t *testing.T) {
+	content, err := New("").Render("init/templates/_helpers.tpl", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !strings.Contains(content, `{{- define "common.labels" -}}`) {
+		t.Errorf("Expected Helm expressions to be left untouched, got:\n%s", content)
+	}
+}
+
+func TestOverrideTakesPrecedence(t *testing.T) {
+	dir := t.TempDir()
+
+	override := filepath.Join(dir, "init", "Chart.yaml.tmpl")
+	if err := os.MkdirAll(filepath.Dir(override), 0755); err != nil {
+		t.Fatalf("Failed to create override dir: %v", err)
+	}
+	if err := os.WriteFile(override, []byte("name: custom-[[ .Project ]]\n"), 0644); err != nil {
+		t.Fatalf("Failed to write override: %v", err)
+	}
+
+	set := New(dir)
+	content, err := set.Render("init/Chart.yaml", struct{ Project string }{Project: "demo"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if content != "name: custom-demo\n" {
+		t.Errorf("Expected override content, got %q", content)
+	}
+
+	list, err := set.ListPrefix("init")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, tmpl := range list {
+		if tmpl.ID == "init/Chart.yaml" && tmpl.Source != SourceOverride {
+			t.Errorf("Expected init/Chart.yaml to be listed as override, got %s", tmpl.Source)
+		}
+	}
+}
+
+func TestEject(t *testing.T) {
+	dir := t.TempDir()
+
+	written, err := Eject(dir, []string{"new/application.yaml"}, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(written) != 1 {
+		t.Fatalf("Expected 1 ejected template, got %d", len(written))
+	}
+
+	ejected, err := os.ReadFile(written[0])
+	if err != nil {
+		t.Fatalf("Failed to read ejected template: %v", err)
+	}
+	builtin, _, _ := ReadBuiltin("new/application.yaml")
+	if string(ejected) != string(builtin) {
+		t.Errorf("Ejected template differs from the built-in one")
+	}
+
+	// Ejecting again must not overwrite customizations without force
+	if _, err := Eject(dir, []string{"new/application.yaml"}, false); err == nil {
+		t.Errorf("Expected error when ejecting over an existing template")
+	}
+	if _, err := Eject(dir, []string{"new/application.yaml"}, true); err != nil {
+		t.Errorf("Unexpected error with force: %v", err)
+	}
+
+	if _, err := Eject(dir, []string{"does/not-exist"}, false); err == nil {
+		t.Errorf("Expected error for unknown template")
+	}
+}
+
+func TestReadHistory(t *testing.T) {
+	before, err := ReadHistory(2, "init/values.yaml")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	current, _, err := ReadBuiltin("init/values.yaml")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(before) == string(current) || strings.Contains(string(before), "destination:") {
+		t.Errorf("Expected values.yaml without the default destination, got:\n%s", before)
+	}
+
+	if _, err := ReadHistory(3, "init/values.schema.json"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a template added in version 3, got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/afero"
+
+	"github.com/rebelopsio/argo-helper/scaffold"
+)
+
+// maxDiffLines limits the diff shown for a conflicting file
+const maxDiffLines = 15
+
+// conflictModel asks for every existing file a scaffold would overwrite
+// whether to overwrite or keep it, then runs the scaffold
+type conflictModel struct {
+	run       scaffoldFunc
+	conflicts []scaffold.Change
+	index     int
+	overwrite map[string]bool
+	err       error
+}
+
+// scaffoldFunc runs scaffold.Init or scaffold.New with the given write
+// options
+type scaffoldFunc func(opts scaffold.WriteOptions) (*scaffold.Result, error)
+
+// runScaffold runs a scaffold, refusing existing scaffolds like the CLI. If
+// it would overwrite existing files, it returns a model asking what to do
+// with them instead.
+func runScaffold(run scaffoldFunc) (tea.Model, error) {
+	_, err := run(scaffold.WriteOptions{})
+	var conflictErr *scaffold.ConflictError
+	if !errors.As(err, &conflictErr) {
+		if err != nil {
+			return nil, err
+		}
+		return NewModel(), nil
+	}
+
+	// Run it again in memory for the diffs of the conflicting files
+	preview, err := run(scaffold.WriteOptions{
+		Fs:        afero.NewCopyOnWriteFs(afero.NewReadOnlyFs(afero.NewOsFs()), afero.NewMemMapFs()),
+		Conflicts: scaffold.ConflictOverwrite,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return conflictModel{
+		run:       run,
+		conflicts: scaffold.Conflicts(preview.Changes),
+		overwrite: map[string]bool{},
+	}, nil
+}
+
+func (m conflictModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m conflictModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	switch key.String() {
+	case "ctrl+c", "esc":
+		return NewModel(), nil
+	}
+	if m.err != nil {
+		return m, nil
+	}
+
+	switch key.String() {
+	case "y":
+		m.decide(true, false)
+	case "n":
+		m.decide(false, false)
+	case "a":
+		m.decide(true, true)
+	case "s":
+		m.decide(false, true)
+	default:
+		return m, nil
+	}
+
+	if m.index < len(m.conflicts) {
+		return m, nil
+	}
+
+	// All conflicts are decided, run the scaffold
+	_, err := m.run(scaffold.WriteOptions{
+		Conflicts: scaffold.ConflictAsk,
+		Prompt: func(change scaffold.Change) (bool, error) {
+			return m.overwrite[change.Path], nil
+		},
+	})
+	if err != nil {
+		m.err = err
+		return m, nil
+	}
+
+	return NewModel(), nil
+}
+
+// decide records the decision for the current file, or for all remaining
+// files
+func (m *conflictModel) decide(overwrite, remaining bool) {
+	for ; m.index < len(m.conflicts); m.index++ {
+		m.overwrite[m.conflicts[m.index].Path] = overwrite
+		if !remaining {
+			m.index++
+			return
+		}
+	}
+}
+
+func (m conflictModel) View() string {
+	title := titleStyle.Render("Existing Files")
+
+	if m.err != nil {
+		errorText := lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#fb4934")).
+			Render(fmt.Sprintf("Error: %v", m.err))
+		return appStyle.Render(fmt.Sprintf("%s\n\n%s\n\nEsc: Back to menu", title, errorText))
+	}
+
+	conflict := m.conflicts[m.index]
+
+	diff := strings.Split(strings.TrimSuffix(conflict.Diff, "\n"), "\n")
+	if len(diff) > maxDiffLines {
+		diff = append(diff[:maxDiffLines], fmt.Sprintf("... %d more lines", len(diff)-maxDiffLines))
+	}
+
+	question := fmt.Sprintf("File %d of %d already exists:\n%s", m.index+1, len(m.conflicts),
+		focusedStyle.Render(conflict.Path))
+
+	help := "\ny: Overwrite • n: Keep • a: Overwrite all • s: Keep all • Esc: Cancel"
+
+	return appStyle.Render(
+		fmt.Sprintf(
+			"%s\n\n%s\n\n%s\n%s",
+			title,
+			question,
+			blurredStyle.Render(strings.Join(diff, "\n")),
+			help,
+		),
+	)
+}
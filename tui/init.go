@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -8,6 +9,9 @@ import (
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+
+	"github.com/rebelopsio/argo-helper/config"
+	"github.com/rebelopsio/argo-helper/scaffold"
 )
 
 var (
@@ -140,24 +144,26 @@ func (m initModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 			}
 
-			// We'll implement this properly later
-			_ = path
-
-			// Instead of using the actual commands directly,
-			// for now just simulate success
-			// TODO: Properly integrate with cmd package
-
-			// Simulate success
-			fmt.Printf("Creating repository structure at %s\n", path)
-			err := fmt.Errorf("") // No error
+			// Generate the repository with the same engine as the CLI
+			opts := scaffold.InitOptions{
+				Path:      path,
+				Project:   m.projectInput.Value(),
+				Examples:  m.withExamples,
+				Templates: config.TemplateSet(),
+			}
+			next, err := runScaffold(func(write scaffold.WriteOptions) (*scaffold.Result, error) {
+				opts.WriteOptions = write
+				return scaffold.Init(context.Background(), opts)
+			})
 			if err != nil {
 				m.err = err
 				m.submitted = false
 				return m, nil
 			}
 
-			// Return to the main menu after successful submission
-			return NewModel(), nil
+			// Return to the main menu after successful submission, or ask
+			// about existing files first
+			return next, nil
 
 		case "space":
 			if m.focusIndex == 2 {
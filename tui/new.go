@@ -1,54 +1,123 @@
 package tui
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	cmdPkg "github.com/rebelopsio/argo-helper/cmd"
+
+	"github.com/rebelopsio/argo-helper/config"
+	"github.com/rebelopsio/argo-helper/scaffold"
 )
 
+// resourceTypeItem is a registered resource type in the type list
+type resourceTypeItem struct {
+	resourceType scaffold.ResourceType
+}
+
+func (i resourceTypeItem) Title() string       { return i.resourceType.Name() }
+func (i resourceTypeItem) Description() string { return i.resourceType.Description() }
+func (i resourceTypeItem) FilterValue() string { return i.resourceType.Name() }
+
 type newModel struct {
-	resourceTypeInput textinput.Model
+	// typeList selects the resource type, the form is shown once it is chosen
+	typeList     list.Model
+	resourceType scaffold.ResourceType
+
 	resourceNameInput textinput.Model
 	outputPathInput   textinput.Model
+	params            []scaffold.Parameter
+	paramInputs       []textinput.Model
 	focusIndex        int
 	submitted         bool
 	err               error
 }
 
 func initialNewModel() newModel {
-	// Resource Type Input (defaults to applicationset)
-	resourceTypeInput := textinput.New()
-	resourceTypeInput.Placeholder = "applicationset"
-	resourceTypeInput.Focus()
-	resourceTypeInput.CharLimit = 30
-	resourceTypeInput.Width = 40
-	resourceTypeInput.SetValue("applicationset")
+	var items []list.Item
+	for _, rt := range scaffold.ResourceTypes() {
+		items = append(items, resourceTypeItem{resourceType: rt})
+	}
+
+	typeList := list.New(items, list.NewDefaultDelegate(), 56, 14)
+	typeList.Title = "Choose a resource type:"
+	typeList.SetShowStatusBar(false)
+	typeList.SetFilteringEnabled(false)
+	typeList.Styles.Title = titleStyle
+
+	return newModel{typeList: typeList}
+}
+
+// selectResourceType builds the form for the chosen resource type
+func (m newModel) selectResourceType(rt scaffold.ResourceType) newModel {
+	m.resourceType = rt
 
 	// Resource Name Input
-	resourceNameInput := textinput.New()
-	resourceNameInput.Placeholder = "Enter resource name"
-	resourceNameInput.CharLimit = 30
-	resourceNameInput.Width = 40
-
-	// Output Path Input (defaults to templates/apps)
-	outputPathInput := textinput.New()
-	outputPathInput.Placeholder = "templates/apps"
-	outputPathInput.CharLimit = 100
-	outputPathInput.Width = 40
-
-	return newModel{
-		resourceTypeInput: resourceTypeInput,
-		resourceNameInput: resourceNameInput,
-		outputPathInput:   outputPathInput,
-		focusIndex:        0,
-		submitted:         false,
-		err:               nil,
+	m.resourceNameInput = textinput.New()
+	m.resourceNameInput.Placeholder = "Enter resource name"
+	m.resourceNameInput.Focus()
+	m.resourceNameInput.CharLimit = 30
+	m.resourceNameInput.Width = 40
+
+	// Output Path Input (defaults to the resource type directory)
+	m.outputPathInput = textinput.New()
+	m.outputPathInput.Placeholder = rt.OutputPath()
+	m.outputPathInput.CharLimit = 100
+	m.outputPathInput.Width = 40
+
+	// One input per resource type parameter
+	m.params = rt.Parameters()
+	m.paramInputs = make([]textinput.Model, len(m.params))
+	for i, param := range m.params {
+		input := textinput.New()
+		input.Placeholder = param.Default
+		input.CharLimit = 200
+		input.Width = 40
+		m.paramInputs[i] = input
+	}
+
+	m.focusIndex = 0
+	return m
+}
+
+// inputs returns pointers to all form inputs in focus order
+func (m *newModel) inputs() []*textinput.Model {
+	inputs := []*textinput.Model{&m.resourceNameInput, &m.outputPathInput}
+	for i := range m.paramInputs {
+		inputs = append(inputs, &m.paramInputs[i])
 	}
+	return inputs
+}
+
+// paramEntries splits a parameter input into the entries Parameter.Apply
+// expects. Repeated values may contain commas and are separated by semicolons.
+func paramEntries(kind scaffold.ParameterKind, value string) []string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+
+	separator := ","
+	switch kind {
+	case scaffold.StringParameter:
+		return []string{value}
+	case scaffold.RepeatedParameter:
+		separator = ";"
+	}
+
+	var entries []string
+	for _, entry := range strings.Split(value, separator) {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
 }
 
 func (m newModel) Init() tea.Cmd {
@@ -58,72 +127,68 @@ func (m newModel) Init() tea.Cmd {
 func (m newModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 
+	// Choose the resource type first
+	if m.resourceType == nil {
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "ctrl+c", "esc":
+				return NewModel(), nil
+			case "enter":
+				if item, ok := m.typeList.SelectedItem().(resourceTypeItem); ok {
+					return m.selectResourceType(item.resourceType), textinput.Blink
+				}
+				return m, nil
+			}
+		}
+
+		m.typeList, cmd = m.typeList.Update(msg)
+		return m, cmd
+	}
+
+	inputs := m.inputs()
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "ctrl+c", "esc":
 			return NewModel(), nil
 		case "tab", "shift+tab", "up", "down":
-			// Handle input focus
+			// Handle input focus, cycling through all inputs
 			if msg.String() == "up" || msg.String() == "shift+tab" {
 				m.focusIndex--
 				if m.focusIndex < 0 {
-					m.focusIndex = 2 // Cycle back to output path input
+					m.focusIndex = len(inputs) - 1
 				}
 			} else {
 				m.focusIndex++
-				if m.focusIndex > 2 {
-					m.focusIndex = 0 // Cycle back to resource type input
+				if m.focusIndex >= len(inputs) {
+					m.focusIndex = 0
 				}
 			}
 
-			// Handle focus changes
-			switch m.focusIndex {
-			case 0:
-				m.resourceTypeInput.Focus()
-				m.resourceNameInput.Blur()
-				m.outputPathInput.Blur()
-			case 1:
-				m.resourceTypeInput.Blur()
-				m.resourceNameInput.Focus()
-				m.outputPathInput.Blur()
-			case 2:
-				m.resourceTypeInput.Blur()
-				m.resourceNameInput.Blur()
-				m.outputPathInput.Focus()
+			for i, input := range inputs {
+				if i == m.focusIndex {
+					input.Focus()
+				} else {
+					input.Blur()
+				}
 			}
 
 			return m, cmd
 
 		case "enter":
 			// Validate and submit if all required fields are provided
-			resourceType := m.resourceTypeInput.Value()
-			if resourceType == "" {
-				resourceType = "applicationset" // Default
-			}
-
-			if resourceType != "applicationset" {
-				m.err = fmt.Errorf("unsupported resource type: %s (only 'applicationset' is currently supported)", resourceType)
-				return m, nil
-			}
-
 			if m.resourceNameInput.Value() == "" {
 				m.err = fmt.Errorf("resource name is required")
 				return m, nil
 			}
 
-			// Form is complete, run 'new' command
+			// Form is complete, create the resource
 			m.submitted = true
 
-			// Get the values
-			resourceName := m.resourceNameInput.Value()
+			// Resolve the output path against the current directory
 			outputPath := m.outputPathInput.Value()
-			if outputPath == "" {
-				outputPath = "templates/apps" // Default
-			}
-
-			// Make sure output path exists
-			if !filepath.IsAbs(outputPath) {
+			if outputPath != "" && !filepath.IsAbs(outputPath) {
 				cwd, err := os.Getwd()
 				if err != nil {
 					m.err = fmt.Errorf("failed to get current directory: %w", err)
@@ -132,131 +197,68 @@ func (m newModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				outputPath = filepath.Join(cwd, outputPath)
 			}
 
-			// Call the CLI handler function
-			// We need to use actual command, create a minimal one
-			cmdPkg.SetNewFlags(nil, resourceType, resourceName, outputPath)
-
-			// Execute without an actual cobra command
-			err := generateNewResource(resourceType, resourceName, outputPath)
+			opts := scaffold.NewOptions{
+				Type:       m.resourceType.Name(),
+				Name:       m.resourceNameInput.Value(),
+				OutputPath: outputPath,
+				Templates:  config.TemplateSet(),
+			}
 
+			// Generate the resource with the same engine as the CLI
+			err := m.applyParams(&opts)
+			var next tea.Model
+			if err == nil {
+				next, err = runScaffold(func(write scaffold.WriteOptions) (*scaffold.Result, error) {
+					opts.WriteOptions = write
+					return scaffold.New(context.Background(), opts)
+				})
+			}
 			if err != nil {
 				m.err = err
 				m.submitted = false
 				return m, nil
 			}
 
-			// Return to the main menu after successful submission
-			return NewModel(), nil
+			// Return to the main menu after successful submission, or ask
+			// about existing files first
+			return next, nil
 		}
 	}
 
 	// Handle text input updates
-	switch m.focusIndex {
-	case 0:
-		m.resourceTypeInput, cmd = m.resourceTypeInput.Update(msg)
-		return m, cmd
-	case 1:
-		m.resourceNameInput, cmd = m.resourceNameInput.Update(msg)
-		return m, cmd
-	case 2:
-		m.outputPathInput, cmd = m.outputPathInput.Update(msg)
-		return m, cmd
-	}
-
-	return m, nil
+	*inputs[m.focusIndex], cmd = inputs[m.focusIndex].Update(msg)
+	return m, cmd
 }
 
-// generateNewResource creates a new resource file
-// This is a simplified version of the command to avoid dependency issues
-func generateNewResource(resourceType, resourceName, outputPath string) error {
-	// Validate resource type
-	if resourceType != "applicationset" {
-		return fmt.Errorf("unsupported resource type: %s", resourceType)
-	}
-
-	// If resourceName is not provided, error
-	if resourceName == "" {
-		return fmt.Errorf("resource name is required")
-	}
-
-	// Set default output path if not provided
-	if outputPath == "" {
-		outputPath = "templates/apps"
-	}
-
-	// Create the output directory if it doesn't exist
-	if err := os.MkdirAll(outputPath, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
-	}
-
-	// Generate the content
-	content := generateApplicationSetContent(resourceName)
-
-	// Write the file
-	filename := fmt.Sprintf("%s-%s.yaml", resourceType, resourceName)
-	filePath := filepath.Join(outputPath, filename)
-
-	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
-		return fmt.Errorf("failed to create file %s: %w", filename, err)
+// applyParams sets the parameters entered in the form on the options
+func (m newModel) applyParams(opts *scaffold.NewOptions) error {
+	for i, param := range m.params {
+		entries := paramEntries(param.Kind, m.paramInputs[i].Value())
+		if len(entries) == 0 {
+			continue
+		}
+		if err := param.Apply(opts, entries); err != nil {
+			return fmt.Errorf("invalid %s: %w", param.Name, err)
+		}
 	}
-
-	fmt.Printf("Created file: %s\n", filePath)
 	return nil
 }
 
-// generateApplicationSetContent generates an ApplicationSet template
-func generateApplicationSetContent(name string) string {
-	return fmt.Sprintf(`apiVersion: argoproj.io/v1alpha1
-kind: ApplicationSet
-metadata:
-  name: %s
-  namespace: argocd
-spec:
-  generators:
-    - git:
-        repoURL: {{ .Values.global.repoURL }}
-        revision: {{ .Values.global.targetRevision }}
-        directories:
-          - path: apps/*
-  template:
-    metadata:
-      name: '{{ "{{ path.basename }}" }}'
-      labels:
-        {{- include "common.labels" . | nindent 8 }}
-    spec:
-      project: {{ include "common.projectName" . }}
-      source:
-        repoURL: {{ .Values.global.repoURL }}
-        targetRevision: {{ .Values.global.targetRevision }}
-        path: '{{ "{{ path }}" }}'
-      destination:
-        server: {{ .Values.destination.server | default "https://kubernetes.default.svc" }}
-        namespace: '{{ "{{ path.basename }}" }}'
-      syncPolicy:
-        {{- toYaml .Values.applications.defaults.syncPolicy | nindent 8 }}
-`, name)
-}
-
 func (m newModel) View() string {
+	if m.resourceType == nil {
+		help := "\nEnter: Select • Esc: Cancel"
+		return appStyle.Render(fmt.Sprintf("%s\n%s", m.typeList.View(), help))
+	}
+
 	if m.submitted {
 		return appStyle.Render("Creating resource...")
 	}
 
-	var resourceTypeStyle, resourceNameStyle, outputPathStyle lipgloss.Style
-
-	switch m.focusIndex {
-	case 0:
-		resourceTypeStyle = focusedStyle
-		resourceNameStyle = blurredStyle
-		outputPathStyle = blurredStyle
-	case 1:
-		resourceTypeStyle = blurredStyle
-		resourceNameStyle = focusedStyle
-		outputPathStyle = blurredStyle
-	case 2:
-		resourceTypeStyle = blurredStyle
-		resourceNameStyle = blurredStyle
-		outputPathStyle = focusedStyle
+	styleFor := func(index int) lipgloss.Style {
+		if index == m.focusIndex {
+			return focusedStyle
+		}
+		return blurredStyle
 	}
 
 	// Error display
@@ -267,20 +269,30 @@ func (m newModel) View() string {
 			Render(fmt.Sprintf("Error: %v", m.err))
 	}
 
-	title := titleStyle.Render("Create New ArgoCD Resource")
-	resourceTypeInput := fmt.Sprintf("Resource Type (default: applicationset):\n%s", resourceTypeStyle.Render(m.resourceTypeInput.View()))
-	resourceNameInput := fmt.Sprintf("Resource Name (required):\n%s", resourceNameStyle.Render(m.resourceNameInput.View()))
-	outputPathInput := fmt.Sprintf("Output Path (default: templates/apps):\n%s", outputPathStyle.Render(m.outputPathInput.View()))
+	title := titleStyle.Render(fmt.Sprintf("Create New %s", m.resourceType.Name()))
+
+	fields := []string{
+		fmt.Sprintf("Resource Name (required):\n%s", styleFor(0).Render(m.resourceNameInput.View())),
+		fmt.Sprintf("Output Path (default: %s):\n%s", m.resourceType.OutputPath(), styleFor(1).Render(m.outputPathInput.View())),
+	}
+	for i, param := range m.params {
+		label := param.Description
+		switch param.Kind {
+		case scaffold.ListParameter, scaffold.MapParameter:
+			label += ", comma-separated"
+		case scaffold.RepeatedParameter:
+			label += ", separated by ;"
+		}
+		fields = append(fields, fmt.Sprintf("%s:\n%s", label, styleFor(i+2).Render(m.paramInputs[i].View())))
+	}
 
 	help := "\nTab/Shift+Tab: Navigate • Enter: Submit • Esc: Cancel"
 
 	return appStyle.Render(
 		fmt.Sprintf(
-			"%s\n\n%s\n\n%s\n\n%s\n\n%s\n%s",
+			"%s\n\n%s\n\n%s\n%s",
 			title,
-			resourceTypeInput,
-			resourceNameInput,
-			outputPathInput,
+			strings.Join(fields, "\n\n"),
 			errorText,
 			help,
 		),
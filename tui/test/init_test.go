@@ -0,0 +1,52 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/rebelopsio/argo-helper/tui"
+)
+
+func TestInitRefusesExistingScaffold(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(cwd)
+
+	chart := "apiVersion: v2\nname: existing\n"
+	if err := os.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte(chart), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "templates"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	helpers := `{{- define "common.projectName" -}}existing{{- end }}` + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "templates", "_helpers.tpl"), []byte(helpers), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	// Type a project name and submit, the path defaults to the current
+	// directory
+	model, _ := tui.ExportedMenuInitAction()
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("demo")})
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	if !strings.Contains(model.View(), "already contains an argo-helper scaffold") {
+		t.Errorf("Expected the existing scaffold to be refused:\n%s", model.View())
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "Chart.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if string(data) != chart {
+		t.Errorf("Expected Chart.yaml to be kept:\n%s", data)
+	}
+}
@@ -1,9 +1,15 @@
 package test
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/viper"
+
+	"github.com/rebelopsio/argo-helper/scaffold"
 	"github.com/rebelopsio/argo-helper/tui"
 )
 
@@ -11,18 +17,143 @@ func TestMenuNewAction(t *testing.T) {
 	// Since we can't directly test the new resource action without mocking CMD
 	// Let's just test that the main model works
 	model := tui.NewModel()
-	
+
 	// Check that the model is initialized properly
 	if model.View() == "" {
 		t.Errorf("View should not be empty")
 	}
-	
+
 	// Test that the model handles keyboard input as expected
 	// Simulate a quit key message
 	_, cmd := model.Update(tea.KeyMsg{Type: tea.KeyCtrlC})
-	
+
 	// Should have a quit command
 	if cmd == nil {
 		t.Errorf("Expected quit command on Ctrl+C, got nil")
 	}
-}
\ No newline at end of file
+}
+
+// selectResourceType moves the resource type list of the new form to the
+// named type, the list is sorted like scaffold.ResourceTypes
+func selectResourceType(t *testing.T, model tea.Model, name string) tea.Model {
+	t.Helper()
+	for _, rt := range scaffold.ResourceTypes() {
+		if rt.Name() == name {
+			return model
+		}
+		model, _ = model.Update(tea.KeyMsg{Type: tea.KeyDown})
+	}
+	t.Fatalf("Resource type %s is not registered", name)
+	return model
+}
+
+func TestNewResourceCreatesFile(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(cwd)
+
+	// Pick the ApplicationSet type, type a name and submit
+	model, _ := tui.ExportedMenuNewAction()
+	model = selectResourceType(t, model, scaffold.TypeApplicationSet)
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("web")})
+	model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	expected := filepath.Join(dir, "templates", "apps", "applicationset-web.yaml")
+	if _, err := os.Stat(expected); err != nil {
+		t.Errorf("Expected %s to be created: %v", expected, err)
+	}
+}
+
+func TestNewResourceUsesTemplateOverrides(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(cwd)
+
+	overrides := filepath.Join(t.TempDir(), "new")
+	if err := os.MkdirAll(overrides, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(overrides, "applicationset.yaml.tmpl"), []byte("# custom [[ .Name ]]\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	viper.Set("templates-dir", filepath.Dir(overrides))
+	defer viper.Set("templates-dir", "")
+
+	model, _ := tui.ExportedMenuNewAction()
+	model = selectResourceType(t, model, scaffold.TypeApplicationSet)
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("web")})
+	model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	data, err := os.ReadFile(filepath.Join(dir, "templates", "apps", "applicationset-web.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if string(data) != "# custom web\n" {
+		t.Errorf("Expected the overridden template to be used:\n%s", data)
+	}
+}
+
+func TestNewResourceListsTypes(t *testing.T) {
+	model, _ := tui.ExportedMenuNewAction()
+	view := model.View()
+
+	for _, rt := range scaffold.ResourceTypes() {
+		if !strings.Contains(view, rt.Name()) {
+			t.Errorf("Expected resource type %s to be listed:\n%s", rt.Name(), view)
+		}
+	}
+}
+
+func TestNewResourceAsksBeforeOverwrite(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(cwd)
+
+	existing := filepath.Join(dir, "templates", "apps", "application-web.yaml")
+	if err := os.MkdirAll(filepath.Dir(existing), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(existing, []byte("# customized\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	model, _ := tui.ExportedMenuNewAction()
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("web")})
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	if !strings.Contains(model.View(), "already exists") {
+		t.Fatalf("Expected a prompt for the existing file:\n%s", model.View())
+	}
+
+	// Keep the existing file
+	model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+
+	data, err := os.ReadFile(existing)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if string(data) != "# customized\n" {
+		t.Errorf("Expected the existing file to be kept:\n%s", data)
+	}
+}
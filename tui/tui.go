@@ -7,6 +7,8 @@ import (
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+
+	"github.com/rebelopsio/argo-helper/config"
 )
 
 var (
@@ -106,6 +108,11 @@ func NewModel() Model {
 
 // Run starts the TUI application
 func Run() error {
+	// The TUI uses the same config file as the CLI, for the templates-dir
+	if _, err := config.Load(""); err != nil {
+		return err
+	}
+
 	p := tea.NewProgram(NewModel())
 	_, err := p.Run()
 	return err
@@ -125,3 +132,8 @@ func menuNewAction() (tea.Model, tea.Cmd) {
 func ExportedMenuNewAction() (tea.Model, tea.Cmd) {
 	return menuNewAction()
 }
+
+// ExportedMenuInitAction is a wrapper for tests to access menuInitAction
+func ExportedMenuInitAction() (tea.Model, tea.Cmd) {
+	return menuInitAction()
+}
@@ -0,0 +1,47 @@
+package yamledit
+
+import (
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Lookup returns the node at a path of mapping keys below node, or nil.
+// Sequence items are looked up by their index, document nodes in their
+// content.
+func Lookup(node *yaml.Node, path ...string) *yaml.Node {
+	if node != nil && node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	for _, key := range path {
+		if node != nil && node.Kind == yaml.SequenceNode {
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node.Content) {
+				return nil
+			}
+			node = node.Content[i]
+			continue
+		}
+		_, node = entry(node, key)
+	}
+	return node
+}
+
+// Scalar returns the value of the scalar at a path below node, or an empty
+// string
+func Scalar(node *yaml.Node, path ...string) string {
+	value := Lookup(node, path...)
+	if value == nil || value.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return value.Value
+}
+
+// Items returns the items of the sequence at a path below node
+func Items(node *yaml.Node, path ...string) []*yaml.Node {
+	value := Lookup(node, path...)
+	if value == nil || value.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return value.Content
+}
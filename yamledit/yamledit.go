@@ -0,0 +1,379 @@
+// Package yamledit edits YAML files in place, like values.yaml, keeping
+// their comments, key order and formatting. Edits are located with the
+// yaml.v3 node tree and applied to the text, so only the edited lines
+// change. Edits the text cannot express, like replacing a block mapping,
+// fall back to re-encoding the node tree, which keeps comments but not
+// blank lines.
+//
+// Lookup, Scalar and Items read yaml.v3 node trees by path, they are
+// shared by the packages inspecting rendered manifests and values files.
+package yamledit
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Document is a YAML document being edited
+type Document struct {
+	data []byte
+	root yaml.Node
+}
+
+// Parse parses a YAML document whose top level is a mapping or empty
+func Parse(data []byte) (*Document, error) {
+	d := &Document{}
+	if err := d.reset(data); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *Document) reset(data []byte) error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return err
+	}
+	if len(root.Content) > 0 && root.Content[0].Kind != yaml.MappingNode && !isNull(root.Content[0]) {
+		return fmt.Errorf("line %d: expected a mapping at the top level", root.Content[0].Line)
+	}
+	d.data, d.root = data, root
+	return nil
+}
+
+// Bytes returns the edited document
+func (d *Document) Bytes() []byte {
+	return d.data
+}
+
+// SplitPath splits a dotted path like applications.web.targetRevision into
+// its keys
+func SplitPath(path string) []string {
+	return strings.Split(path, ".")
+}
+
+// Lookup returns the value at a path, or nil
+func (d *Document) Lookup(path []string) *yaml.Node {
+	return Lookup(d.top(), path...)
+}
+
+// Set sets the value at a path, creating the mappings leading to it, and
+// reports whether the document changed. Setting a value to what it already
+// is leaves the document untouched.
+func (d *Document) Set(path []string, value *yaml.Node) (bool, error) {
+	return d.SetCommented(path, value, "")
+}
+
+// SetCommented is Set with a comment above the first key of the path when
+// the edit creates it, like "# Additional ArgoCD projects" above a new
+// projects key. The comment includes the # marker.
+func (d *Document) SetCommented(path []string, value *yaml.Node, comment string) (bool, error) {
+	if len(path) == 0 {
+		return false, fmt.Errorf("empty path")
+	}
+	if value.Kind == yaml.DocumentNode && len(value.Content) > 0 {
+		value = value.Content[0]
+	}
+	copied := *value
+	value = &copied
+
+	parent := d.top()
+	if parent == nil {
+		return true, d.appendTopLevel(nest(path, value, comment))
+	}
+	for i, key := range path {
+		k, v := entry(parent, key)
+		switch {
+		case k == nil:
+			if i > 0 {
+				comment = ""
+			}
+			return true, d.insert(parent, nest(path[i:], value, comment))
+		case i == len(path)-1:
+			return d.replace(k, v, value)
+		case v.Kind == yaml.MappingNode:
+			parent = v
+		case isNull(v):
+			return true, d.rewrite(k, v, nest(path[i+1:], value, ""))
+		default:
+			return false, fmt.Errorf("%s is not a mapping", strings.Join(path[:i+1], "."))
+		}
+	}
+	return false, nil
+}
+
+// top returns the top-level mapping, or nil for an empty document
+func (d *Document) top() *yaml.Node {
+	if len(d.root.Content) == 0 || d.root.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	return d.root.Content[0]
+}
+
+// replace replaces the value of a key unless it is equal
+func (d *Document) replace(k, v, value *yaml.Node) (bool, error) {
+	if equal(v, value) {
+		return false, nil
+	}
+	return true, d.rewrite(k, v, value)
+}
+
+// rewrite replaces the value of a key in the text when both are on one
+// line or the value is null, and re-encodes the document otherwise
+func (d *Document) rewrite(k, v, value *yaml.Node) error {
+	if v.Kind == yaml.ScalarNode {
+		if start, end, ok := d.scalarSpan(v); ok {
+			if value.Kind == yaml.ScalarNode {
+				if value.Style == 0 && v.Tag == "!!str" && value.Tag == "!!str" {
+					// Keep the quoting of the value being replaced
+					value.Style = v.Style
+				}
+				if text, ok := inline(value); ok {
+					if start == end {
+						text = " " + text
+					}
+					return d.splice(v.Line, start, end, text, "")
+				}
+			} else if value.Style&yaml.FlowStyle == 0 && (isNull(v) || start == end) {
+				block, err := render(value, k.Column+1)
+				if err != nil {
+					return err
+				}
+				return d.splice(v.Line, start, end, "", block)
+			}
+		}
+	}
+
+	value.LineComment = v.LineComment
+	*v = *value
+	return d.encode()
+}
+
+// insert adds the keys of a mapping at the end of a block mapping
+func (d *Document) insert(mapping, keys *yaml.Node) error {
+	if mapping == d.top() {
+		return d.appendTopLevel(keys)
+	}
+	if mapping.Style&yaml.FlowStyle != 0 || len(mapping.Content) == 0 {
+		mapping.Style = 0
+		mapping.Content = append(mapping.Content, keys.Content...)
+		return d.encode()
+	}
+
+	block, err := render(keys, mapping.Content[0].Column-1)
+	if err != nil {
+		return err
+	}
+	return d.insertLines(lastLine(mapping), block)
+}
+
+// appendTopLevel adds the keys of a mapping at the end of the document,
+// separated by a blank line
+func (d *Document) appendTopLevel(keys *yaml.Node) error {
+	block, err := render(keys, 0)
+	if err != nil {
+		return err
+	}
+
+	content := string(d.data)
+	if strings.TrimSpace(content) != "" {
+		if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		if !strings.HasSuffix(content, "\n\n") {
+			content += "\n"
+		}
+	}
+	return d.reset([]byte(content + block))
+}
+
+// splice replaces the characters start to end of a line with text and
+// inserts block below it
+func (d *Document) splice(line, start, end int, text, block string) error {
+	lines := strings.SplitAfter(string(d.data), "\n")
+	runes := []rune(lines[line-1])
+	before, after := string(runes[:start]), string(runes[end:])
+	if text == "" && strings.TrimSpace(after) == "" {
+		before = strings.TrimRight(before, " ")
+	}
+	edited := before + text + after
+	if block != "" {
+		if !strings.HasSuffix(edited, "\n") {
+			edited += "\n"
+		}
+		edited += block
+	}
+	lines[line-1] = edited
+	return d.reset([]byte(strings.Join(lines, "")))
+}
+
+// insertLines inserts block below a line
+func (d *Document) insertLines(line int, block string) error {
+	lines := strings.SplitAfter(string(d.data), "\n")
+	if line > len(lines) {
+		line = len(lines)
+	}
+	if !strings.HasSuffix(lines[line-1], "\n") {
+		lines[line-1] += "\n"
+	}
+	edited := strings.Join(lines[:line], "") + block + strings.Join(lines[line:], "")
+	return d.reset([]byte(edited))
+}
+
+// encode replaces the text with the encoded node tree
+func (d *Document) encode() error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&d.root); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	return d.reset(buf.Bytes())
+}
+
+// scalarSpan returns the characters of a line holding a single-line scalar,
+// ok is false for scalars spanning lines or with tags or anchors
+func (d *Document) scalarSpan(node *yaml.Node) (start, end int, ok bool) {
+	lines := strings.Split(string(d.data), "\n")
+	if node.Line < 1 || node.Line > len(lines) || node.Anchor != "" {
+		return 0, 0, false
+	}
+	line := []rune(lines[node.Line-1])
+	start = node.Column - 1
+	if start < 0 || start > len(line) {
+		return 0, 0, false
+	}
+
+	switch node.Style {
+	case 0:
+		value := []rune(node.Value)
+		if start+len(value) > len(line) || string(line[start:start+len(value)]) != node.Value {
+			return 0, 0, false
+		}
+		return start, start + len(value), true
+	case yaml.DoubleQuotedStyle:
+		for i := start + 1; i < len(line); i++ {
+			switch line[i] {
+			case '\\':
+				i++
+			case '"':
+				return start, i + 1, true
+			}
+		}
+	case yaml.SingleQuotedStyle:
+		for i := start + 1; i < len(line); i++ {
+			if line[i] != '\'' {
+				continue
+			}
+			if i+1 < len(line) && line[i+1] == '\'' {
+				i++
+				continue
+			}
+			return start, i + 1, true
+		}
+	}
+	return 0, 0, false
+}
+
+// entry returns the key and value nodes of a key of a mapping
+func entry(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// nest returns the mappings leading to a value through keys, with a
+// comment above the first key
+func nest(keys []string, value *yaml.Node, comment string) *yaml.Node {
+	for i := len(keys) - 1; i >= 0; i-- {
+		value = &yaml.Node{
+			Kind:    yaml.MappingNode,
+			Tag:     "!!map",
+			Content: []*yaml.Node{{Kind: yaml.ScalarNode, Tag: "!!str", Value: keys[i]}, value},
+		}
+	}
+	if comment != "" && value.Kind == yaml.MappingNode {
+		value.Content[0].HeadComment = comment
+	}
+	return value
+}
+
+// render encodes a node as block YAML indented by indent spaces
+func render(node *yaml.Node, indent int) (string, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+
+	prefix := strings.Repeat(" ", indent)
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if strings.TrimSpace(line) != "" {
+			b.WriteString(prefix)
+		}
+		b.WriteString(line)
+	}
+	return b.String(), nil
+}
+
+// inline encodes a scalar on one line, ok is false when it needs more
+func inline(node *yaml.Node) (string, bool) {
+	text, err := render(node, 0)
+	if err != nil {
+		return "", false
+	}
+	text = strings.TrimSuffix(text, "\n")
+	return text, !strings.Contains(text, "\n")
+}
+
+// lastLine returns the last line of a node and its children
+func lastLine(node *yaml.Node) int {
+	line := node.Line
+	if node.Kind == yaml.ScalarNode {
+		line += strings.Count(strings.TrimRight(node.Value, "\n"), "\n")
+		if node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+			line++
+		}
+	}
+	for _, child := range node.Content {
+		if l := lastLine(child); l > line {
+			line = l
+		}
+	}
+	return line
+}
+
+// equal reports whether two nodes hold the same value. Scalars are
+// compared by type and text, so 1.10 does not equal 1.1.
+func equal(a, b *yaml.Node) bool {
+	if a.Kind == yaml.ScalarNode && b.Kind == yaml.ScalarNode {
+		return a.ShortTag() == b.ShortTag() && a.Value == b.Value
+	}
+	var x, y interface{}
+	if a.Decode(&x) != nil || b.Decode(&y) != nil {
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Tag == "!!null"
+}
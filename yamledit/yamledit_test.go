@@ -0,0 +1,218 @@
+package yamledit
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const values = `# Production values
+
+global:
+  environment: prod # the environment
+  targetRevision: "v1.0.0"
+
+applications:
+  web:
+    image:
+      tag: '1.0'
+    extra: ~
+
+# Trailing comment
+`
+
+func scalar(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+func TestSet(t *testing.T) {
+	mapping := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{scalar("enabled"), {Kind: yaml.ScalarNode, Tag: "!!bool", Value: "true"}}}
+
+	tests := []struct {
+		name     string
+		path     string
+		value    *yaml.Node
+		expected string
+	}{
+		{
+			name:  "replace keeping quotes and comments",
+			path:  "global.targetRevision",
+			value: scalar("v1.1.0"),
+			expected: `# Production values
+
+global:
+  environment: prod # the environment
+  targetRevision: "v1.1.0"
+
+applications:
+  web:
+    image:
+      tag: '1.0'
+    extra: ~
+
+# Trailing comment
+`,
+		},
+		{
+			name:  "replace plain scalar before a comment",
+			path:  "global.environment",
+			value: scalar("production"),
+			expected: `# Production values
+
+global:
+  environment: production # the environment
+  targetRevision: "v1.0.0"
+
+applications:
+  web:
+    image:
+      tag: '1.0'
+    extra: ~
+
+# Trailing comment
+`,
+		},
+		{
+			name:  "insert into nested mapping",
+			path:  "applications.web.image.repository",
+			value: scalar("nginx"),
+			expected: `# Production values
+
+global:
+  environment: prod # the environment
+  targetRevision: "v1.0.0"
+
+applications:
+  web:
+    image:
+      tag: '1.0'
+      repository: nginx
+    extra: ~
+
+# Trailing comment
+`,
+		},
+		{
+			name:  "insert missing mappings",
+			path:  "applications.api.image.tag",
+			value: scalar("2.0"),
+			expected: `# Production values
+
+global:
+  environment: prod # the environment
+  targetRevision: "v1.0.0"
+
+applications:
+  web:
+    image:
+      tag: '1.0'
+    extra: ~
+  api:
+    image:
+      tag: "2.0"
+
+# Trailing comment
+`,
+		},
+		{
+			name:  "mapping replacing null",
+			path:  "applications.web.extra",
+			value: mapping,
+			expected: `# Production values
+
+global:
+  environment: prod # the environment
+  targetRevision: "v1.0.0"
+
+applications:
+  web:
+    image:
+      tag: '1.0'
+    extra:
+      enabled: true
+
+# Trailing comment
+`,
+		},
+		{
+			name:  "append top-level key",
+			path:  "destination.server",
+			value: scalar("https://prod"),
+			expected: values + `
+destination:
+  server: https://prod
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse([]byte(values))
+			if err != nil {
+				t.Fatalf("Failed to parse: %v", err)
+			}
+			changed, err := doc.Set(SplitPath(tt.path), tt.value)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !changed || string(doc.Bytes()) != tt.expected {
+				t.Errorf("Expected change to:\n%s\ngot (changed %v):\n%s", tt.expected, changed, doc.Bytes())
+			}
+
+			// Setting the same value again is a no-op
+			before := string(doc.Bytes())
+			if changed, err := doc.Set(SplitPath(tt.path), tt.value); err != nil || changed || string(doc.Bytes()) != before {
+				t.Errorf("Expected the second edit to change nothing, got changed %v, error %v:\n%s", changed, err, doc.Bytes())
+			}
+		})
+	}
+}
+
+func TestSetErrors(t *testing.T) {
+	doc, err := Parse([]byte(values))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if _, err := doc.Set(SplitPath("global.environment.name"), scalar("x")); err == nil {
+		t.Errorf("Expected error setting a key below a scalar")
+	}
+	if _, err := Parse([]byte("- a\n- b\n")); err == nil {
+		t.Errorf("Expected error for a top-level sequence")
+	}
+}
+
+func TestSetEmptyDocument(t *testing.T) {
+	doc, err := Parse(nil)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if _, err := doc.Set(SplitPath("global.project"), scalar("demo")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := "global:\n  project: demo\n"; string(doc.Bytes()) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, doc.Bytes())
+	}
+	if node := doc.Lookup(SplitPath("global.project")); node == nil || node.Value != "demo" {
+		t.Errorf("Expected to look up the new value, got %v", node)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte("spec:\n  project: web\n  sources:\n    - path: a\n    - path: b\n"), &doc); err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	if got := Scalar(&doc, "spec", "project"); got != "web" {
+		t.Errorf("Expected web, got %q", got)
+	}
+	if got := Scalar(&doc, "spec", "sources"); got != "" {
+		t.Errorf("Expected no scalar for a sequence, got %q", got)
+	}
+	if got := Items(&doc, "spec", "sources"); len(got) != 2 || Scalar(got[1], "path") != "b" {
+		t.Errorf("Expected two sources, got %v", got)
+	}
+	if Lookup(&doc, "spec", "project", "name") != nil || Lookup(nil, "spec") != nil {
+		t.Errorf("Expected nil for missing paths")
+	}
+}